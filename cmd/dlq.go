@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/project-kessel/inventory-consumer/consumer"
+	kessel "github.com/project-kessel/inventory-consumer/internal/client"
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/spf13/cobra"
+)
+
+// replayOptions contains the settings used to select which dead-lettered messages are replayed
+type replayOptions struct {
+	topic       string
+	partition   int32
+	startOffset int64
+	endOffset   int64
+	startTime   string
+	endTime     string
+	stage       string
+	resourceID  string
+	dryRun      bool
+}
+
+func dlqCommand(consumerOptions *consumer.Options, clientOptions *kessel.Options, loggerOptions common.LoggerOptions) *cobra.Command {
+	dlqCmd := &cobra.Command{
+		Use:   "dlq",
+		Short: "Manage messages sent to the dead-letter topic",
+	}
+	dlqCmd.AddCommand(dlqReplayCommand(consumerOptions, clientOptions, loggerOptions))
+	return dlqCmd
+}
+
+func dlqReplayCommand(consumerOptions *consumer.Options, clientOptions *kessel.Options, loggerOptions common.LoggerOptions) *cobra.Command {
+	opts := &replayOptions{}
+	replayCmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Reprocess messages from the dead-letter topic",
+		Long: `Reads the dead-letter topic from the beginning, or from the provided offset or time, up to its current end
+and feeds each matching message back through the consumer using its original headers. Offsets are not committed,
+so a replay can be repeated. Use --dry-run to print the resulting requests without sending them to Inventory API.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, logger := common.InitLogger(common.GetLogLevel(), loggerOptions)
+			logHelper := log.NewHelper(log.With(logger, "subsystem", "dlqReplay"))
+
+			filter, err := opts.filter()
+			if err != nil {
+				return err
+			}
+			topic := opts.topic
+			if topic == "" {
+				topic = consumerOptions.DeadLetterTopic
+			}
+			if topic == "" {
+				return fmt.Errorf("no dead-letter topic provided: set --topic or consumer.dead-letter-topic")
+			}
+
+			// the replay reads with its own consumer group and never commits, so the main consumer group is unaffected
+			// and failures during the replay are not dead-lettered again
+			replayConsumerOptions := *consumerOptions
+			replayConsumerOptions.Topics = []string{topic}
+			replayConsumerOptions.ConsumerGroupID = consumerOptions.ConsumerGroupID + "-dlq-replay"
+			replayConsumerOptions.EnableAutoCommit = "false"
+			replayConsumerOptions.DeadLetterTopic = ""
+			// replayed messages are not read by partition workers, so their requests are sent one at a time
+			replayConsumerOptions.BatchOptions = nil
+			replayConsumerOptions.TopicOptions = make(map[string]*consumer.TopicOptions, len(consumerOptions.TopicOptions))
+			for name, options := range consumerOptions.TopicOptions {
+				if options != nil {
+					topicOptions := *options
+					topicOptions.DeadLetterTopic = ""
+					replayConsumerOptions.TopicOptions[name] = &topicOptions
+				}
+			}
+			if errs := replayConsumerOptions.Complete(); errs != nil {
+				return fmt.Errorf("failed to setup consumer options: %v", errs)
+			}
+			if errs := replayConsumerOptions.Validate(); errs != nil {
+				return fmt.Errorf("consumer options validation error: %v", errs)
+			}
+			consumerConfig, errs := consumer.NewConfig(&replayConsumerOptions).Complete()
+			if errs != nil {
+				return fmt.Errorf("failed to setup consumer config: %v", errs)
+			}
+
+			var client kessel.ClientProvider
+			if opts.dryRun {
+				client = &printingClient{out: cmd.OutOrStdout()}
+			} else {
+				if errs := clientOptions.Complete(); errs != nil {
+					return fmt.Errorf("failed to setup client options: %v", errs)
+				}
+				if errs := clientOptions.Validate(); errs != nil {
+					return fmt.Errorf("client options validation error: %v", errs)
+				}
+				clientConfig, errs := kessel.NewConfig(clientOptions).Complete()
+				if errs != nil {
+					return fmt.Errorf("failed to setup client config: %v", errs)
+				}
+				client, err = kessel.New(clientConfig, log.NewHelper(log.With(logger, "subsystem", "client")))
+				if err != nil {
+					return fmt.Errorf("failed to instantiate client: %v", err)
+				}
+			}
+
+			reader, err := kafka.NewConsumer(consumerConfig.KafkaConfig)
+			if err != nil {
+				return fmt.Errorf("failed to create kafka consumer: %v", err)
+			}
+			defer reader.Close() //nolint:errcheck
+
+			replayer, err := consumer.New(consumerConfig, client, logHelper, reader)
+			if err != nil {
+				return fmt.Errorf("failed to setup consumer: %v", err)
+			}
+
+			// interrupting the replay cancels the request in flight
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			result, err := replayer.Replay(ctx, reader, topic, filter)
+			fmt.Fprintf(cmd.OutOrStdout(), "Replay finished: replayed=%d skipped=%d failed=%d\n", result.Replayed, result.Skipped, result.Failed) //nolint:errcheck
+			if err != nil {
+				return err
+			}
+			if result.Failed > 0 {
+				return fmt.Errorf("%d message(s) failed to replay", result.Failed)
+			}
+			return nil
+		},
+	}
+
+	fs := replayCmd.Flags()
+	fs.StringVar(&opts.topic, "topic", "", "dead-letter topic to replay (default: consumer.dead-letter-topic)")
+	fs.Int32Var(&opts.partition, "partition", -1, "only replay messages from this partition (default: all partitions)")
+	fs.Int64Var(&opts.startOffset, "start-offset", -1, "first offset to replay in each partition, inclusive")
+	fs.Int64Var(&opts.endOffset, "end-offset", -1, "last offset to replay in each partition, inclusive")
+	fs.StringVar(&opts.startTime, "start-time", "", "only replay messages dead-lettered at or after this time (RFC3339)")
+	fs.StringVar(&opts.endTime, "end-time", "", "only replay messages dead-lettered at or before this time (RFC3339)")
+	fs.StringVar(&opts.stage, "stage", "", "only replay messages that failed at this processing stage, e.g. ParseCreateOrUpdateMessage")
+	fs.StringVar(&opts.resourceID, "resource-id", "", "only replay messages for this resource ID")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the transformed requests instead of sending them to Inventory API")
+	return replayCmd
+}
+
+// filter converts the replay options into a consumer.ReplayFilter
+func (o *replayOptions) filter() (consumer.ReplayFilter, error) {
+	filter := consumer.NewReplayFilter()
+	filter.Partition = o.partition
+	filter.StartOffset = o.startOffset
+	filter.EndOffset = o.endOffset
+	filter.Stage = o.stage
+	filter.ResourceID = o.resourceID
+
+	var err error
+	if o.startTime != "" {
+		if filter.StartTime, err = time.Parse(time.RFC3339, o.startTime); err != nil {
+			return filter, fmt.Errorf("invalid start time: %v", err)
+		}
+	}
+	if o.endTime != "" {
+		if filter.EndTime, err = time.Parse(time.RFC3339, o.endTime); err != nil {
+			return filter, fmt.Errorf("invalid end time: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+// printingClient implements kessel.ClientProvider by printing each request instead of sending it, for dry runs
+type printingClient struct {
+	out io.Writer
+}
+
+func (p *printingClient) CreateOrUpdateResource(ctx context.Context, request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error) {
+	return &v1beta2.ReportResourceResponse{}, p.print("ReportResource", request)
+}
+
+func (p *printingClient) DeleteResource(ctx context.Context, request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error) {
+	return &v1beta2.DeleteResourceResponse{}, p.print("DeleteResource", request)
+}
+
+func (p *printingClient) IsEnabled() bool {
+	return true
+}
+
+func (p *printingClient) print(operation string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", operation, err)
+	}
+	_, err = fmt.Fprintf(p.out, "%s %s\n", operation, body)
+	return err
+}
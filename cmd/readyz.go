@@ -5,13 +5,11 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/authzed/grpcutil"
 	"github.com/go-kratos/kratos/v2/log"
 	kesselv1 "github.com/project-kessel/inventory-api/api/kessel/inventory/v1"
 	kessel "github.com/project-kessel/inventory-consumer/internal/client"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 func readyzCommand(clientOptions *kessel.Options) *cobra.Command {
@@ -45,21 +43,17 @@ The InventoryURL from the client configuration is used as the gRPC endpoint.`,
 
 			fmt.Printf("Checking inventory service readiness at: %s\n", clientOptions.InventoryURL)
 
-			// Set up gRPC connection options
-			var opts []grpc.DialOption
-
-			if clientOptions.Insecure {
-				opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-			} else {
-				tlsConfig, _ := grpcutil.WithSystemCerts(grpcutil.VerifyCA)
-				opts = append(opts, tlsConfig)
+			// Set up gRPC connection with the same TLS settings as the consumer's client
+			creds, err := kessel.TransportCredentials(clientOptions)
+			if err != nil {
+				return fmt.Errorf("failed to configure TLS for the inventory service: %v", err)
 			}
 
 			// Create gRPC connection with timeout
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 
-			conn, err := grpc.NewClient(clientOptions.InventoryURL, opts...)
+			conn, err := grpc.NewClient(clientOptions.InventoryURL, grpc.WithTransportCredentials(creds))
 			if err != nil {
 				return fmt.Errorf("failed to connect to inventory service: %v", err)
 			}
@@ -92,6 +92,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	// dlq flags only configure the replay itself and are not config file options, so they are not bound to viper
+	dlqCmd := dlqCommand(options.Consumer, options.Client, loggerOptions)
+	rootCmd.AddCommand(dlqCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"github.com/project-kessel/inventory-consumer/consumer"
 	kessel "github.com/project-kessel/inventory-consumer/internal/client"
 	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/project-kessel/inventory-consumer/internal/secrets"
 	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
 	"github.com/spf13/cobra"
 )
@@ -52,9 +54,17 @@ subscribed to the provided topic`,
 			}
 			client, err = kessel.New(clientConfig, log.NewHelper(log.With(logger, "subsystem", "client")))
 			if err != nil {
-				return fmt.Errorf("failed to instantiate client: %v", errs)
+				return fmt.Errorf("failed to instantiate client: %v", err)
 			}
 
+			// rotated secrets are reloaded from their files without restarting
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+			watcher := secrets.NewWatcher(secrets.DefaultInterval, logHelper)
+			watcher.Add(clientOptions.ClientSecretFile, client.SetClientSecret)
+			watcher.Add(consumerOptions.AuthOptions.SASLPasswordFile, consumerConfig.SetSASLPassword)
+			go watcher.Run(ctx)
+
 			quit := make(chan os.Signal, 1)
 			signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
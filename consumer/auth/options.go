@@ -1,6 +1,11 @@
 package auth
 
-import "github.com/spf13/pflag"
+import (
+	"fmt"
+
+	"github.com/project-kessel/inventory-consumer/internal/secrets"
+	"github.com/spf13/pflag"
+)
 
 type Options struct {
 	Enabled          bool   `mapstructure:"enabled"`
@@ -8,6 +13,8 @@ type Options struct {
 	SASLMechanism    string `mapstructure:"sasl-mechanism"`
 	SASLUsername     string `mapstructure:"sasl-username"`
 	SASLPassword     string `mapstructure:"sasl-password"`
+	// SASLPasswordFile is a file the SASL password is read from instead, and reloaded from when it changes
+	SASLPasswordFile string `mapstructure:"sasl-password-file"`
 	CACertLocation   string `mapstructure:"ca-cert-location"`
 }
 
@@ -26,5 +33,19 @@ func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
 	fs.StringVar(&o.SASLMechanism, prefix+"sasl-mechanism", o.SASLMechanism, "sets the SASL mechanism")
 	fs.StringVar(&o.SASLUsername, prefix+"sasl-username", o.SASLUsername, "sets the username to use for authentication")
 	fs.StringVar(&o.SASLPassword, prefix+"sasl-password", o.SASLPassword, "sets the password to use for authentication")
+	fs.StringVar(&o.SASLPasswordFile, prefix+"sasl-password-file", o.SASLPasswordFile, "file containing the password to use for authentication, reloaded when it changes; overrides sasl-password")
 	fs.StringVar(&o.CACertLocation, prefix+"ca-cert-location", o.CACertLocation, "sets the location of the Kafka clusters' CA certificate")
 }
+
+// Complete reads the SASL password from SASLPasswordFile if it is set
+func (o *Options) Complete() []error {
+	if o.SASLPasswordFile == "" {
+		return nil
+	}
+	password, err := secrets.Read(o.SASLPasswordFile)
+	if err != nil {
+		return []error{fmt.Errorf("failed to load SASL password: %w", err)}
+	}
+	o.SASLPassword = password
+	return nil
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/project-kessel/inventory-consumer/internal/common"
@@ -33,3 +35,21 @@ func TestOptions_AddFlags(t *testing.T) {
 
 	common.AllOptionsHaveFlags(t, prefix, fs, *test.options, nil)
 }
+
+func TestOptions_Complete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &Options{SASLPassword: "from-config", SASLPasswordFile: path}
+	assert.Nil(t, options.Complete())
+	assert.Equal(t, "s3cret", options.SASLPassword)
+
+	options = &Options{SASLPassword: "from-config"}
+	assert.Nil(t, options.Complete())
+	assert.Equal(t, "from-config", options.SASLPassword)
+
+	options = &Options{SASLPasswordFile: filepath.Join(t.TempDir(), "missing")}
+	assert.Len(t, options.Complete(), 1)
+}
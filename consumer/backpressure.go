@@ -0,0 +1,32 @@
+package consumer
+
+import (
+	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
+)
+
+// throttlePartitions pauses the partitions whose worker queues are full so a slow partition never blocks the poll
+// loop, and resumes them once their queues have drained. Partitions paused for the open circuit breaker stay paused
+// until the breaker closes.
+func (i *InventoryConsumer) throttlePartitions() {
+	full, drained := i.Workers.Backpressure()
+	if len(full) > 0 {
+		if err := i.Consumer.Pause(full); err != nil {
+			metricscollector.Incr(i.MetricsCollector.ConsumerErrors, "PausePartitions", err)
+			i.Logger.Errorf("failed to pause partitions with full queues: %v", err)
+		} else {
+			i.Workers.SetThrottled(full, true)
+			i.Logger.Warnf("paused %d partition(s) until their queues drain: %v", len(full), full)
+		}
+	}
+	if len(drained) > 0 {
+		if !i.paused {
+			if err := i.Consumer.Resume(drained); err != nil {
+				metricscollector.Incr(i.MetricsCollector.ConsumerErrors, "ResumePartitions", err)
+				i.Logger.Errorf("failed to resume partitions with drained queues: %v", err)
+				return
+			}
+			i.Logger.Infof("resumed %d partition(s) with drained queues: %v", len(drained), drained)
+		}
+		i.Workers.SetThrottled(drained, false)
+	}
+}
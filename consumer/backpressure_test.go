@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventoryConsumer_ThrottlePartitions(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	release := make(chan struct{})
+	tester.inv.Workers = NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}, nil)
+	defer tester.inv.Workers.StopAll()
+
+	partitions := []kafka.TopicPartition{{Topic: ToPointer("test-topic"), Partition: 0}}
+	mockConsumer := &mocks.MockConsumer{}
+	mockConsumer.On("Pause", partitions).Return(nil).Once()
+	mockConsumer.On("Resume", partitions).Return(nil).Once()
+	tester.inv.Consumer = mockConsumer
+
+	for offset := 0; offset < partitionQueueSize; offset++ {
+		tester.inv.Workers.Dispatch(makeTestMessage(0, offset))
+	}
+	tester.inv.throttlePartitions()
+	tester.inv.throttlePartitions()
+	mockConsumer.AssertNumberOfCalls(t, "Pause", 1)
+	assert.True(t, tester.inv.Workers.IsThrottled(partitions[0]))
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		tester.inv.throttlePartitions()
+		return !tester.inv.Workers.IsThrottled(partitions[0])
+	}, 5*time.Second, 5*time.Millisecond)
+	mockConsumer.AssertExpectations(t)
+}
+
+func TestInventoryConsumer_ThrottledPartitionsStayPausedForBreaker(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	partitions := []kafka.TopicPartition{{Topic: ToPointer("test-topic"), Partition: 0}}
+	tester.inv.Workers.Start(partitions)
+	defer tester.inv.Workers.StopAll()
+	tester.inv.Workers.SetThrottled(partitions, true)
+	tester.inv.Consumer = &mocks.MockConsumer{}
+
+	// a drained partition is not resumed while the partitions are paused for the open circuit breaker
+	tester.inv.paused = true
+	tester.inv.throttlePartitions()
+	assert.False(t, tester.inv.Workers.IsThrottled(partitions[0]))
+}
@@ -0,0 +1,203 @@
+package batch
+
+import (
+	"sync"
+	"time"
+)
+
+// Item is a request queued to be sent in a batch
+type Item struct {
+	// Key identifies the resource of the request; requests for the same resource are never in the same batch, so they
+	// are sent in the order they were added
+	Key string
+	// Send sends the request, including any retries, and handles a failed request if it can; an error fails the batch
+	Send func() error
+	// Done is called once every request of the batch has been sent, with nil if they all succeeded or the first error
+	// returned by the batch otherwise
+	Done func(err error)
+}
+
+// Batcher accumulates requests and sends them concurrently once the batch is full or its oldest request has waited
+// for the maximum wait time. Batches are sent one at a time, in the order they were filled, by a sender goroutine so
+// adding a request never waits for other requests to be sent.
+type Batcher struct {
+	mu     sync.Mutex
+	items  []Item
+	keys   map[string]bool
+	timer  *time.Timer
+	closed bool
+	// generation identifies the open batch so the timer of a batch that was already queued does not queue the next one
+	generation uint64
+	// queue holds the filled batches the sender has not taken yet; queued and sent count the batches so Flush can
+	// wait for those queued before it
+	queue  [][]Item
+	queued uint64
+	sent   uint64
+	cond   *sync.Cond
+	signal chan struct{}
+	stop   chan struct{}
+
+	maxSize     int
+	maxWait     time.Duration
+	concurrency int
+}
+
+// New returns a Batcher using the size, wait time and concurrency limits of options, and starts its sender
+func New(options *Options) *Batcher {
+	b := &Batcher{
+		keys:        make(map[string]bool),
+		signal:      make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		maxSize:     max(options.MaxSize, 1),
+		maxWait:     time.Duration(options.MaxWaitMs) * time.Millisecond,
+		concurrency: max(options.Concurrency, 1),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	go b.run()
+	return b
+}
+
+// Add queues a request without waiting for it to be sent. If the batch already has a request for the same key, the
+// batch is queued for sending first and the request starts the next one.
+// Requests added after Close are sent immediately.
+func (b *Batcher) Add(item Item) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		send([]Item{item}, 1)
+		return
+	}
+	defer b.mu.Unlock()
+
+	if b.keys[item.Key] {
+		b.queueLocked()
+	}
+	b.items = append(b.items, item)
+	b.keys[item.Key] = true
+	if len(b.items) >= b.maxSize {
+		b.queueLocked()
+	} else if len(b.items) == 1 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.maxWait, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.generation == generation {
+				b.queueLocked()
+			}
+		})
+	}
+}
+
+// Flush queues the open batch and waits until it and every batch queued before it has been sent
+func (b *Batcher) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queueLocked()
+	target := b.queued
+	for b.sent < target {
+		b.cond.Wait()
+	}
+}
+
+// Len returns the number of requests that have not been sent yet, excluding those of the batch being sent
+func (b *Batcher) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.items)
+	for _, items := range b.queue {
+		n += len(items)
+	}
+	return n
+}
+
+// Close sends the queued requests and stops the sender; requests added afterwards are sent without batching
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	b.mu.Unlock()
+	b.Flush()
+	close(b.stop)
+}
+
+// queueLocked hands the open batch to the sender and starts a new one; b.mu must be held
+func (b *Batcher) queueLocked() {
+	b.generation++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.items) == 0 {
+		return
+	}
+	b.queue = append(b.queue, b.items)
+	b.queued++
+	b.items = nil
+	b.keys = make(map[string]bool)
+	select {
+	case b.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the queued batches in order until the Batcher is closed
+func (b *Batcher) run() {
+	for {
+		b.mu.Lock()
+		if len(b.queue) > 0 {
+			items := b.queue[0]
+			b.queue[0] = nil
+			b.queue = b.queue[1:]
+			b.mu.Unlock()
+
+			send(items, b.concurrency)
+
+			b.mu.Lock()
+			b.sent++
+			b.cond.Broadcast()
+			b.mu.Unlock()
+			continue
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.signal:
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// send sends the requests with at most concurrency requests in flight, then calls Done for each of them with the
+// result of the batch
+func send(items []Item, concurrency int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed error
+	slots := make(chan struct{}, concurrency)
+	for _, item := range items {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := item.Send(); err != nil {
+				mu.Lock()
+				if failed == nil {
+					failed = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, item := range items {
+		if item.Done != nil {
+			item.Done(failed)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the requests sent by a Batcher and their results
+type recorder struct {
+	mu      sync.Mutex
+	sent    []string
+	results map[string]error
+}
+
+func newRecorder() *recorder {
+	return &recorder{results: make(map[string]error)}
+}
+
+func (r *recorder) item(key, name string, err error) Item {
+	return Item{
+		Key: key,
+		Send: func() error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.sent = append(r.sent, name)
+			return err
+		},
+		Done: func(err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.results[name] = err
+		},
+	}
+}
+
+func (r *recorder) sentCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sent)
+}
+
+func TestBatcher_FlushesWhenFull(t *testing.T) {
+	r := newRecorder()
+	b := New(&Options{MaxSize: 3, MaxWaitMs: 60000, Concurrency: 2})
+	defer b.Close()
+
+	b.Add(r.item("a", "a", nil))
+	b.Add(r.item("b", "b", nil))
+	assert.Equal(t, 0, r.sentCount())
+	assert.Equal(t, 2, b.Len())
+
+	b.Add(r.item("c", "c", nil))
+	assert.Eventually(t, func() bool { return r.sentCount() == 3 }, 5*time.Second, 5*time.Millisecond)
+	b.Flush()
+	assert.Equal(t, 0, b.Len())
+	assert.Len(t, r.results, 3)
+}
+
+func TestBatcher_FlushesAfterMaxWait(t *testing.T) {
+	r := newRecorder()
+	b := New(&Options{MaxSize: 100, MaxWaitMs: 10, Concurrency: 2})
+	defer b.Close()
+
+	b.Add(r.item("a", "a", nil))
+	assert.Eventually(t, func() bool { return r.sentCount() == 1 }, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestBatcher_SameKeyIsSentInOrder(t *testing.T) {
+	r := newRecorder()
+	b := New(&Options{MaxSize: 100, MaxWaitMs: 60000, Concurrency: 10})
+
+	b.Add(r.item("a", "a1", nil))
+	b.Add(r.item("b", "b1", nil))
+	// a second request for the same key sends the batch holding the first one
+	b.Add(r.item("a", "a2", nil))
+	assert.Eventually(t, func() bool { return r.sentCount() == 2 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 1, b.Len())
+
+	b.Close()
+	assert.Equal(t, "a2", r.sent[2])
+}
+
+func TestBatcher_FailedRequestFailsBatch(t *testing.T) {
+	r := newRecorder()
+	b := New(&Options{MaxSize: 2, MaxWaitMs: 60000, Concurrency: 2})
+	failed := errors.New("invalid host")
+
+	// every request of the batch is sent before Done reports the failure for all of them
+	b.Add(r.item("a", "a", failed))
+	b.Add(r.item("b", "b", nil))
+	b.Flush()
+	assert.Equal(t, 2, r.sentCount())
+	assert.Equal(t, failed, r.results["a"])
+	assert.Equal(t, failed, r.results["b"])
+
+	b.Add(r.item("c", "c", nil))
+	b.Add(r.item("d", "d", nil))
+	b.Flush()
+	assert.Nil(t, r.results["c"])
+	assert.Nil(t, r.results["d"])
+}
+
+func TestBatcher_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	b := New(&Options{MaxSize: 20, MaxWaitMs: 60000, Concurrency: 3})
+	for n := range 20 {
+		b.Add(Item{
+			Key: fmt.Sprint(n),
+			Send: func() error {
+				current := inFlight.Add(1)
+				for {
+					observed := maxInFlight.Load()
+					if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				inFlight.Add(-1)
+				return nil
+			},
+		})
+	}
+	b.Flush()
+	assert.Equal(t, 0, b.Len())
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+	assert.Greater(t, maxInFlight.Load(), int32(1))
+}
+
+func TestBatcher_AddDoesNotWaitForSend(t *testing.T) {
+	release := make(chan struct{})
+	b := New(&Options{MaxSize: 1, MaxWaitMs: 60000, Concurrency: 1})
+	defer b.Close()
+
+	// the first batch is held up by a slow request while later requests are queued behind it
+	added := make(chan struct{})
+	go func() {
+		b.Add(Item{Key: "a", Send: func() error {
+			<-release
+			return nil
+		}})
+		b.Add(Item{Key: "b", Send: func() error { return nil }})
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add waited for a batch to be sent")
+	}
+	close(release)
+}
+
+func TestBatcher_Close(t *testing.T) {
+	r := newRecorder()
+	b := New(&Options{MaxSize: 100, MaxWaitMs: 60000, Concurrency: 2})
+
+	b.Add(r.item("a", "a", nil))
+	b.Close()
+	assert.Equal(t, 1, r.sentCount())
+
+	// requests added after Close are sent immediately
+	b.Add(r.item("b", "b", nil))
+	assert.Equal(t, 2, r.sentCount())
+}
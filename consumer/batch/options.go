@@ -0,0 +1,45 @@
+package batch
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+type Options struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MaxSize     int  `mapstructure:"max-size"`
+	MaxWaitMs   int  `mapstructure:"max-wait-ms"`
+	Concurrency int  `mapstructure:"concurrency"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Enabled:     false,
+		MaxSize:     100,
+		MaxWaitMs:   1000,
+		Concurrency: 10,
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
+	if prefix != "" {
+		prefix = prefix + "."
+	}
+	fs.BoolVar(&o.Enabled, prefix+"enabled", o.Enabled, "send the requests of migration messages in batches (default: false)")
+	fs.IntVar(&o.MaxSize, prefix+"max-size", o.MaxSize, "number of requests that are sent as a batch (default: 100)")
+	fs.IntVar(&o.MaxWaitMs, prefix+"max-wait-ms", o.MaxWaitMs, "time in milliseconds a request waits for its batch to fill before it is sent (default: 1000)")
+	fs.IntVar(&o.Concurrency, prefix+"concurrency", o.Concurrency, "number of requests of a batch that are sent at once (default: 10)")
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+
+	if !o.Enabled {
+		return errs
+	}
+	if o.MaxSize < 1 || o.MaxWaitMs < 1 || o.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("batch max size, max wait and concurrency must be at least 1"))
+	}
+	return errs
+}
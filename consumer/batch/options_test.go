@@ -0,0 +1,67 @@
+package batch
+
+import (
+	"testing"
+
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	expectedOptions := &Options{
+		Enabled:     false,
+		MaxSize:     100,
+		MaxWaitMs:   1000,
+		Concurrency: 10,
+	}
+	assert.Equal(t, expectedOptions, NewOptions())
+}
+
+func TestOptions_AddFlags(t *testing.T) {
+	options := NewOptions()
+	prefix := "consumer.batch"
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	options.AddFlags(fs, prefix)
+
+	common.AllOptionsHaveFlags(t, prefix, fs, *options, nil)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *Options
+		expectError bool
+	}{
+		{
+			name:        "default options are valid",
+			options:     NewOptions(),
+			expectError: false,
+		},
+		{
+			name:        "disabled options are not validated",
+			options:     &Options{Enabled: false},
+			expectError: false,
+		},
+		{
+			name:        "max size must be positive",
+			options:     &Options{Enabled: true, MaxSize: 0, MaxWaitMs: 1000, Concurrency: 10},
+			expectError: true,
+		},
+		{
+			name:        "concurrency must be positive",
+			options:     &Options{Enabled: true, MaxSize: 100, MaxWaitMs: 1000, Concurrency: 0},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate()
+			if test.expectError {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/batch"
+	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
+)
+
+// errDeferred is returned by handlers whose request was queued in a batch instead of being sent
+var errDeferred = errors.New("request queued in a batch")
+
+// addToBatch queues the request of a migration message to be sent with the next batch. A request that fails is
+// dead-lettered like any other failed message, whatever the error, so one bad resource does not fail the whole batch.
+// The offsets of the batch are only completed once every request in it has succeeded or been dead-lettered; if one
+// could not be, the partitions of the batch stop before any of its offsets are committed.
+func (i *InventoryConsumer) addToBatch(ctx context.Context, msg *kafka.Message, send func() (interface{}, error)) error {
+	done := i.Workers.Defer(msg)
+	i.Batcher.Add(batch.Item{
+		Key: batchKey(msg),
+		Send: func() error {
+			for {
+				// requests of partitions that were stopped before the batch was sent are re-read by the next owner of
+				// the partition, so they do not fail the batch
+				if ctx.Err() != nil {
+					return nil
+				}
+				_, err := send()
+				// requests rejected by the open circuit breaker hold up the batch until it lets requests through again
+				if i.waitForBreaker(ctx, err) {
+					continue
+				}
+				if err == nil || ctx.Err() != nil {
+					return nil
+				}
+
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ProcessMigrationResource", err)
+				i.Logger.Errorf("failed to process batched migration resource: topic=%s partition=%d offset=%s: %v",
+					*msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset, err)
+				var failure *UnprocessableError
+				if !errors.As(err, &failure) {
+					err = NewUnprocessableError("ProcessMigrationResource", err)
+				}
+				return i.handleUnprocessable(ctx, msg, err)
+			}
+		},
+		Done: func(err error) {
+			// offsets of stopped partitions are not completed
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				metricscollector.Incr(i.MetricsCollector.MsgsProcessed, OperationTypeMigration, nil)
+				i.Logger.Infof("consumed batched event from topic %s, partition %d at offset %s",
+					*msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset)
+			}
+			done(err)
+		},
+	})
+	return errDeferred
+}
+
+// batchKey identifies the resource of a message so requests for the same resource are never sent in the same batch
+func batchKey(msg *kafka.Message) string {
+	var topic string
+	if msg.TopicPartition.Topic != nil {
+		topic = *msg.TopicPartition.Topic
+	}
+	return topic + "/" + messageKeyID(msg.Key)
+}
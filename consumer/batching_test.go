@@ -0,0 +1,153 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/project-kessel/inventory-consumer/consumer/batch"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func makeMigrationMessage(offset int, id string) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(offset)},
+		Headers: []kafka.Header{
+			{Key: "operation", Value: []byte(OperationTypeMigration)},
+			{Key: "version", Value: []byte(APIVersionV1Beta2)},
+		},
+		Key:   []byte(`{"payload":{"id":"` + id + `"}}`),
+		Value: []byte(testMigrationMessage),
+	}
+}
+
+func TestInventoryConsumer_BatchedMigration(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+	tester.inv.Client = client
+	tester.inv.Batcher = batch.New(&batch.Options{MaxSize: 100, MaxWaitMs: 60000, Concurrency: 2})
+	tester.inv.Workers = NewPartitionWorkers(1, tester.inv.processPartitionMessage, tester.inv.storeProcessedOffset)
+	defer tester.inv.Workers.StopAll()
+
+	tester.inv.Workers.Dispatch(makeMigrationMessage(0, "host-1"))
+	tester.inv.Workers.Dispatch(makeMigrationMessage(1, "host-2"))
+	assert.Eventually(t, func() bool { return tester.inv.Batcher.Len() == 2 }, 5*time.Second, 5*time.Millisecond)
+
+	// offsets are not stored for commit until the batch has been sent
+	client.AssertNotCalled(t, "CreateOrUpdateResource", mock.Anything, mock.Anything)
+	assert.Equal(t, 0, tester.inv.OffsetStorage.Len())
+
+	tester.inv.Batcher.Flush()
+	client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 2)
+	assert.Equal(t, []kafka.TopicPartition{{Topic: ToPointer("test-topic"), Partition: 0, Offset: 1}}, tester.inv.OffsetStorage.Pending())
+}
+
+func TestInventoryConsumer_BatchedMigrationFailureIsDeadLettered(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, status.Error(codes.InvalidArgument, "invalid host")).Once()
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+	tester.inv.Client = client
+	tester.inv.Batcher = batch.New(&batch.Options{MaxSize: 2, MaxWaitMs: 60000, Concurrency: 1})
+	tester.inv.Workers = NewPartitionWorkers(1, tester.inv.processPartitionMessage, tester.inv.storeProcessedOffset)
+	defer tester.inv.Workers.StopAll()
+
+	producer := &mocks.MockProducer{}
+	producer.On("Produce", mock.Anything, mock.Anything).Return(nil).Once()
+	tester.inv.Producer = producer
+	tester.inv.Config.DeadLetterTopic = "test-topic.dlq"
+
+	// one bad host is dead-lettered without failing the rest of the batch
+	tester.inv.Workers.Dispatch(makeMigrationMessage(0, "host-1"))
+	tester.inv.Workers.Dispatch(makeMigrationMessage(1, "host-2"))
+	assert.Eventually(t, func() bool {
+		pending := tester.inv.OffsetStorage.Pending()
+		return len(pending) == 1 && pending[0].Offset == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	producer.AssertExpectations(t)
+
+	select {
+	case err := <-tester.inv.Workers.Errors():
+		t.Fatalf("unexpected worker error: %v", err)
+	default:
+	}
+}
+
+func TestInventoryConsumer_BatchedMigrationExhaustedRetriesAreDeadLettered(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+	tester.inv.RetryOptions.BackoffFactor = 0
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, status.Error(codes.Unavailable, "unavailable"))
+	tester.inv.Client = client
+	tester.inv.Batcher = batch.New(&batch.Options{MaxSize: 1, MaxWaitMs: 60000, Concurrency: 1})
+	tester.inv.Workers = NewPartitionWorkers(1, tester.inv.processPartitionMessage, tester.inv.storeProcessedOffset)
+	defer tester.inv.Workers.StopAll()
+
+	producer := &mocks.MockProducer{}
+	producer.On("Produce", mock.Anything, mock.Anything).Return(nil).Once()
+	tester.inv.Producer = producer
+	tester.inv.Config.DeadLetterTopic = "test-topic.dlq"
+
+	// failures that are not terminal are dead-lettered too instead of stopping the partition
+	tester.inv.Workers.Dispatch(makeMigrationMessage(0, "host-1"))
+	assert.Eventually(t, func() bool { return tester.inv.OffsetStorage.Len() == 1 }, 5*time.Second, 5*time.Millisecond)
+	producer.AssertExpectations(t)
+
+	select {
+	case err := <-tester.inv.Workers.Errors():
+		t.Fatalf("unexpected worker error: %v", err)
+	default:
+	}
+}
+
+func TestInventoryConsumer_BatchedMigrationFailureHoldsBatchOffsets(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, status.Error(codes.InvalidArgument, "invalid host")).Once()
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+	tester.inv.Client = client
+	tester.inv.Batcher = batch.New(&batch.Options{MaxSize: 2, MaxWaitMs: 60000, Concurrency: 1})
+	tester.inv.Workers = NewPartitionWorkers(1, tester.inv.processPartitionMessage, tester.inv.storeProcessedOffset)
+	defer tester.inv.Workers.StopAll()
+
+	// without a dead-letter topic the failure fails the batch, so the offset of the request that succeeded is not
+	// committed either
+	tester.inv.Workers.Dispatch(makeMigrationMessage(0, "host-1"))
+	tester.inv.Workers.Dispatch(makeMigrationMessage(1, "host-2"))
+	select {
+	case err := <-tester.inv.Workers.Errors():
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch failure was not reported")
+	}
+	client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 2)
+	assert.Equal(t, 0, tester.inv.OffsetStorage.Len())
+}
+
+func TestBatchKey(t *testing.T) {
+	assert.Equal(t, "test-topic/host-1", batchKey(makeMigrationMessage(0, "host-1")))
+	assert.NotEqual(t, batchKey(makeMigrationMessage(0, "host-1")), batchKey(makeMigrationMessage(0, "host-2")))
+}
@@ -0,0 +1,190 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// halfOpenWait is how long callers wait for a probe in flight before checking the breaker again
+const halfOpenWait = time.Second
+
+// ErrOpen is returned instead of sending a request while the circuit breaker is open
+var ErrOpen = errors.New("circuit breaker is open: Inventory API is unavailable")
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// Closed lets every request through
+	Closed State = iota
+	// HalfOpen lets a single probe request through to check if Inventory API has recovered
+	HalfOpen
+	// Open rejects every request until the open duration has passed
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker that opens after consecutive failed requests, rejects requests while open, and closes
+// again once probe requests succeed
+type Breaker struct {
+	mu        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	probing   bool
+	openedAt  time.Time
+
+	failureThreshold  int
+	openDuration      time.Duration
+	halfOpenSuccesses int
+	onStateChange     func(State)
+	now               func() time.Time
+}
+
+// New returns a closed Breaker; onStateChange, if set, is called with the new state on every transition
+func New(options *Options, onStateChange func(State)) *Breaker {
+	return &Breaker{
+		state:             Closed,
+		failureThreshold:  max(options.FailureThreshold, 1),
+		openDuration:      time.Duration(options.OpenSeconds) * time.Second,
+		halfOpenSuccesses: max(options.HalfOpenSuccesses, 1),
+		onStateChange:     onStateChange,
+		now:               time.Now,
+	}
+}
+
+// State returns the current state, moving an open breaker to half-open once the open duration has passed
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfElapsed()
+	return b.state
+}
+
+// Allow returns ErrOpen if a request can not be sent now. In the half-open state, only one probe is allowed at a time.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfElapsed()
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record records the outcome of an allowed request. Canceled requests are not counted, but a canceled probe lets
+// another probe through.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if isCanceled(err) {
+		b.probing = false
+		return
+	}
+	failed := IsFailure(err)
+
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+		if failed {
+			b.transition(Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.halfOpenSuccesses {
+			b.transition(Closed)
+		}
+	case Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.transition(Open)
+		}
+	}
+}
+
+// RetryAfter returns how long to wait before a request may be allowed again, or 0 if one may be allowed now
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfElapsed()
+	switch {
+	case b.state == Open:
+		return b.openDuration - b.now().Sub(b.openedAt)
+	case b.state == HalfOpen && b.probing:
+		return halfOpenWait
+	default:
+		return 0
+	}
+}
+
+// halfOpenIfElapsed moves an open breaker to half-open once the open duration has passed; b.mu must be held
+func (b *Breaker) halfOpenIfElapsed() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.openDuration {
+		b.transition(HalfOpen)
+	}
+}
+
+// transition changes the state and resets the counters for it; b.mu must be held
+func (b *Breaker) transition(state State) {
+	b.state = state
+	b.failures, b.successes, b.probing = 0, 0, false
+	if state == Open {
+		b.openedAt = b.now()
+	}
+	if b.onStateChange != nil {
+		b.onStateChange(state)
+	}
+}
+
+// IsFailure returns true if a request error indicates Inventory API is unavailable, rather than rejecting the request
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCanceled returns true if a request was canceled by the caller, such as when the partition is revoked
+func isCanceled(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Canceled
+}
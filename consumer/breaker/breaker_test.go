@@ -0,0 +1,136 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "connection refused")
+
+// newTestBreaker returns a breaker with a clock that only moves when advanced, and records its state changes
+func newTestBreaker(options *Options) (*Breaker, *time.Time, *[]State) {
+	var states []State
+	b := New(options, func(state State) { states = append(states, state) })
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	return b, &now, &states
+}
+
+func TestBreaker_Transitions(t *testing.T) {
+	b, now, states := newTestBreaker(&Options{FailureThreshold: 2, OpenSeconds: 10, HalfOpenSuccesses: 2})
+
+	// failures below the threshold, or interrupted by a success, keep the breaker closed
+	assert.Nil(t, b.Allow())
+	b.Record(errUnavailable)
+	b.Record(nil)
+	b.Record(errUnavailable)
+	assert.Equal(t, Closed, b.State())
+
+	b.Record(errUnavailable)
+	assert.Equal(t, Open, b.State())
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	assert.Equal(t, 10*time.Second, b.RetryAfter())
+
+	*now = now.Add(10 * time.Second)
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Equal(t, time.Duration(0), b.RetryAfter())
+
+	// a single probe is allowed at a time
+	assert.Nil(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	assert.Equal(t, halfOpenWait, b.RetryAfter())
+
+	// a failed probe opens the breaker again
+	b.Record(errUnavailable)
+	assert.Equal(t, Open, b.State())
+
+	*now = now.Add(10 * time.Second)
+	assert.Nil(t, b.Allow())
+	b.Record(nil)
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Nil(t, b.Allow())
+	b.Record(nil)
+	assert.Equal(t, Closed, b.State())
+
+	assert.Equal(t, []State{Open, HalfOpen, Open, HalfOpen, Closed}, *states)
+}
+
+func TestBreaker_CanceledRequestsAreNotCounted(t *testing.T) {
+	b, now, _ := newTestBreaker(&Options{FailureThreshold: 1, OpenSeconds: 10, HalfOpenSuccesses: 1})
+
+	b.Record(context.Canceled)
+	b.Record(status.Error(codes.Canceled, "context canceled"))
+	assert.Equal(t, Closed, b.State())
+
+	// a canceled probe lets another probe through without closing the breaker
+	b.Record(errUnavailable)
+	*now = now.Add(10 * time.Second)
+	assert.Nil(t, b.Allow())
+	b.Record(context.Canceled)
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Nil(t, b.Allow())
+}
+
+func TestIsFailure(t *testing.T) {
+	assert.False(t, IsFailure(nil))
+	assert.True(t, IsFailure(errors.New("connection reset")))
+	assert.True(t, IsFailure(errUnavailable))
+	assert.True(t, IsFailure(status.Error(codes.DeadlineExceeded, "timeout")))
+	// requests rejected by Inventory API show it is available
+	assert.False(t, IsFailure(status.Error(codes.InvalidArgument, "invalid resource")))
+	assert.False(t, IsFailure(status.Error(codes.NotFound, "not found")))
+}
+
+func TestClient(t *testing.T) {
+	b, _, _ := newTestBreaker(&Options{FailureThreshold: 1, OpenSeconds: 10, HalfOpenSuccesses: 1})
+	inner := &mocks.MockClient{}
+	inner.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, errUnavailable).Once()
+	client := NewClient(inner, b)
+
+	_, err := client.CreateOrUpdateResource(context.Background(), &v1beta2.ReportResourceRequest{})
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Equal(t, Open, b.State())
+
+	// requests are rejected without calling Inventory API while the breaker is open
+	_, err = client.CreateOrUpdateResource(context.Background(), &v1beta2.ReportResourceRequest{})
+	assert.ErrorIs(t, err, ErrOpen)
+	_, err = client.DeleteResource(context.Background(), &v1beta2.DeleteResourceRequest{})
+	assert.ErrorIs(t, err, ErrOpen)
+	inner.AssertExpectations(t)
+}
+
+// failingConn fails every request with errUnavailable and counts the requests sent
+type failingConn struct {
+	grpc.ClientConnInterface
+	calls int
+}
+
+func (f *failingConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	f.calls++
+	return errUnavailable
+}
+
+func TestConn(t *testing.T) {
+	b, _, _ := newTestBreaker(&Options{FailureThreshold: 1, OpenSeconds: 10, HalfOpenSuccesses: 1})
+	inner := &failingConn{}
+	conn := NewConn(inner, b)
+
+	assert.ErrorIs(t, conn.Invoke(context.Background(), "/test/Check", nil, nil), errUnavailable)
+	assert.Equal(t, Open, b.State())
+
+	// requests are rejected without calling Inventory API while the breaker is open
+	assert.ErrorIs(t, conn.Invoke(context.Background(), "/test/Check", nil, nil), ErrOpen)
+	_, err := conn.NewStream(context.Background(), &grpc.StreamDesc{}, "/test/Watch")
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, 1, inner.calls)
+}
@@ -0,0 +1,36 @@
+package breaker
+
+import (
+	"context"
+
+	kessel "github.com/project-kessel/inventory-consumer/internal/client"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+)
+
+// Client wraps a ClientProvider so requests are rejected with ErrOpen while the circuit breaker is open
+type Client struct {
+	kessel.ClientProvider
+	breaker *Breaker
+}
+
+func NewClient(client kessel.ClientProvider, breaker *Breaker) *Client {
+	return &Client{ClientProvider: client, breaker: breaker}
+}
+
+func (c *Client) CreateOrUpdateResource(ctx context.Context, request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := c.ClientProvider.CreateOrUpdateResource(ctx, request)
+	c.breaker.Record(err)
+	return resp, err
+}
+
+func (c *Client) DeleteResource(ctx context.Context, request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := c.ClientProvider.DeleteResource(ctx, request)
+	c.breaker.Record(err)
+	return resp, err
+}
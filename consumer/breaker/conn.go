@@ -0,0 +1,36 @@
+package breaker
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// Conn wraps a connection to Inventory API so requests are rejected with ErrOpen while the circuit breaker is open
+// It is used by clients for API versions other than v1beta2, which Client does not wrap
+type Conn struct {
+	grpc.ClientConnInterface
+	breaker *Breaker
+}
+
+func NewConn(conn grpc.ClientConnInterface, breaker *Breaker) *Conn {
+	return &Conn{ClientConnInterface: conn, breaker: breaker}
+}
+
+func (c *Conn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	if err := c.breaker.Allow(); err != nil {
+		return err
+	}
+	err := c.ClientConnInterface.Invoke(ctx, method, args, reply, opts...)
+	c.breaker.Record(err)
+	return err
+}
+
+func (c *Conn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	stream, err := c.ClientConnInterface.NewStream(ctx, desc, method, opts...)
+	c.breaker.Record(err)
+	return stream, err
+}
@@ -0,0 +1,48 @@
+package breaker
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+type Options struct {
+	Enabled           bool `mapstructure:"enabled"`
+	FailureThreshold  int  `mapstructure:"failure-threshold"`
+	OpenSeconds       int  `mapstructure:"open-seconds"`
+	HalfOpenSuccesses int  `mapstructure:"half-open-successes"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Enabled:           false,
+		FailureThreshold:  5,
+		OpenSeconds:       30,
+		HalfOpenSuccesses: 1,
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
+	if prefix != "" {
+		prefix = prefix + "."
+	}
+	fs.BoolVar(&o.Enabled, prefix+"enabled", o.Enabled, "pauses consumption while Inventory API is unavailable (default: false)")
+	fs.IntVar(&o.FailureThreshold, prefix+"failure-threshold", o.FailureThreshold, "consecutive failed requests that open the circuit breaker (default: 5)")
+	fs.IntVar(&o.OpenSeconds, prefix+"open-seconds", o.OpenSeconds, "time the circuit breaker stays open before probing Inventory API again (default: 30)")
+	fs.IntVar(&o.HalfOpenSuccesses, prefix+"half-open-successes", o.HalfOpenSuccesses, "successful probes needed to close the circuit breaker (default: 1)")
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+
+	if !o.Enabled {
+		return errs
+	}
+	if o.FailureThreshold < 1 || o.HalfOpenSuccesses < 1 {
+		errs = append(errs, fmt.Errorf("circuit breaker failure threshold and half-open successes must be at least 1"))
+	}
+	if o.OpenSeconds < 1 {
+		errs = append(errs, fmt.Errorf("circuit breaker open seconds must be at least 1"))
+	}
+	return errs
+}
@@ -0,0 +1,72 @@
+package breaker
+
+import (
+	"testing"
+
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	expectedOptions := &Options{
+		Enabled:           false,
+		FailureThreshold:  5,
+		OpenSeconds:       30,
+		HalfOpenSuccesses: 1,
+	}
+	assert.Equal(t, expectedOptions, NewOptions())
+}
+
+func TestOptions_AddFlags(t *testing.T) {
+	options := NewOptions()
+	prefix := "consumer.circuit-breaker"
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	options.AddFlags(fs, prefix)
+
+	common.AllOptionsHaveFlags(t, prefix, fs, *options, nil)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *Options
+		expectError bool
+	}{
+		{
+			name:        "default options are valid",
+			options:     NewOptions(),
+			expectError: false,
+		},
+		{
+			name:        "enabled default options are valid",
+			options:     &Options{Enabled: true, FailureThreshold: 5, OpenSeconds: 30, HalfOpenSuccesses: 1},
+			expectError: false,
+		},
+		{
+			name:        "disabled options are not validated",
+			options:     &Options{Enabled: false},
+			expectError: false,
+		},
+		{
+			name:        "failure threshold must be positive",
+			options:     &Options{Enabled: true, FailureThreshold: 0, OpenSeconds: 30, HalfOpenSuccesses: 1},
+			expectError: true,
+		},
+		{
+			name:        "open seconds must be positive",
+			options:     &Options{Enabled: true, FailureThreshold: 5, OpenSeconds: 0, HalfOpenSuccesses: 1},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate()
+			if test.expectError {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
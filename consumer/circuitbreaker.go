@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/breaker"
+	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
+)
+
+// waitForBreaker is called by the partition workers when processing a message fails. Messages rejected by the open
+// circuit breaker wait until it lets requests through again and are processed again, instead of stopping the partition
+// and restarting the consumer. It returns false for other errors, or if the partition is stopped while waiting.
+func (i *InventoryConsumer) waitForBreaker(ctx context.Context, err error) bool {
+	if i.Breaker == nil || !errors.Is(err, breaker.ErrOpen) {
+		return false
+	}
+	return sleep(ctx, max(i.Breaker.RetryAfter(), 0)) == nil
+}
+
+// pauseWhileBreakerOpen pauses the assigned partitions while the circuit breaker is open so no more messages are
+// fetched, and resumes them once it closes. While the breaker is half-open, the messages that were rejected while it
+// was open are sent as probes; the partitions are only resumed early if none are left, so a new message can probe it.
+func (i *InventoryConsumer) pauseWhileBreakerOpen() {
+	if i.Breaker == nil {
+		return
+	}
+	state := i.Breaker.State()
+	open := state == breaker.Open || (state == breaker.HalfOpen && i.Workers.InFlight() > 0)
+	if open == i.paused {
+		return
+	}
+
+	partitions, err := i.Consumer.Assignment()
+	if err != nil {
+		metricscollector.Incr(i.MetricsCollector.ConsumerErrors, "Assignment", err)
+		i.Logger.Errorf("failed to get assigned partitions: %v", err)
+		return
+	}
+	if open {
+		err = i.Consumer.Pause(partitions)
+	} else {
+		// partitions with full queues stay paused until their queues drain
+		var resume []kafka.TopicPartition
+		for _, tp := range partitions {
+			if !i.Workers.IsThrottled(tp) {
+				resume = append(resume, tp)
+			}
+		}
+		if len(resume) > 0 {
+			err = i.Consumer.Resume(resume)
+		}
+	}
+	if err != nil {
+		metricscollector.Incr(i.MetricsCollector.ConsumerErrors, "PausePartitions", err)
+		i.Logger.Errorf("failed to pause or resume partitions: %v", err)
+		return
+	}
+	i.paused = open
+	if open {
+		i.Logger.Warnf("circuit breaker is %s: paused %d partition(s)", state, len(partitions))
+	} else {
+		i.Logger.Infof("circuit breaker is %s: resumed %d partition(s)", state, len(partitions))
+	}
+}
@@ -0,0 +1,166 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/breaker"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// setupBreakerTest returns a consumer whose client is wrapped by a circuit breaker that opens after a single failure
+func setupBreakerTest(t *testing.T, client *mocks.MockClient) *TestCase {
+	tester := &TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	tester.inv.Breaker = breaker.New(&breaker.Options{Enabled: true, FailureThreshold: 1, OpenSeconds: 1, HalfOpenSuccesses: 1}, nil)
+	tester.inv.Client = breaker.NewClient(client, tester.inv.Breaker)
+	return tester
+}
+
+func TestNew_CircuitBreaker(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+	// the circuit breaker is disabled by default
+	assert.Nil(t, tester.inv.Breaker)
+
+	tester.options.CircuitBreakerOptions.Enabled = true
+	cfg, errList := NewConfig(tester.options).Complete()
+	assert.Nil(t, errList)
+	inv, err := New(cfg, nil, tester.logger, &mocks.MockConsumer{})
+	assert.Nil(t, err)
+	assert.NotNil(t, inv.Breaker)
+	assert.Equal(t, breaker.Closed, inv.Breaker.State())
+}
+
+func TestInventoryConsumer_RetryStopsWhenBreakerOpen(t *testing.T) {
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, status.Error(codes.Unavailable, "connection refused"))
+	tester := setupBreakerTest(t, client)
+	tester.inv.RetryOptions.BackoffFactor = 0
+
+	err := tester.inv.ProcessMessage(context.Background(), EventHeaders{Operation: OperationTypeReportResource, Version: APIVersionV1Beta2}, &kafka.Message{
+		Value: []byte(testCreateOrUpdateMessage),
+	})
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+	// the request that opened the breaker is the only one sent to Inventory API
+	client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 1)
+}
+
+func TestInventoryConsumer_WaitForBreaker(t *testing.T) {
+	client := &mocks.MockClient{}
+	tester := setupBreakerTest(t, client)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// other errors stop the partition as before
+	assert.False(t, tester.inv.waitForBreaker(ctx, errors.New("processing failed")))
+
+	// messages rejected by the open breaker wait for it to let a probe through
+	tester.inv.Breaker.Record(errors.New("connection refused"))
+	start := time.Now()
+	assert.True(t, tester.inv.waitForBreaker(ctx, breaker.ErrOpen))
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	assert.Equal(t, breaker.HalfOpen, tester.inv.Breaker.State())
+
+	// waiting ends when the partition is stopped
+	assert.Nil(t, tester.inv.Breaker.Allow())
+	cancel()
+	assert.False(t, tester.inv.waitForBreaker(ctx, breaker.ErrOpen))
+}
+
+func TestInventoryConsumer_PauseWhileBreakerOpen(t *testing.T) {
+	client := &mocks.MockClient{}
+	tester := setupBreakerTest(t, client)
+	partitions := []kafka.TopicPartition{{Topic: &tester.completedConfig.Topics[0], Partition: 0}}
+
+	// a message rejected by the open breaker is still being processed, so it probes the half-open breaker
+	release := make(chan struct{})
+	tester.inv.Workers = NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		<-release
+		return nil
+	}, nil)
+	defer tester.inv.Workers.StopAll()
+	defer close(release)
+	tester.inv.Workers.Dispatch(&kafka.Message{TopicPartition: kafka.TopicPartition{Topic: partitions[0].Topic, Partition: 0, Offset: 0}})
+
+	mockConsumer := &mocks.MockConsumer{}
+	mockConsumer.On("Assignment").Return(partitions, nil)
+	mockConsumer.On("Pause", partitions).Return(nil).Once()
+	mockConsumer.On("Resume", partitions).Return(nil).Once()
+	tester.inv.Consumer = mockConsumer
+
+	// nothing is paused while the breaker is closed
+	tester.inv.pauseWhileBreakerOpen()
+	mockConsumer.AssertNotCalled(t, "Assignment")
+
+	tester.inv.Breaker.Record(errors.New("connection refused"))
+	tester.inv.pauseWhileBreakerOpen()
+	tester.inv.pauseWhileBreakerOpen()
+	mockConsumer.AssertNumberOfCalls(t, "Pause", 1)
+	assert.True(t, tester.inv.paused)
+
+	// the partitions stay paused while the breaker is half-open
+	assert.Eventually(t, func() bool {
+		return tester.inv.Breaker.State() == breaker.HalfOpen
+	}, 5*time.Second, 50*time.Millisecond)
+	tester.inv.pauseWhileBreakerOpen()
+	assert.True(t, tester.inv.paused)
+	mockConsumer.AssertNotCalled(t, "Resume", mock.Anything)
+
+	// and are resumed once a probe succeeds and closes it
+	assert.Nil(t, tester.inv.Breaker.Allow())
+	tester.inv.Breaker.Record(nil)
+	tester.inv.pauseWhileBreakerOpen()
+	assert.False(t, tester.inv.paused)
+	mockConsumer.AssertExpectations(t)
+}
+
+func TestInventoryConsumer_PauseWhileBreakerHalfOpenWithoutProbes(t *testing.T) {
+	client := &mocks.MockClient{}
+	tester := setupBreakerTest(t, client)
+	partitions := []kafka.TopicPartition{{Topic: &tester.completedConfig.Topics[0], Partition: 0}}
+
+	mockConsumer := &mocks.MockConsumer{}
+	mockConsumer.On("Assignment").Return(partitions, nil)
+	mockConsumer.On("Pause", partitions).Return(nil).Once()
+	mockConsumer.On("Resume", partitions).Return(nil).Once()
+	tester.inv.Consumer = mockConsumer
+
+	tester.inv.Breaker.Record(errors.New("connection refused"))
+	tester.inv.pauseWhileBreakerOpen()
+	assert.True(t, tester.inv.paused)
+
+	// without messages left to probe the half-open breaker, the partitions are resumed so a new message can
+	assert.Eventually(t, func() bool {
+		tester.inv.pauseWhileBreakerOpen()
+		return !tester.inv.paused
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, breaker.HalfOpen, tester.inv.Breaker.State())
+	mockConsumer.AssertExpectations(t)
+}
+
+func TestInventoryConsumer_PauseWhileBreakerOpenFailure(t *testing.T) {
+	client := &mocks.MockClient{}
+	tester := setupBreakerTest(t, client)
+
+	mockConsumer := &mocks.MockConsumer{}
+	mockConsumer.On("Assignment").Return([]kafka.TopicPartition{}, errors.New("not subscribed"))
+	tester.inv.Consumer = mockConsumer
+
+	// the partitions are paused on a later poll if they could not be paused
+	tester.inv.Breaker.Record(errors.New("connection refused"))
+	tester.inv.pauseWhileBreakerOpen()
+	assert.False(t, tester.inv.paused)
+	mockConsumer.AssertNotCalled(t, "Pause", mock.Anything)
+}
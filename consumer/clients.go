@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"fmt"
+	"sync"
+
+	kessel "github.com/project-kessel/inventory-consumer/internal/client"
+	"google.golang.org/grpc"
+)
+
+// ClientFactory creates the client for an API version, such as a generated Inventory API client, on the connection
+// to Inventory API. Requests sent on the connection are rate limited and go through the circuit breaker like those of
+// the v1beta2 client.
+type ClientFactory func(conn grpc.ClientConnInterface) (kessel.Provider, error)
+
+// ClientRegistry maps API versions to the ClientFactory that creates the client used by their handlers
+type ClientRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]ClientFactory
+}
+
+// DefaultClients is the registry used by consumers created with New
+// Each API version has its own request types, so clients are created each time the consumer is created, including
+// when it restarts, and handlers retrieve them with ClientFor
+var DefaultClients = NewClientRegistry()
+
+// NewClientRegistry returns an empty ClientRegistry
+func NewClientRegistry() *ClientRegistry {
+	return &ClientRegistry{factories: make(map[string]ClientFactory)}
+}
+
+// RegisterClient adds a ClientFactory to the DefaultClients registry
+func RegisterClient(version string, factory ClientFactory) error {
+	return DefaultClients.Register(version, factory)
+}
+
+// Register adds a ClientFactory for the given API version
+// It returns an error if the version is empty or a ClientFactory is already registered for it
+func (r *ClientRegistry) Register(version string, factory ClientFactory) error {
+	if version == "" {
+		return fmt.Errorf("version is required to register a client")
+	}
+	if factory == nil {
+		return fmt.Errorf("client factory for version '%s' is nil", version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.factories[version]; ok {
+		return fmt.Errorf("client already registered for version '%s'", version)
+	}
+	r.factories[version] = factory
+	return nil
+}
+
+// newClients creates the client for each registered version on conn
+// No clients are created without a connection, such as when the Inventory API client is disabled
+func (r *ClientRegistry) newClients(conn grpc.ClientConnInterface) (map[string]kessel.Provider, error) {
+	clients := make(map[string]kessel.Provider)
+	if conn == nil {
+		return clients, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for version, factory := range r.factories {
+		client, err := factory(conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client for version '%s': %w", version, err)
+		}
+		clients[version] = client
+	}
+	return clients, nil
+}
+
+// ClientFor returns the client registered for an API version. Handlers assert it to the provider interface of their
+// version. The v1beta2 version falls back to the Client set on the consumer when no other client is registered for it.
+func (i *InventoryConsumer) ClientFor(version string) (kessel.Provider, error) {
+	client, ok := i.Clients[version]
+	if !ok && version == APIVersionV1Beta2 && i.Client != nil {
+		client, ok = i.Client, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("no client registered for version '%s'", version)
+	}
+	return client, nil
+}
+
+// clientProvider returns the client of an API version as the v1beta2 ClientProvider used by the built-in handlers
+func (i *InventoryConsumer) clientProvider(version string) (kessel.ClientProvider, error) {
+	client, err := i.ClientFor(version)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := client.(kessel.ClientProvider)
+	if !ok {
+		return nil, fmt.Errorf("client registered for version '%s' is %T which does not send v1beta2 requests", version, client)
+	}
+	return provider, nil
+}
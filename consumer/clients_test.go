@@ -0,0 +1,239 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/project-kessel/inventory-consumer/consumer/breaker"
+	kessel "github.com/project-kessel/inventory-consumer/internal/client"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// v1beta3ReportResourceMethod is the method a client for the test v1beta3 version sends its reports to
+const v1beta3ReportResourceMethod = "/kessel.inventory.v1beta3.KesselInventoryService/ReportResource"
+
+// v1beta3Provider is the provider interface the handlers of the test v1beta3 version use, with that version's
+// request types
+type v1beta3Provider interface {
+	kessel.Provider
+	ReportResource(ctx context.Context, request *v1beta3ReportResourceRequest) error
+}
+
+type v1beta3ReportResourceRequest struct {
+	ResourceID string `json:"resource_id"`
+}
+
+// v1beta3Client sends the requests of the test v1beta3 version on the connection to Inventory API
+type v1beta3Client struct {
+	conn grpc.ClientConnInterface
+}
+
+func (c *v1beta3Client) IsEnabled() bool {
+	return true
+}
+
+func (c *v1beta3Client) ReportResource(ctx context.Context, request *v1beta3ReportResourceRequest) error {
+	return c.conn.Invoke(ctx, v1beta3ReportResourceMethod, request, nil)
+}
+
+// handleV1beta3ReportResource parses a message into a v1beta3 request and sends it with the v1beta3 client
+func handleV1beta3ReportResource(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error {
+	provider, err := i.ClientFor(headers.Version)
+	if err != nil {
+		return err
+	}
+	client, ok := provider.(v1beta3Provider)
+	if !ok {
+		return fmt.Errorf("client for version '%s' is %T", headers.Version, provider)
+	}
+	request := &v1beta3ReportResourceRequest{}
+	if err := json.Unmarshal(msg.Value, request); err != nil {
+		return NewUnprocessableError("ParseV1beta3Request", err)
+	}
+	return client.ReportResource(ctx, request)
+}
+
+// connectedClient is a v1beta2 client that shares its connection like KesselClient
+type connectedClient struct {
+	*mocks.MockClient
+	conn grpc.ClientConnInterface
+}
+
+func (c *connectedClient) Conn() grpc.ClientConnInterface {
+	return c.conn
+}
+
+// fakeConn fails every request with err and records the requests sent
+type fakeConn struct {
+	grpc.ClientConnInterface
+	mu      sync.Mutex
+	err     error
+	calls   int
+	methods []string
+	args    []any
+}
+
+func (f *fakeConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.methods = append(f.methods, method)
+	f.args = append(f.args, args)
+	return f.err
+}
+
+func (f *fakeConn) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.methods...)
+}
+
+func TestClientRegistry_Register(t *testing.T) {
+	registry := NewClientRegistry()
+	factory := func(conn grpc.ClientConnInterface) (kessel.Provider, error) {
+		return &v1beta3Client{conn: conn}, nil
+	}
+
+	assert.Nil(t, registry.Register("v1beta3", factory))
+	assert.NotNil(t, registry.Register("v1beta3", factory))
+	assert.NotNil(t, registry.Register("", factory))
+	assert.NotNil(t, registry.Register("v1beta4", nil))
+
+	// clients are not created without a connection to Inventory API
+	clients, err := registry.newClients(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, clients)
+
+	assert.Nil(t, registry.Register("v1beta4", func(conn grpc.ClientConnInterface) (kessel.Provider, error) {
+		return nil, errors.New("invalid connection")
+	}))
+	_, err = registry.newClients(&fakeConn{})
+	assert.NotNil(t, err)
+}
+
+func TestNew_RegisteredClients(t *testing.T) {
+	defaultClients := DefaultClients
+	DefaultClients = NewClientRegistry()
+	defer func() { DefaultClients = defaultClients }()
+
+	var conns []grpc.ClientConnInterface
+	assert.Nil(t, RegisterClient("v1beta3", func(conn grpc.ClientConnInterface) (kessel.Provider, error) {
+		conns = append(conns, conn)
+		return &v1beta3Client{conn: conn}, nil
+	}))
+
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+	tester.completedConfig.CircuitBreakerOptions = &breaker.Options{Enabled: true, FailureThreshold: 1, OpenSeconds: 60, HalfOpenSuccesses: 1}
+	inner := &fakeConn{err: status.Error(codes.Unavailable, "connection refused")}
+	client := &connectedClient{MockClient: &mocks.MockClient{}, conn: inner}
+
+	// the clients are created again each time the consumer is, such as when it restarts
+	for range 2 {
+		inv, err := New(tester.completedConfig, client, tester.logger, &mocks.MockConsumer{})
+		assert.Nil(t, err)
+		_, err = inv.ClientFor("v1beta3")
+		assert.Nil(t, err)
+	}
+	assert.Len(t, conns, 2)
+
+	// requests on the connection go through the circuit breaker of the consumer
+	assert.ErrorIs(t, conns[1].Invoke(context.Background(), "/test/Check", nil, nil), inner.err)
+	assert.ErrorIs(t, conns[1].Invoke(context.Background(), "/test/Check", nil, nil), breaker.ErrOpen)
+	assert.Equal(t, 1, inner.calls)
+}
+
+func TestInventoryConsumer_ClientFor(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	v1beta2Client := &mocks.MockClient{}
+	v1beta3 := &v1beta3Client{}
+	tester.inv.Client = v1beta2Client
+	tester.inv.Clients["v1beta3"] = v1beta3
+
+	// v1beta2 falls back to the consumer client
+	client, err := tester.inv.ClientFor(APIVersionV1Beta2)
+	assert.Nil(t, err)
+	assert.Same(t, v1beta2Client, client)
+	provider, err := tester.inv.clientProvider(APIVersionV1Beta2)
+	assert.Nil(t, err)
+	assert.Same(t, v1beta2Client, provider)
+
+	client, err = tester.inv.ClientFor("v1beta3")
+	assert.Nil(t, err)
+	assert.Same(t, v1beta3, client)
+
+	// the registered client does not send v1beta2 requests
+	_, err = tester.inv.clientProvider("v1beta3")
+	assert.NotNil(t, err)
+
+	_, err = tester.inv.ClientFor("v2")
+	assert.NotNil(t, err)
+}
+
+func TestInventoryConsumer_V1beta3EndToEnd(t *testing.T) {
+	defaultClients := DefaultClients
+	DefaultClients = NewClientRegistry()
+	defer func() { DefaultClients = defaultClients }()
+	assert.Nil(t, RegisterClient("v1beta3", func(conn grpc.ClientConnInterface) (kessel.Provider, error) {
+		return &v1beta3Client{conn: conn}, nil
+	}))
+
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+	conn := &fakeConn{}
+	v1beta2Client := &mocks.MockClient{}
+	v1beta2Client.On("IsEnabled").Return(true)
+	v1beta2Client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+	client := &connectedClient{MockClient: v1beta2Client, conn: conn}
+
+	inv, err := New(tester.completedConfig, client, tester.logger, &mocks.MockConsumer{})
+	assert.Nil(t, err)
+	inv.Handlers = NewDefaultHandlerRegistry()
+	assert.Nil(t, inv.Handlers.Register(OperationTypeReportResource, "v1beta3", handleV1beta3ReportResource))
+	inv.Workers = NewPartitionWorkers(1, inv.processPartitionMessage, inv.storeProcessedOffset)
+	defer inv.Workers.StopAll()
+
+	// both versions are consumed from the same topic, each sent with the client of its version
+	inv.Workers.Dispatch(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: 0},
+		Headers: []kafka.Header{
+			{Key: "operation", Value: []byte(OperationTypeReportResource)},
+			{Key: "version", Value: []byte("v1beta3")},
+		},
+		Value: []byte(`{"resource_id":"host-1"}`),
+	})
+	inv.Workers.Dispatch(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: 1},
+		Headers: []kafka.Header{
+			{Key: "operation", Value: []byte(OperationTypeReportResource)},
+			{Key: "version", Value: []byte(APIVersionV1Beta2)},
+		},
+		Value: []byte(testCreateOrUpdateMessage),
+	})
+	assert.Eventually(t, func() bool {
+		pending := inv.OffsetStorage.Pending()
+		return len(pending) == 1 && pending[0].Offset == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, []string{v1beta3ReportResourceMethod}, conn.sent())
+	assert.Equal(t, &v1beta3ReportResourceRequest{ResourceID: "host-1"}, conn.args[0])
+	v1beta2Client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 1)
+}
@@ -0,0 +1,119 @@
+package consumer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	// MessageFormatHeaders reads the operation and version of messages from the operation and version headers
+	MessageFormatHeaders = "headers"
+	// MessageFormatCloudEvents reads the operation and version of CloudEvents from their type and dataschema, and
+	// falls back to the operation and version headers for messages that are not CloudEvents
+	MessageFormatCloudEvents = "cloudevents"
+	// MessageFormatMigration processes every message as a migration message, such as a Debezium change event captured
+	// for a migration, whatever its operation header
+	MessageFormatMigration = "migration"
+
+	// cloudEventsHeaderPrefix prefixes the CloudEvents attributes set as Kafka headers in binary mode
+	cloudEventsHeaderPrefix = "ce_"
+	// cloudEventsContentType is the content type of CloudEvents sent in structured mode
+	cloudEventsContentType = "application/cloudevents+json"
+	contentTypeHeader      = "content-type"
+)
+
+// CloudEvent holds the CloudEvents attributes used to process a message, and the event data
+type CloudEvent struct {
+	SpecVersion string          `json:"specversion"`
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	Type        string          `json:"type"`
+	DataSchema  string          `json:"dataschema"`
+	Data        json.RawMessage `json:"data"`
+	DataBase64  string          `json:"data_base64"`
+}
+
+// ParseCloudEvent returns the CloudEvent sent in a message in binary mode, with its attributes in ce_ headers, or in
+// structured mode, with a content-type of application/cloudevents+json. It returns false if the message is not a CloudEvent.
+func ParseCloudEvent(msg *kafka.Message) (*CloudEvent, bool, error) {
+	attributes := make(map[string]string)
+	for _, header := range msg.Headers {
+		key := strings.ToLower(header.Key)
+		if strings.HasPrefix(key, cloudEventsHeaderPrefix) || key == contentTypeHeader {
+			attributes[key] = string(header.Value)
+		}
+	}
+
+	var event CloudEvent
+	switch {
+	case attributes[cloudEventsHeaderPrefix+"specversion"] != "":
+		event = CloudEvent{
+			SpecVersion: attributes[cloudEventsHeaderPrefix+"specversion"],
+			ID:          attributes[cloudEventsHeaderPrefix+"id"],
+			Source:      attributes[cloudEventsHeaderPrefix+"source"],
+			Type:        attributes[cloudEventsHeaderPrefix+"type"],
+			DataSchema:  attributes[cloudEventsHeaderPrefix+"dataschema"],
+			Data:        msg.Value,
+		}
+	case strings.HasPrefix(attributes[contentTypeHeader], cloudEventsContentType):
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, false, fmt.Errorf("error unmarshaling structured CloudEvent: %w", err)
+		}
+		if event.DataBase64 != "" {
+			data, err := base64.StdEncoding.DecodeString(event.DataBase64)
+			if err != nil {
+				return nil, false, fmt.Errorf("error decoding CloudEvent data_base64: %w", err)
+			}
+			event.Data = data
+		}
+	default:
+		return nil, false, nil
+	}
+
+	if event.Type == "" {
+		return nil, false, fmt.Errorf("required CloudEvent attribute 'type' is missing: id='%s'", event.ID)
+	}
+	return &event, true, nil
+}
+
+// Message returns a copy of the message with the event data as its value, so it is handled like any other message
+func (e *CloudEvent) Message(msg *kafka.Message) *kafka.Message {
+	event := *msg
+	event.Value = e.Data
+	return &event
+}
+
+// CloudEventHeaders maps a CloudEvent to the operation and version of a registered handler
+// The operation is the last dot separated segment of the type, e.g. com.redhat.kessel.ReportResource. The version is
+// taken from a type suffix, e.g. com.redhat.kessel.ReportResource.v1beta2, or else from a segment of the dataschema
+// URI, e.g. https://example.com/schemas/v1beta2/report-resource.json
+func (r *HandlerRegistry) CloudEventHeaders(event *CloudEvent) (EventHeaders, error) {
+	versions := make(map[string]bool)
+	for _, key := range r.Keys() {
+		versions[key.Version] = true
+	}
+
+	segments := strings.Split(event.Type, ".")
+	headers := EventHeaders{Operation: segments[len(segments)-1]}
+	if len(segments) > 1 && versions[headers.Operation] {
+		headers.Version = headers.Operation
+		headers.Operation = segments[len(segments)-2]
+	} else {
+		for _, segment := range strings.FieldsFunc(event.DataSchema, func(r rune) bool {
+			return strings.ContainsRune("/:.#?=&", r)
+		}) {
+			if versions[segment] {
+				headers.Version = segment
+			}
+		}
+	}
+
+	if err := r.validate(headers, "type", "dataschema"); err != nil {
+		return EventHeaders{}, fmt.Errorf("unsupported CloudEvent: type='%s' dataschema='%s': %w", event.Type, event.DataSchema, err)
+	}
+	return headers, nil
+}
@@ -0,0 +1,237 @@
+package consumer
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testReportResourceData = `{"type":"host","reporter_type":"hbi","reporter_instance_id":"00000000-0000-0000-0000-000000000000"}`
+
+func binaryCloudEvent(eventType, dataSchema, data string) *kafka.Message {
+	headers := []kafka.Header{
+		{Key: "ce_specversion", Value: []byte("1.0")},
+		{Key: "ce_id", Value: []byte("1")},
+		{Key: "ce_source", Value: []byte("/hbi")},
+		{Key: "ce_type", Value: []byte(eventType)},
+		{Key: "content-type", Value: []byte("application/json")},
+	}
+	if dataSchema != "" {
+		headers = append(headers, kafka.Header{Key: "ce_dataschema", Value: []byte(dataSchema)})
+	}
+	return &kafka.Message{Headers: headers, Value: []byte(data)}
+}
+
+func structuredCloudEvent(event string) *kafka.Message {
+	return &kafka.Message{
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte("application/cloudevents+json; charset=UTF-8")}},
+		Value:   []byte(event),
+	}
+}
+
+func TestParseCloudEvent(t *testing.T) {
+	tests := []struct {
+		name         string
+		msg          *kafka.Message
+		expectOk     bool
+		expectType   string
+		expectSchema string
+		expectData   string
+		expectErr    bool
+	}{
+		{
+			name:         "binary mode",
+			msg:          binaryCloudEvent("com.redhat.kessel.ReportResource", "https://example.com/schemas/v1beta2/report-resource.json", testReportResourceData),
+			expectOk:     true,
+			expectType:   "com.redhat.kessel.ReportResource",
+			expectSchema: "https://example.com/schemas/v1beta2/report-resource.json",
+			expectData:   testReportResourceData,
+		},
+		{
+			name:       "structured mode",
+			msg:        structuredCloudEvent(`{"specversion":"1.0","id":"1","source":"/hbi","type":"com.redhat.kessel.ReportResource.v1beta2","data":` + testReportResourceData + `}`),
+			expectOk:   true,
+			expectType: "com.redhat.kessel.ReportResource.v1beta2",
+			expectData: testReportResourceData,
+		},
+		{
+			name: "structured mode with base64 data",
+			msg: structuredCloudEvent(`{"specversion":"1.0","id":"1","source":"/hbi","type":"com.redhat.kessel.ReportResource.v1beta2","data_base64":"` +
+				base64.StdEncoding.EncodeToString([]byte(testReportResourceData)) + `"}`),
+			expectOk:   true,
+			expectType: "com.redhat.kessel.ReportResource.v1beta2",
+			expectData: testReportResourceData,
+		},
+		{
+			name:     "messages with operation and version headers are not CloudEvents",
+			msg:      &kafka.Message{Headers: []kafka.Header{{Key: "operation", Value: []byte(OperationTypeReportResource)}}, Value: []byte(testReportResourceData)},
+			expectOk: false,
+		},
+		{
+			name:      "missing type is an error",
+			msg:       binaryCloudEvent("", "", testReportResourceData),
+			expectErr: true,
+		},
+		{
+			name:      "invalid structured event is an error",
+			msg:       structuredCloudEvent(`{invalid`),
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, ok, err := ParseCloudEvent(test.msg)
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectOk, ok)
+			if !test.expectOk {
+				assert.Nil(t, event)
+				return
+			}
+			assert.Equal(t, test.expectType, event.Type)
+			assert.Equal(t, test.expectSchema, event.DataSchema)
+			assert.JSONEq(t, test.expectData, string(event.Message(test.msg).Value))
+		})
+	}
+}
+
+func TestHandlerRegistry_CloudEventHeaders(t *testing.T) {
+	tests := []struct {
+		name            string
+		event           *CloudEvent
+		expectedHeaders EventHeaders
+		expectErr       bool
+	}{
+		{
+			name:            "version from type suffix",
+			event:           &CloudEvent{Type: "com.redhat.kessel.DeleteResource.v1beta2"},
+			expectedHeaders: EventHeaders{Operation: OperationTypeDeleteResource, Version: APIVersionV1Beta2},
+		},
+		{
+			name:            "version from dataschema",
+			event:           &CloudEvent{Type: "com.redhat.kessel.ReportResource", DataSchema: "https://example.com/schemas/v1beta2/report-resource.json"},
+			expectedHeaders: EventHeaders{Operation: OperationTypeReportResource, Version: APIVersionV1Beta2},
+		},
+		{
+			name:            "type without a namespace",
+			event:           &CloudEvent{Type: "migration", DataSchema: "urn:kessel:v1beta2"},
+			expectedHeaders: EventHeaders{Operation: OperationTypeMigration, Version: APIVersionV1Beta2},
+		},
+		{
+			name:      "missing version is an error",
+			event:     &CloudEvent{Type: "com.redhat.kessel.ReportResource"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown operation is an error",
+			event:     &CloudEvent{Type: "com.redhat.kessel.CreateTuple.v1beta2"},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers, err := DefaultHandlers.CloudEventHeaders(test.event)
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedHeaders, headers)
+		})
+	}
+}
+
+func TestInventoryConsumer_ProcessCloudEvents(t *testing.T) {
+	tests := []struct {
+		name          string
+		messageFormat string
+		msg           *kafka.Message
+		setupMock     func(client *mocks.MockClient)
+		expectStage   string
+	}{
+		{
+			name:          "binary mode CloudEvents are processed",
+			messageFormat: MessageFormatCloudEvents,
+			msg:           binaryCloudEvent("com.redhat.kessel.ReportResource", "https://example.com/schemas/v1beta2/report-resource.json", testReportResourceData),
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.MatchedBy(func(req *v1beta2.ReportResourceRequest) bool {
+					return req.Type == "host" && req.ReporterType == "hbi"
+				})).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:          "structured mode CloudEvents are processed",
+			messageFormat: MessageFormatCloudEvents,
+			msg:           structuredCloudEvent(`{"specversion":"1.0","id":"1","source":"/hbi","type":"com.redhat.kessel.ReportResource.v1beta2","data":` + testReportResourceData + `}`),
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.MatchedBy(func(req *v1beta2.ReportResourceRequest) bool {
+					return req.Type == "host" && req.ReporterType == "hbi"
+				})).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:          "messages with operation and version headers are still processed",
+			messageFormat: MessageFormatCloudEvents,
+			msg: &kafka.Message{
+				Headers: []kafka.Header{
+					{Key: "operation", Value: []byte(OperationTypeReportResource)},
+					{Key: "version", Value: []byte(APIVersionV1Beta2)},
+				},
+				Value: []byte(testCreateOrUpdateMessage),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:          "unsupported CloudEvents are unprocessable",
+			messageFormat: MessageFormatCloudEvents,
+			msg:           binaryCloudEvent("com.redhat.kessel.ReportResource", "", testReportResourceData),
+			setupMock:     func(client *mocks.MockClient) {},
+			expectStage:   "ParseCloudEvent",
+		},
+		{
+			name:          "CloudEvents are not read with the headers message format",
+			messageFormat: MessageFormatHeaders,
+			msg:           binaryCloudEvent("com.redhat.kessel.ReportResource.v1beta2", "", testReportResourceData),
+			setupMock:     func(client *mocks.MockClient) {},
+			expectStage:   "ParseHeaders",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+
+			client := &mocks.MockClient{}
+			test.setupMock(client)
+			tester.inv.Client = client
+			tester.inv.MessageFormat = test.messageFormat
+
+			topic := "test-topic"
+			test.msg.TopicPartition = kafka.TopicPartition{Topic: &topic}
+			err := tester.inv.processPartitionMessage(context.Background(), test.msg)
+			if test.expectStage != "" {
+				var unprocessable *UnprocessableError
+				assert.ErrorAs(t, err, &unprocessable)
+				assert.Equal(t, test.expectStage, unprocessable.Stage)
+			} else {
+				assert.Nil(t, err)
+			}
+			client.AssertExpectations(t)
+		})
+	}
+}
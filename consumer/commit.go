@@ -0,0 +1,61 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+)
+
+// CommitPolicy decides when the offsets stored for processed messages are committed
+type CommitPolicy interface {
+	// Processed is called each time the processed offset of a partition advances and is stored
+	Processed()
+	// ShouldCommit returns true when stored offsets are due to be committed
+	ShouldCommit(now time.Time) bool
+	// Committed resets the policy after stored offsets have been committed
+	Committed(now time.Time)
+}
+
+// ThresholdCommitPolicy commits once Count offsets have been stored or Interval has elapsed since the last commit,
+// whichever comes first. Setting either threshold to zero disables it.
+type ThresholdCommitPolicy struct {
+	Count    int
+	Interval time.Duration
+
+	mu         sync.Mutex
+	processed  int
+	lastCommit time.Time
+}
+
+// NewThresholdCommitPolicy returns a ThresholdCommitPolicy with the given thresholds, starting its interval now
+func NewThresholdCommitPolicy(count int, interval time.Duration) *ThresholdCommitPolicy {
+	return &ThresholdCommitPolicy{
+		Count:      count,
+		Interval:   interval,
+		lastCommit: time.Now(),
+	}
+}
+
+func (p *ThresholdCommitPolicy) Processed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed++
+}
+
+func (p *ThresholdCommitPolicy) ShouldCommit(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.processed == 0 {
+		return false
+	}
+	if p.Count > 0 && p.processed >= p.Count {
+		return true
+	}
+	return p.Interval > 0 && now.Sub(p.lastCommit) >= p.Interval
+}
+
+func (p *ThresholdCommitPolicy) Committed(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed = 0
+	p.lastCommit = now
+}
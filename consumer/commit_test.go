@@ -0,0 +1,91 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdCommitPolicy(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		count     int
+		interval  time.Duration
+		processed int
+		elapsed   time.Duration
+		expected  bool
+	}{
+		{
+			name:      "nothing processed is never committed",
+			count:     1,
+			interval:  time.Second,
+			processed: 0,
+			elapsed:   time.Minute,
+			expected:  false,
+		},
+		{
+			name:      "commit count reached",
+			count:     10,
+			interval:  time.Minute,
+			processed: 10,
+			expected:  true,
+		},
+		{
+			name:      "commit count and interval not reached",
+			count:     10,
+			interval:  time.Minute,
+			processed: 9,
+			elapsed:   time.Second,
+			expected:  false,
+		},
+		{
+			name:      "commit interval elapsed before commit count reached",
+			count:     10,
+			interval:  time.Second,
+			processed: 1,
+			elapsed:   time.Second,
+			expected:  true,
+		},
+		{
+			name:      "count only policy ignores elapsed time",
+			count:     10,
+			processed: 1,
+			elapsed:   time.Hour,
+			expected:  false,
+		},
+		{
+			name:      "interval only policy ignores processed count",
+			interval:  time.Minute,
+			processed: 1000,
+			elapsed:   time.Second,
+			expected:  false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := NewThresholdCommitPolicy(test.count, test.interval)
+			policy.Committed(now)
+			for range test.processed {
+				policy.Processed()
+			}
+			assert.Equal(t, test.expected, policy.ShouldCommit(now.Add(test.elapsed)))
+		})
+	}
+}
+
+func TestThresholdCommitPolicy_Committed(t *testing.T) {
+	now := time.Now()
+	policy := NewThresholdCommitPolicy(2, time.Second)
+	policy.Processed()
+	policy.Processed()
+	assert.True(t, policy.ShouldCommit(now))
+
+	policy.Committed(now)
+	assert.False(t, policy.ShouldCommit(now.Add(2*time.Second)))
+
+	policy.Processed()
+	assert.False(t, policy.ShouldCommit(now.Add(time.Millisecond)))
+	assert.True(t, policy.ShouldCommit(now.Add(time.Second)))
+}
@@ -14,7 +14,8 @@ const clientID = "kic"
 
 type Config struct {
 	*Options
-	KafkaConfig *kafka.ConfigMap
+	KafkaConfig    *kafka.ConfigMap
+	ProducerConfig *kafka.ConfigMap
 
 	RetryConfig *retry.Config
 	AuthConfig  *auth.Config
@@ -22,10 +23,12 @@ type Config struct {
 
 type completedConfig struct {
 	*Options
-	Topics      []string
-	KafkaConfig *kafka.ConfigMap
-	RetryConfig *retry.Config
-	AuthConfig  *auth.Config
+	Topics         []string
+	KafkaConfig    *kafka.ConfigMap
+	ProducerConfig *kafka.ConfigMap
+	RetryConfig    *retry.Config
+	AuthConfig     *auth.Config
+	sasl           *saslCredentials
 }
 
 type CompletedConfig struct {
@@ -54,20 +57,7 @@ func (c *Config) Complete() (CompletedConfig, []error) {
 				errs = append(errs, fmt.Errorf("cannot set debug value: %w", err))
 			}
 		}
-		if c.AuthConfig.Enabled {
-			authSettings := map[string]string{
-				"security.protocol": c.AuthConfig.SecurityProtocol,
-				"sasl.mechanism":    c.AuthConfig.SASLMechanism,
-				"sasl.username":     c.AuthConfig.SASLUsername,
-				"sasl.password":     c.AuthConfig.SASLPassword,
-				"ssl.ca.location":   c.AuthConfig.CACertLocation,
-			}
-			for key, value := range authSettings {
-				if err := config.SetKey(key, value); err != nil {
-					errs = append(errs, fmt.Errorf("cannot set %s value: %w", key, err))
-				}
-			}
-		}
+		errs = append(errs, c.setAuthSettings(config)...)
 		kafkaSettings := map[string]string{
 			"client.id":              clientID,
 			"bootstrap.servers":      strings.Join(c.BootstrapServers, ","),
@@ -86,14 +76,55 @@ func (c *Config) Complete() (CompletedConfig, []error) {
 		}
 	}
 
+	// a producer is only needed when unprocessable messages are sent to a dead-letter topic
+	var producerConfig *kafka.ConfigMap
+	if c.ProducerConfig != nil {
+		producerConfig = c.ProducerConfig
+	} else if c.hasDeadLetterTopic() {
+		producerConfig = &kafka.ConfigMap{}
+		errs = append(errs, c.setAuthSettings(producerConfig)...)
+		producerSettings := map[string]string{
+			"client.id":         clientID,
+			"bootstrap.servers": strings.Join(c.BootstrapServers, ","),
+			"acks":              "all",
+		}
+		for key, value := range producerSettings {
+			if err := producerConfig.SetKey(key, value); err != nil {
+				errs = append(errs, fmt.Errorf("cannot set producer %s value: %w", key, err))
+			}
+		}
+	}
+
 	if len(errs) > 0 {
 		return CompletedConfig{}, errs
 	}
 	return CompletedConfig{&completedConfig{
-		KafkaConfig: config,
-		Topics:      c.Topics,
-		Options:     c.Options,
-		RetryConfig: c.RetryConfig,
-		AuthConfig:  c.AuthConfig,
+		KafkaConfig:    config,
+		ProducerConfig: producerConfig,
+		Topics:         subscribedTopics(c.Topics, c.TopicOptions),
+		Options:        c.Options,
+		RetryConfig:    c.RetryConfig,
+		AuthConfig:     c.AuthConfig,
+		sasl:           &saslCredentials{password: c.AuthConfig.SASLPassword},
 	}}, nil
 }
+
+// setAuthSettings applies the kafka authentication settings to a config map when auth is enabled
+func (c *Config) setAuthSettings(config *kafka.ConfigMap) []error {
+	var errs []error
+	if c.AuthConfig.Enabled {
+		authSettings := map[string]string{
+			"security.protocol": c.AuthConfig.SecurityProtocol,
+			"sasl.mechanism":    c.AuthConfig.SASLMechanism,
+			"sasl.username":     c.AuthConfig.SASLUsername,
+			"sasl.password":     c.AuthConfig.SASLPassword,
+			"ssl.ca.location":   c.AuthConfig.CACertLocation,
+		}
+		for key, value := range authSettings {
+			if err := config.SetKey(key, value); err != nil {
+				errs = append(errs, fmt.Errorf("cannot set %s value: %w", key, err))
+			}
+		}
+	}
+	return errs
+}
@@ -28,3 +28,21 @@ func TestConfig_Complete(t *testing.T) {
 	assert.NotNil(t, completed.RetryConfig)
 	assert.NotNil(t, completed.AuthConfig)
 }
+
+func TestConfig_CompleteWithDeadLetterTopic(t *testing.T) {
+	o := NewOptions()
+	o.Topics = []string{"test-topic"}
+	config := NewConfig(o)
+	completed, errs := config.Complete()
+	assert.Nil(t, errs)
+	assert.Nil(t, completed.ProducerConfig)
+
+	o.DeadLetterTopic = "test-topic.dlq"
+	o.BootstrapServers = []string{"localhost:9092"}
+	completed, errs = NewConfig(o).Complete()
+	assert.Nil(t, errs)
+	assert.NotNil(t, completed.ProducerConfig)
+	servers, err := completed.ProducerConfig.Get("bootstrap.servers", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost:9092", servers)
+}
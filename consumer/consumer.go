@@ -1,6 +1,7 @@
 package consumer
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,19 +14,25 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/project-kessel/inventory-consumer/consumer/auth"
+	"github.com/project-kessel/inventory-consumer/consumer/batch"
+	"github.com/project-kessel/inventory-consumer/consumer/breaker"
 	"github.com/project-kessel/inventory-consumer/consumer/retry"
+	"github.com/project-kessel/inventory-consumer/consumer/schemaregistry"
 	"github.com/project-kessel/inventory-consumer/consumer/transforms"
+	"github.com/project-kessel/inventory-consumer/consumer/types"
 	kessel "github.com/project-kessel/inventory-consumer/internal/client"
 	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
 	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 const (
-	// commitModulo is used to define the batch size of offsets based on the current offset being processed
-	commitModulo = 10
+	// producerFlushTimeoutMs is the maximum time to wait for outstanding dead-letter messages to be delivered on shutdown
+	producerFlushTimeoutMs = 5000
 
 	/*
 		TODO: Discussion started with SDK development team to see about adding some custom types for API Operations.
@@ -37,20 +44,17 @@ const (
 	OperationTypeReportResource = "ReportResource"
 	OperationTypeDeleteResource = "DeleteResource"
 	OperationTypeMigration      = "migration"
+
+	// ResourceTypeHeader is the optional header used to select the Transformer for a migration message
+	ResourceTypeHeader = "resource-type"
+
+	// APIVersionV1Beta2 is the version header value for messages targeting the v1beta2 Inventory API
+	APIVersionV1Beta2 = "v1beta2"
 )
 
 var (
-
-	/*
-		TODO: Similar to API Operations, validOperations and validApiVersions should be abstracted outside of the consumer
-		in the SDK's or API itself. Then we could reference the specific operation types for our required headers, and
-		perform validation using those defined valid operations per API version
-	*/
-	requiredHeaders  = map[string]bool{"operation": true, "version": true}
-	validOperations  = map[string]bool{OperationTypeReportResource: true, OperationTypeDeleteResource: true, OperationTypeMigration: true}
-	validApiVersions = map[string]bool{"v1beta2": true}
-	ErrClosed        = errors.New("consumer closed")
-	ErrMaxRetries    = errors.New("max retries reached")
+	ErrClosed     = errors.New("consumer closed")
+	ErrMaxRetries = errors.New("max retries reached")
 )
 
 type Consumer interface {
@@ -60,24 +64,76 @@ type Consumer interface {
 	IsClosed() bool
 	Close() error
 	AssignmentLost() bool
+	Assignment() (partitions []kafka.TopicPartition, err error)
+	Pause(partitions []kafka.TopicPartition) (err error)
+	Resume(partitions []kafka.TopicPartition) (err error)
 }
 
 // InventoryConsumer defines a Consumer with required clients and configs to call Relations API and update the Inventory DB with consistency tokens
 type InventoryConsumer struct {
 	Consumer         Consumer
+	Producer         Producer
 	Client           kessel.ClientProvider
-	OffsetStorage    []kafka.TopicPartition
+	Clients          map[string]kessel.Provider
+	Handlers         *HandlerRegistry
+	Transformers     *transforms.Registry
+	Decoder          Decoder
+	MessageFormat    string
+	HeaderOptions    *HeaderOptions
+	TopicOptions     map[string]*TopicOptions
+	Breaker          *breaker.Breaker
+	Batcher          *batch.Batcher
+	OffsetStorage    *OffsetStorage
+	CommitPolicy     CommitPolicy
+	Workers          *PartitionWorkers
 	Config           CompletedConfig
 	MetricsCollector *metricscollector.MetricsCollector
 	Logger           *log.Helper
 	AuthOptions      *auth.Options
 	RetryOptions     *retry.Options
+
+	// paused is true while the assigned partitions are paused for the open circuit breaker; it is only used by the
+	// goroutine polling the consumer
+	paused bool
+	// saslVersion is the version of the SASL password the consumer and producer authenticate with
+	saslVersion int
 }
 
 // New instantiates a new InventoryConsumer
 // If consumer is nil, a new kafka consumer will be created from config
 // If consumer is provided, it will be used (useful for testing)
+// If a dead-letter topic is configured, a kafka producer is also created for publishing unprocessable messages
 func New(config CompletedConfig, client kessel.ClientProvider, logger *log.Helper, consumer Consumer) (InventoryConsumer, error) {
+	// hosts are reported with the configured reporter identity and links, and transformers declared in the config
+	// file extend or replace the transformers registered from code
+	hostTransformer, err := transforms.NewHostTransformer(config.HostOptions)
+	if err != nil {
+		logger.Errorf("error creating host transformer: %v", err)
+		return InventoryConsumer{}, err
+	}
+	transformers := transforms.DefaultRegistry.WithTransformer(types.HostResourceType, hostTransformer)
+	if len(config.Mappings) > 0 {
+		transformers, err = transformers.WithMappings(config.Mappings)
+		if err != nil {
+			logger.Errorf("error loading transform mappings: %v", err)
+			return InventoryConsumer{}, err
+		}
+	}
+
+	// messages serialized with a schema registry are decoded to JSON before they are parsed
+	var decoder Decoder
+	if config.SchemaRegistryOptions != nil && config.SchemaRegistryOptions.URL != "" {
+		logger.Infof("Setting up schema registry decoder for %s", config.SchemaRegistryOptions.URL)
+		decoder = schemaregistry.NewDecoder(schemaregistry.NewClient(config.SchemaRegistryOptions))
+	}
+
+	// consumers created after the SASL password was rotated authenticate with the rotated password
+	saslPassword, saslVersion, err := config.rotateSASLPassword()
+	if err != nil {
+		logger.Errorf("error setting rotated SASL password: %v", err)
+		return InventoryConsumer{}, err
+	}
+
 	// Create consumer if not provided
 	if consumer == nil {
 		logger.Info("Setting up kafka consumer")
@@ -92,8 +148,19 @@ func New(config CompletedConfig, client kessel.ClientProvider, logger *log.Helpe
 		logger.Info("Setting up kafka consumer with provided consumer")
 	}
 
+	var producer Producer
+	if config.hasDeadLetterTopic() && config.ProducerConfig != nil {
+		logger.Info("Setting up kafka producer for dead-letter topics")
+		kafkaProducer, err := kafka.NewProducer(config.ProducerConfig)
+		if err != nil {
+			logger.Errorf("error creating kafka producer: %v", err)
+			return InventoryConsumer{}, err
+		}
+		producer = kafkaProducer
+	}
+
 	var mc metricscollector.MetricsCollector
-	err := mc.New(config.Topics)
+	err = mc.New(config.Topics)
 	if err != nil {
 		logger.Errorf("error creating metrics collector: %v", err)
 		return InventoryConsumer{}, err
@@ -104,7 +171,8 @@ func New(config CompletedConfig, client kessel.ClientProvider, logger *log.Helpe
 		SecurityProtocol: config.AuthConfig.SecurityProtocol,
 		SASLMechanism:    config.AuthConfig.SASLMechanism,
 		SASLUsername:     config.AuthConfig.SASLUsername,
-		SASLPassword:     config.AuthConfig.SASLPassword,
+		SASLPassword:     saslPassword,
+		SASLPasswordFile: config.AuthConfig.SASLPasswordFile,
 		CACertLocation:   config.AuthConfig.CACertLocation,
 	}
 
@@ -113,18 +181,82 @@ func New(config CompletedConfig, client kessel.ClientProvider, logger *log.Helpe
 		OperationMaxRetries: config.RetryConfig.OperationMaxRetries,
 		BackoffFactor:       config.RetryConfig.BackoffFactor,
 		MaxBackoffSeconds:   config.RetryConfig.MaxBackoffSeconds,
+		RetryableCodes:      config.RetryConfig.RetryableCodes,
+		TerminalCodes:       config.RetryConfig.TerminalCodes,
+		Operations:          config.RetryConfig.Operations,
+	}
+
+	if limited, ok := client.(kessel.RateLimited); ok {
+		limited.SetRateLimitObserver(func(operation string, wait time.Duration) {
+			mc.RateLimiterWait.Record(context.Background(), wait.Seconds(), metric.WithAttributes(attribute.String("operation", operation)))
+		})
+	}
+
+	// clients for other API versions send requests on the rate limited connection of the v1beta2 client
+	var conn grpc.ClientConnInterface
+	if connected, ok := client.(kessel.Connected); ok {
+		conn = connected.Conn()
+	}
+
+	// requests are sent through the circuit breaker so consumption pauses while Inventory API is unavailable
+	var circuitBreaker *breaker.Breaker
+	if config.CircuitBreakerOptions != nil && config.CircuitBreakerOptions.Enabled {
+		circuitBreaker = breaker.New(config.CircuitBreakerOptions, func(state breaker.State) {
+			mc.CircuitBreakerState.Record(context.Background(), int64(state))
+			logger.Warnf("circuit breaker for Inventory API requests is %s", state)
+		})
+		mc.CircuitBreakerState.Record(context.Background(), int64(circuitBreaker.State()))
+		if client != nil {
+			client = breaker.NewClient(client, circuitBreaker)
+		}
+		if conn != nil {
+			conn = breaker.NewConn(conn, circuitBreaker)
+		}
 	}
 
-	return InventoryConsumer{
+	clients, err := DefaultClients.newClients(conn)
+	if err != nil {
+		logger.Errorf("error creating Inventory API clients: %v", err)
+		return InventoryConsumer{}, err
+	}
+
+	var batcher *batch.Batcher
+	if config.BatchOptions != nil && config.BatchOptions.Enabled {
+		batcher = batch.New(config.BatchOptions)
+	}
+
+	topicOptions, err := newTopicOptions(config.TopicOptions, retryOptions, transformers)
+	if err != nil {
+		logger.Errorf("error loading topic options: %v", err)
+		return InventoryConsumer{}, err
+	}
+
+	inventoryConsumer := InventoryConsumer{
 		Consumer:         consumer,
+		Producer:         producer,
 		Client:           client,
-		OffsetStorage:    make([]kafka.TopicPartition, 0),
+		Clients:          clients,
+		Handlers:         DefaultHandlers,
+		Transformers:     transformers,
+		Decoder:          decoder,
+		MessageFormat:    config.MessageFormat,
+		HeaderOptions:    config.HeaderOptions,
+		TopicOptions:     topicOptions,
+		Breaker:          circuitBreaker,
+		Batcher:          batcher,
+		OffsetStorage:    NewOffsetStorage(),
+		CommitPolicy:     NewThresholdCommitPolicy(config.CommitCount, time.Duration(config.CommitIntervalMs)*time.Millisecond),
 		Config:           config,
 		MetricsCollector: &mc,
 		Logger:           logger,
 		AuthOptions:      authnOptions,
 		RetryOptions:     retryOptions,
-	}, nil
+		saslVersion:      saslVersion,
+	}
+	inventoryConsumer.Workers = NewPartitionWorkers(config.WorkersPerPartition, inventoryConsumer.processPartitionMessage, inventoryConsumer.storeProcessedOffset)
+	inventoryConsumer.Workers.SetWait(inventoryConsumer.waitForBreaker)
+	inventoryConsumer.Workers.SetKey(inventoryConsumer.laneKey)
+	return inventoryConsumer, nil
 }
 
 // KeyPayload stores the event message key captured from the topic as emitted by Debezium
@@ -200,45 +332,24 @@ func (i *InventoryConsumer) Consume() error {
 		select {
 		case <-sigchan:
 			run = false
+		case err := <-i.Workers.Errors():
+			i.Logger.Errorf("partition worker stopped: %v", err)
+			run = false
 		default:
 			event := i.Consumer.Poll(100)
+			// commits are checked on every poll so the interval threshold is honored even when no messages arrive
+			i.commitIfDue()
+			i.pauseWhileBreakerOpen()
+			i.throttlePartitions()
+			i.applySASLCredentials()
 			if event == nil {
 				continue
 			}
 
 			switch e := event.(type) {
 			case *kafka.Message:
-				headers, err := ParseHeaders(e)
-				if err != nil {
-					metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseHeaders", fmt.Errorf("missing headers"))
-					i.Logger.Errorf("failed to parse message headers: %v", err)
-					run = false
-					continue
-				}
-
-				err = i.ProcessMessage(headers, e)
-				if err != nil {
-					i.Logger.Errorf(
-						"error processing message: topic=%s partition=%d offset=%s",
-						*e.TopicPartition.Topic, e.TopicPartition.Partition, e.TopicPartition.Offset)
-					run = false
-					continue
-				}
-
-				// store the current offset to be later batch committed
-				i.OffsetStorage = append(i.OffsetStorage, e.TopicPartition)
-				if CheckIfCommit(e.TopicPartition) {
-					err := i.CommitStoredOffsets()
-					if err != nil {
-						metricscollector.Incr(i.MetricsCollector.ConsumerErrors, "CommitStoredOffsets", err)
-						i.Logger.Errorf("failed to commit offsets: %v", err)
-						continue
-					}
-				}
-				metricscollector.Incr(i.MetricsCollector.MsgsProcessed, headers.Operation, nil)
-				i.Logger.Infof("consumed event from topic %s, partition %d at offset %s",
-					*e.TopicPartition.Topic, e.TopicPartition.Partition, e.TopicPartition.Offset)
-				i.Logger.Debugf("consumed event data: key = %-10s value = %s", string(e.Key), string(e.Value))
+				// messages are handed off to the workers for their partition and processed in key order there
+				i.Workers.Dispatch(e)
 
 			case kafka.Error:
 				metricscollector.Incr(i.MetricsCollector.KafkaErrorEvents, "kafka", nil,
@@ -272,143 +383,304 @@ func (i *InventoryConsumer) Consume() error {
 	return err
 }
 
-// ProcessMessage processes an event message and replicates the change to Kessel Inventory
-func (i *InventoryConsumer) ProcessMessage(headers EventHeaders, msg *kafka.Message) error {
-	switch headers.Operation {
-	// TODO: We need to support migrations for many resource types, this is a temporary solution to support host migrations
-	case OperationTypeMigration:
-		i.Logger.Infof("processing message: operation=%s, version=%s", headers.Operation, headers.Version)
-		i.Logger.Debugf("processed message=%s", msg.Value)
-
-		if i.Client.IsEnabled() {
-			var resp interface{}
-			var operationErr error
-
-			// Migration error handler for "resource not found" errors
-			deleteErrorHandler := func(err error) bool {
-				if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
-					metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "MigrationResourceNotFound", err)
-					i.Logger.Warnf("resource not found during migration delete, dropping message: %v", err)
-					return true // Short-circuit retry loop
-				}
-				return false // Continue with normal retry behavior
-			}
+// processPartitionMessage is run by a partition worker for each message consumed from its partition
+func (i *InventoryConsumer) processPartitionMessage(ctx context.Context, msg *kafka.Message) error {
+	// messages are decoded before they are parsed so structured CloudEvents are detected in their decoded value;
+	// unprocessable messages are dead-lettered in their original format
+	decoded, err := i.decodeMessage(msg)
+	if err != nil {
+		return i.handleUnprocessable(ctx, msg, err)
+	}
 
-			// Check if this is a delete message
-			isDeleted, err := transforms.IsHostDeleted(msg.Value)
-			if err != nil {
-				i.Logger.Errorf("failed to check if host is deleted: %v", err)
-				return err
-			}
+	headers, event, err := i.parseMessage(decoded)
+	if err != nil {
+		// unprocessable messages are committed past once dead-lettered
+		return i.handleUnprocessable(ctx, msg, err)
+	}
 
-			if isDeleted {
-				// Transform and process delete request
-				deleteReq, err := transforms.TransformHostToDeleteResourceRequest(msg.Value, msg.Key)
-				if err != nil {
-					metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "TransformHostToDeleteResourceRequest", err)
-					i.Logger.Errorf("failed to parse message for host deletion: %v", err)
-					return err
-				}
+	err = i.ProcessMessage(ctx, headers, event)
+	if errors.Is(err, errDeferred) {
+		// the message is completed once its batch is sent
+		return nil
+	}
+	if err != nil {
+		i.Logger.Errorf(
+			"error processing message: topic=%s partition=%d offset=%s",
+			*msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset)
+		return i.handleUnprocessable(ctx, msg, err)
+	}
 
-				resp, operationErr = i.Retry(func() (interface{}, error) {
-					return i.Client.DeleteResource(deleteReq)
-				}, deleteErrorHandler)
-			} else {
-				// Transform and process report resource request
-				reportReq, err := transforms.TransformHostToReportResourceRequest(msg.Value)
-				if err != nil {
-					metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "TransformHostToReportResourceRequest", err)
-					i.Logger.Errorf("failed to parse message for host: %v", err)
-					return err
-				}
+	metricscollector.Incr(i.MetricsCollector.MsgsProcessed, headers.Operation, nil)
+	i.Logger.Infof("consumed event from topic %s, partition %d at offset %s",
+		*msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset)
+	i.Logger.Debugf("consumed event data: key = %-10s value = %s", string(msg.Key), string(msg.Value))
+	return nil
+}
 
-				resp, operationErr = i.Retry(func() (interface{}, error) {
-					return i.Client.CreateOrUpdateResource(reportReq)
-				})
-			}
+// parseMessage returns the operation and version of a message from its headers, from its CloudEvents attributes when
+// the CloudEvents message format is configured, or the migration operation when the migration message format is
+// configured, along with the message to process
+func (i *InventoryConsumer) parseMessage(msg *kafka.Message) (EventHeaders, *kafka.Message, error) {
+	switch i.messageFormat(msg) {
+	case MessageFormatMigration:
+		return i.topicOptions(msg).migrationHeaders(), msg, nil
+	case MessageFormatCloudEvents:
+		event, ok, err := ParseCloudEvent(msg)
+		var headers EventHeaders
+		if err == nil && ok {
+			headers, err = i.Handlers.CloudEventHeaders(event)
+		}
+		if err != nil {
+			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseCloudEvent", err)
+			i.Logger.Errorf("failed to parse CloudEvent: %v", err)
+			return EventHeaders{}, nil, NewUnprocessableError("ParseCloudEvent", err)
+		}
+		if ok {
+			return headers, event.Message(msg), nil
+		}
+	}
+
+	headers, err := i.Handlers.ParseHeadersWithDefaults(msg, i.headerNames(msg), i.topicOptions(msg).defaultHeaders())
+	if err != nil {
+		metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseHeaders", fmt.Errorf("missing headers"))
+		i.Logger.Errorf("failed to parse message headers: %v", err)
+		return EventHeaders{}, nil, NewUnprocessableError("ParseHeaders", err)
+	}
+	return headers, msg, nil
+}
+
+// headerNames returns the header keys the operation and version of a message are read from, which may be configured
+// per topic
+func (i *InventoryConsumer) headerNames(msg *kafka.Message) HeaderNames {
+	if i.HeaderOptions == nil || msg.TopicPartition.Topic == nil {
+		return DefaultHeaderNames
+	}
+	return i.HeaderOptions.NamesFor(*msg.TopicPartition.Topic)
+}
+
+// storeProcessedOffset is called by the partition workers whenever the lowest contiguous processed offset of a partition
+// advances. It stores the offset to be later batch committed by the consumer loop according to the CommitPolicy
+func (i *InventoryConsumer) storeProcessedOffset(partition kafka.TopicPartition) {
+	i.OffsetStorage.Store(partition)
+	i.CommitPolicy.Processed()
+}
 
-			if operationErr != nil {
-				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ProcessMigrationResource", operationErr)
-				i.Logger.Errorf("failed to process migration resource: %v", operationErr)
-				return operationErr
+// commitIfDue commits stored offsets when the CommitPolicy condition is met
+func (i *InventoryConsumer) commitIfDue() {
+	if !i.CommitPolicy.ShouldCommit(time.Now()) || i.OffsetStorage.Len() == 0 {
+		return
+	}
+	err := i.CommitStoredOffsets()
+	if err != nil {
+		metricscollector.Incr(i.MetricsCollector.ConsumerErrors, "CommitStoredOffsets", err)
+		i.Logger.Errorf("failed to commit offsets: %v", err)
+	}
+}
+
+// ProcessMessage processes a decoded event message and replicates the change to Kessel Inventory
+// Requests to Inventory API and retries are canceled with ctx
+func (i *InventoryConsumer) ProcessMessage(ctx context.Context, headers EventHeaders, msg *kafka.Message) error {
+	handler, ok := i.Handlers.Lookup(headers.Operation, headers.Version)
+	if !ok {
+		metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "unknown-operation-type", nil)
+		i.Logger.Errorf("unknown operation type, message cannot be processed and will be dropped: offset=%s operation=%s version=%s msg=%s",
+			msg.TopicPartition.Offset.String(), headers.Operation, headers.Version, msg.Value)
+		return nil
+	}
+	return handler(ctx, i, headers, msg)
+}
+
+// handleMigration processes migration messages, reporting or deleting the resource based on its payload
+// The payload is transformed by the Transformer registered for the message's resource-type header or topic
+func (i *InventoryConsumer) handleMigration(ctx context.Context, headers EventHeaders, msg *kafka.Message) error {
+	i.Logger.Infof("processing message: operation=%s, version=%s", headers.Operation, headers.Version)
+	i.Logger.Debugf("processed message=%s", msg.Value)
+
+	client, err := i.clientProvider(headers.Version)
+	if err != nil {
+		i.Logger.Errorf("failed to get client: %v", err)
+		return err
+	}
+	if client.IsEnabled() {
+		// send sends the request for the message, with retries
+		var send func() (interface{}, error)
+
+		// Migration error handler for "resource not found" errors
+		deleteErrorHandler := func(err error) bool {
+			if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "MigrationResourceNotFound", err)
+				i.Logger.Warnf("resource not found during migration delete, dropping message: %v", err)
+				return true // Short-circuit retry loop
 			}
-			i.Logger.Infof("response: %+v", resp)
+			return false // Continue with normal retry behavior
 		}
-		return nil
 
-	case OperationTypeReportResource:
-		i.Logger.Infof("processing message: operation=%s, version=%s", headers.Operation, headers.Version)
-		i.Logger.Debugf("processed message=%s", msg.Value)
+		var topic string
+		if msg.TopicPartition.Topic != nil {
+			topic = *msg.TopicPartition.Topic
+		}
+		resourceType := headerValue(msg.Headers, ResourceTypeHeader)
+		if options := i.topicOptions(msg); resourceType == "" && options != nil {
+			resourceType = options.Transformer
+		}
+		transformer, err := i.Transformers.Lookup(resourceType, topic)
+		if err != nil {
+			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "LookupTransformer", err)
+			i.Logger.Errorf("failed to find transformer for message: %v", err)
+			return NewUnprocessableError("LookupTransformer", err)
+		}
 
-		var req v1beta2.ReportResourceRequest
-		err := ParseCreateOrUpdateMessage(msg.Value, &req)
+		// Raw Debezium envelopes are unwrapped to the record state expected by transformers, and deletes are taken
+		// from the envelope operation; other messages are expected to be unwrapped by the ExtractNewRecordState SMT
+		value := msg.Value
+		var isDeleted bool
+		envelope, ok, err := ParseDebeziumEnvelope(msg.Value)
 		if err != nil {
-			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseCreateOrUpdateMessage", err)
-			i.Logger.Errorf("failed to parse message for tuple: %v", err)
-			return err
+			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseDebeziumEnvelope", err)
+			i.Logger.Errorf("failed to parse Debezium envelope: %v", err)
+			return NewUnprocessableError("ParseDebeziumEnvelope", err)
+		}
+		if ok {
+			isDeleted = envelope.IsDelete()
+			value, err = envelope.Record()
+			if err != nil {
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseDebeziumEnvelope", err)
+				i.Logger.Errorf("failed to parse Debezium envelope: %v", err)
+				return NewUnprocessableError("ParseDebeziumEnvelope", err)
+			}
+		} else {
+			// Check if this is a delete message
+			isDeleted, err = transformer.IsDeleted(msg.Value)
+			if err != nil {
+				i.Logger.Errorf("failed to check if resource is deleted: %v", err)
+				return NewUnprocessableError("IsDeleted", err)
+			}
 		}
 
-		if i.Client.IsEnabled() {
-			resp, err := i.Retry(func() (interface{}, error) {
-				return i.Client.CreateOrUpdateResource(&req)
-			})
+		if isDeleted {
+			// Transform and process delete request
+			deleteReq, err := transformer.ToDeleteResourceRequest(value, msg.Key)
 			if err != nil {
-				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "CreateResource", err)
-				i.Logger.Errorf("failed to create resource: %v", err)
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "TransformToDeleteResourceRequest", err)
+				i.Logger.Errorf("failed to parse message for resource deletion: %v", err)
+				return NewUnprocessableError("TransformToDeleteResourceRequest", err)
+			}
+
+			send = func() (interface{}, error) {
+				return i.retry(ctx, i.retryOptions(msg), OperationTypeDeleteResource, func() (interface{}, error) {
+					return client.DeleteResource(ctx, deleteReq)
+				}, deleteErrorHandler)
+			}
+		} else {
+			// Transform and process report resource request
+			reportReq, err := transformer.ToReportResourceRequest(ctx, value)
+			if errors.Is(err, transforms.ErrWorkspaceUnavailable) {
+				// the message is valid but its workspace could not be looked up yet, so it is retried instead of dead-lettered
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ResolveDefaultWorkspace", err)
+				i.Logger.Errorf("failed to resolve workspace for resource: %v", err)
 				return err
 			}
-			i.Logger.Debugf("response: %v", resp)
+			if err != nil {
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "TransformToReportResourceRequest", err)
+				i.Logger.Errorf("failed to parse message for resource: %v", err)
+				return NewUnprocessableError("TransformToReportResourceRequest", err)
+			}
+
+			send = func() (interface{}, error) {
+				return i.retry(ctx, i.retryOptions(msg), OperationTypeReportResource, func() (interface{}, error) {
+					return client.CreateOrUpdateResource(ctx, reportReq)
+				})
+			}
 		}
-		return nil
 
-	case OperationTypeDeleteResource:
-		i.Logger.Infof("processing message: operation=%s, version=%s", headers.Operation, headers.Version)
-		i.Logger.Debugf("processed message=%s", msg.Value)
+		if i.Batcher != nil {
+			return i.addToBatch(ctx, msg, send)
+		}
+		resp, operationErr := send()
+		if operationErr != nil {
+			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ProcessMigrationResource", operationErr)
+			i.Logger.Errorf("failed to process migration resource: %v", operationErr)
+			return operationErr
+		}
+		i.Logger.Infof("response: %+v", resp)
+	}
+	return nil
+}
+
+// handleReportResource processes ReportResource messages whose payload is a ReportResourceRequest
+func (i *InventoryConsumer) handleReportResource(ctx context.Context, headers EventHeaders, msg *kafka.Message) error {
+	i.Logger.Infof("processing message: operation=%s, version=%s", headers.Operation, headers.Version)
+	i.Logger.Debugf("processed message=%s", msg.Value)
 
-		var req v1beta2.DeleteResourceRequest
-		err := ParseDeleteMessage(msg.Value, &req)
+	var req v1beta2.ReportResourceRequest
+	err := ParseCreateOrUpdateMessage(msg.Value, &req)
+	if err != nil {
+		metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseCreateOrUpdateMessage", err)
+		i.Logger.Errorf("failed to parse message for tuple: %v", err)
+		return NewUnprocessableError("ParseCreateOrUpdateMessage", err)
+	}
+
+	client, err := i.clientProvider(headers.Version)
+	if err != nil {
+		i.Logger.Errorf("failed to get client: %v", err)
+		return err
+	}
+	if client.IsEnabled() {
+		resp, err := i.retry(ctx, i.retryOptions(msg), OperationTypeReportResource, func() (interface{}, error) {
+			return client.CreateOrUpdateResource(ctx, &req)
+		})
 		if err != nil {
-			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseDeleteMessage", err)
-			i.Logger.Errorf("failed to parse message for filter: %v", err)
+			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "CreateResource", err)
+			i.Logger.Errorf("failed to create resource: %v", err)
 			return err
 		}
+		i.Logger.Debugf("response: %v", resp)
+	}
+	return nil
+}
 
-		if i.Client.IsEnabled() {
-			// Error handler for "resource not found" errors
-			deleteErrorHandler := func(err error) bool {
-				if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
-					metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "InventoryResourceNotFound", err)
-					i.Logger.Warnf("inventory resource not found, dropping message: %v", err)
-					return true // Short-circuit retry loop
-				}
-				return false // Continue with normal retry behavior
-			}
+// handleDeleteResource processes DeleteResource messages whose payload is a DeleteResourceRequest
+func (i *InventoryConsumer) handleDeleteResource(ctx context.Context, headers EventHeaders, msg *kafka.Message) error {
+	i.Logger.Infof("processing message: operation=%s, version=%s", headers.Operation, headers.Version)
+	i.Logger.Debugf("processed message=%s", msg.Value)
 
-			resp, err := i.Retry(func() (interface{}, error) {
-				return i.Client.DeleteResource(&req)
-			}, deleteErrorHandler)
-			if err != nil {
-				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "CreateResource", err)
-				i.Logger.Errorf("failed to create resource: %v", err)
-				return err
+	var req v1beta2.DeleteResourceRequest
+	err := ParseDeleteMessage(msg.Value, &req)
+	if err != nil {
+		metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "ParseDeleteMessage", err)
+		i.Logger.Errorf("failed to parse message for filter: %v", err)
+		return NewUnprocessableError("ParseDeleteMessage", err)
+	}
+
+	client, err := i.clientProvider(headers.Version)
+	if err != nil {
+		i.Logger.Errorf("failed to get client: %v", err)
+		return err
+	}
+	if client.IsEnabled() {
+		// Error handler for "resource not found" errors
+		deleteErrorHandler := func(err error) bool {
+			if st, ok := status.FromError(err); ok && st.Code() == codes.NotFound {
+				metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "InventoryResourceNotFound", err)
+				i.Logger.Warnf("inventory resource not found, dropping message: %v", err)
+				return true // Short-circuit retry loop
 			}
-			i.Logger.Debugf("response: %v", resp)
+			return false // Continue with normal retry behavior
 		}
-		return nil
 
-	default:
-		metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "unknown-operation-type", nil)
-		i.Logger.Errorf("unknown operation type, message cannot be processed and will be dropped: offset=%s operation=%s version=%s msg=%s",
-			msg.TopicPartition.Offset.String(), headers.Operation, headers.Version, msg.Value)
+		resp, err := i.retry(ctx, i.retryOptions(msg), OperationTypeDeleteResource, func() (interface{}, error) {
+			return client.DeleteResource(ctx, &req)
+		}, deleteErrorHandler)
+		if err != nil {
+			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "CreateResource", err)
+			i.Logger.Errorf("failed to create resource: %v", err)
+			return err
+		}
+		i.Logger.Debugf("response: %v", resp)
 	}
 	return nil
 }
 
-// CheckIfCommit returns true whenever the condition to commit a batch of offsets is met
-func CheckIfCommit(partition kafka.TopicPartition) bool {
-	return partition.Offset%commitModulo == 0
-}
-
 // FormatOffsets converts a slice of partitions with offset data into a more readable shorthand-coded string to capture what partitions and offsets were committed
 func FormatOffsets(offsets []kafka.TopicPartition) string {
 	var committedOffsets []string
@@ -418,15 +690,24 @@ func FormatOffsets(offsets []kafka.TopicPartition) string {
 	return strings.Join(committedOffsets, ",")
 }
 
-// CommitStoredOffsets commits offsets for all processed messages since last offset commit
+// CommitStoredOffsets commits the latest processed offset of each partition since last offset commit
+// Per Kafka semantics, the committed offset is the next offset to be consumed, so one past the last processed offset
 func (i *InventoryConsumer) CommitStoredOffsets() error {
-	committed, err := i.Consumer.CommitOffsets(i.OffsetStorage)
+	pending := i.OffsetStorage.Pending()
+	offsets := make([]kafka.TopicPartition, 0, len(pending))
+	for _, partition := range pending {
+		partition.Offset++
+		offsets = append(offsets, partition)
+	}
+
+	committed, err := i.Consumer.CommitOffsets(offsets)
 	if err != nil {
 		return err
 	}
 
 	i.Logger.Infof("offsets committed ([partition:offset]): %s", FormatOffsets(committed))
-	i.OffsetStorage = nil
+	i.OffsetStorage.Clear(pending)
+	i.CommitPolicy.Committed(time.Now())
 	return nil
 }
 
@@ -434,12 +715,24 @@ func (i *InventoryConsumer) CommitStoredOffsets() error {
 func (i *InventoryConsumer) Shutdown() error {
 	if !i.Consumer.IsClosed() {
 		i.Logger.Info("shutting down consumer...")
-		if len(i.OffsetStorage) > 0 {
+		// cancel in-flight requests and wait for the workers to exit so processed offsets are included in the final commit
+		i.Workers.StopAll()
+		if i.Batcher != nil {
+			// batched requests of the stopped workers are canceled and their messages are re-read on restart
+			i.Batcher.Close()
+		}
+		if i.OffsetStorage.Len() > 0 {
 			err := i.CommitStoredOffsets()
 			if err != nil {
 				i.Logger.Errorf("failed to commit offsets before shutting down: %v", err)
 			}
 		}
+		if i.Producer != nil {
+			if remaining := i.Producer.Flush(producerFlushTimeoutMs); remaining > 0 {
+				i.Logger.Errorf("%d dead-letter message(s) were not delivered before shutting down", remaining)
+			}
+			i.Producer.Close()
+		}
 		err := i.Consumer.Close()
 		if err != nil {
 			i.Logger.Errorf("Error closing kafka consumer: %v", err)
@@ -452,13 +745,30 @@ func (i *InventoryConsumer) Shutdown() error {
 
 // Retry executes the given function and will retry on failure with backoff until max retries is reached
 // If errorHandler returns true, the retry loop is short-circuited and the original error is returned
-func (i *InventoryConsumer) Retry(operation func() (interface{}, error), errorHandler ...func(error) bool) (interface{}, error) {
+// Errors with a terminal gRPC code are not retried and are returned as an UnprocessableError to be dead-lettered
+// Once ctx is canceled, no more attempts are made and the context error is returned
+func (i *InventoryConsumer) Retry(ctx context.Context, operation func() (interface{}, error), errorHandler ...func(error) bool) (interface{}, error) {
+	return i.retry(ctx, i.RetryOptions, "", operation, errorHandler...)
+}
+
+// retry is like Retry but uses the given retry options, such as those configured for the topic of a message, and the
+// retry policy of the named Inventory API operation
+func (i *InventoryConsumer) retry(ctx context.Context, options *retry.Options, name string, operation func() (interface{}, error), errorHandler ...func(error) bool) (interface{}, error) {
+	policy := options.Policy(name)
 	attempts := 0
 	var resp interface{}
 	var err error
 
-	for i.RetryOptions.OperationMaxRetries == -1 || attempts < i.RetryOptions.OperationMaxRetries {
+	for policy.ShouldRetry(attempts) {
 		resp, err = operation()
+		if err != nil && ctx.Err() != nil {
+			// requests canceled by shutdown or rebalance are neither retried nor dead-lettered
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, breaker.ErrOpen) {
+			// requests are not retried while the circuit breaker is open; the message waits for it to close instead
+			return nil, err
+		}
 		if err != nil {
 			// Check if we have a custom error handler and if it wants to short-circuit
 			if len(errorHandler) > 0 && errorHandler[0](err) {
@@ -468,10 +778,20 @@ func (i *InventoryConsumer) Retry(operation func() (interface{}, error), errorHa
 			metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "Retry", err)
 			i.Logger.Errorf("request failed: %v", err)
 			attempts++
-			if i.RetryOptions.OperationMaxRetries == -1 || attempts < i.RetryOptions.OperationMaxRetries {
-				backoff := min(time.Duration(i.RetryOptions.BackoffFactor*attempts*300)*time.Millisecond, time.Duration(i.RetryOptions.MaxBackoffSeconds)*time.Second)
+			if policy.IsTerminal(err) {
+				i.Logger.Errorf("request failed with a terminal error, not retrying (attempts: %v): %v", attempts, err)
+				stage := name
+				if stage == "" {
+					stage = "Retry"
+				}
+				return nil, &UnprocessableError{Stage: stage, Attempts: attempts, Err: err}
+			}
+			if policy.ShouldRetry(attempts) {
+				backoff := policy.Backoff(attempts)
 				i.Logger.Errorf("retrying in %v", backoff)
-				time.Sleep(backoff)
+				if err := sleep(ctx, backoff); err != nil {
+					return nil, err
+				}
 			}
 			continue
 		}
@@ -481,7 +801,8 @@ func (i *InventoryConsumer) Retry(operation func() (interface{}, error), errorHa
 	return nil, ErrMaxRetries
 }
 
-// RebalanceCallback logs when rebalance events occur and ensures any stored offsets are committed before losing the partition assignment.
+// RebalanceCallback logs when rebalance events occur, starts and stops partition workers to match the new assignment,
+// and ensures any stored offsets are committed before losing the partition assignment.
 // It is registered to the kafka 'SubscribeTopics' call and is invoked automatically whenever rebalances occurs.
 // Note, the RebalanceCb function must satisfy the function type func(*Consumer, Event).
 // This function does so, but the consumer embedded in the InventoryConsumer is used versus the passed one which is the same consumer in either case.
@@ -490,15 +811,22 @@ func (i *InventoryConsumer) RebalanceCallback(consumer *kafka.Consumer, event ka
 	case kafka.AssignedPartitions:
 		i.Logger.Warnf("consumer rebalance event type: %d new partition(s) assigned: %v\n",
 			len(ev.Partitions), ev.Partitions)
+		i.Workers.Start(ev.Partitions)
+		// newly assigned partitions are paused on the next poll if the circuit breaker is open
+		i.paused = false
 
 	case kafka.RevokedPartitions:
 		i.Logger.Warnf("consumer rebalance event: %d partition(s) revoked: %v\n",
 			len(ev.Partitions), ev.Partitions)
 
+		// in-flight requests are canceled and the workers exit before the final commit for the revoked partitions
+		i.Workers.Stop(ev.Partitions)
 		if i.Consumer.AssignmentLost() {
 			i.Logger.Warn("Assignment lost involuntarily, commit may fail")
 		}
 		err := i.CommitStoredOffsets()
+		// offsets for revoked partitions can no longer be committed by this consumer
+		i.OffsetStorage.Remove(ev.Partitions)
 		if err != nil {
 			i.Logger.Errorf("failed to commit offsets: %v", err)
 			return err
@@ -509,3 +837,15 @@ func (i *InventoryConsumer) RebalanceCallback(consumer *kafka.Consumer, event ka
 	}
 	return nil
 }
+
+// sleep waits for the given duration, returning the context error early if ctx is canceled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,12 +1,15 @@
 package consumer
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
 	"github.com/project-kessel/inventory-consumer/internal/mocks"
 	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
 	"google.golang.org/grpc/codes"
@@ -135,6 +138,29 @@ func TestNewConsumerSetup(t *testing.T) {
 	assert.Nil(t, errs)
 }
 
+// rateLimitedClient is a client that reports the time requests wait for its rate limiter
+type rateLimitedClient struct {
+	mocks.MockClient
+	observe func(operation string, wait time.Duration)
+}
+
+func (c *rateLimitedClient) SetRateLimitObserver(observe func(operation string, wait time.Duration)) {
+	c.observe = observe
+}
+
+func TestNewConsumerSetup_RateLimitObserver(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	// the time requests wait for the client rate limiter is recorded by the consumer metrics
+	client := &rateLimitedClient{}
+	_, err := New(tester.completedConfig, client, tester.logger, &mocks.MockConsumer{})
+	assert.Nil(t, err)
+	assert.NotNil(t, client.observe)
+	client.observe(OperationTypeReportResource, time.Second)
+}
+
 func TestInventoryConsumer_Retry(t *testing.T) {
 	tests := []struct {
 		description    string
@@ -165,13 +191,86 @@ func TestInventoryConsumer_Retry(t *testing.T) {
 			errs := tester.TestSetup()
 			assert.Nil(t, errs)
 
-			result, err := tester.inv.Retry(test.funcToExecute)
+			result, err := tester.inv.Retry(context.Background(), test.funcToExecute)
 			assert.Equal(t, test.expectedResult, result)
 			assert.Equal(t, test.expectedErr, err)
 		})
 	}
 }
 
+func TestInventoryConsumer_RetryTerminalErrors(t *testing.T) {
+	tests := []struct {
+		description      string
+		err              error
+		expectedAttempts int
+		expectTerminal   bool
+	}{
+		{
+			description:      "terminal gRPC errors are not retried",
+			err:              status.Error(codes.InvalidArgument, "invalid resource"),
+			expectedAttempts: 1,
+			expectTerminal:   true,
+		},
+		{
+			description:      "retryable gRPC errors are retried until max retries is reached",
+			err:              status.Error(codes.Unavailable, "unavailable"),
+			expectedAttempts: 3,
+			expectTerminal:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+			tester.inv.RetryOptions.BackoffFactor = 0
+
+			attempts := 0
+			_, err := tester.inv.Retry(context.Background(), func() (interface{}, error) {
+				attempts++
+				return nil, test.err
+			})
+			assert.Equal(t, test.expectedAttempts, attempts)
+			if test.expectTerminal {
+				var unprocessable *UnprocessableError
+				assert.ErrorAs(t, err, &unprocessable)
+				assert.Equal(t, test.expectedAttempts, unprocessable.Attempts)
+				assert.ErrorIs(t, err, test.err)
+			} else {
+				assert.Equal(t, ErrMaxRetries, err)
+			}
+		})
+	}
+}
+
+func TestInventoryConsumer_RetryCanceled(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+	tester.inv.RetryOptions.BackoffFactor = 100
+	tester.inv.RetryOptions.MaxBackoffSeconds = 30
+
+	// the backoff is interrupted instead of slept through once the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	start := time.Now()
+	_, err := tester.inv.Retry(ctx, func() (interface{}, error) {
+		attempts++
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// requests failing because the context was canceled are not dead-lettered
+	_, err = tester.inv.Retry(ctx, func() (interface{}, error) {
+		return nil, status.Error(codes.Canceled, "context canceled")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
 func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -193,7 +292,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			},
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
-				client.On("CreateOrUpdateResource", mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
 			},
 			expectError: false,
 		},
@@ -207,7 +306,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			},
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
-				client.On("CreateOrUpdateResource", mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
 			},
 			expectError: false,
 		},
@@ -221,7 +320,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			},
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
-				client.On("DeleteResource", mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil)
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil)
 			},
 			expectError: false,
 		},
@@ -236,7 +335,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
 				// Return NotFound error on first attempt, which should cause message to be dropped
-				client.On("DeleteResource", mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, status.Error(codes.NotFound, "resource not found"))
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, status.Error(codes.NotFound, "resource not found"))
 			},
 			expectError: false,
 		},
@@ -271,7 +370,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			},
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
-				client.On("CreateOrUpdateResource", mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
 			},
 			expectError: false,
 		},
@@ -285,7 +384,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			},
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
-				client.On("DeleteResource", mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil)
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil)
 			},
 			expectError: false,
 		},
@@ -300,7 +399,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
 				// Return NotFound error on first attempt, which should cause message to be dropped
-				client.On("DeleteResource", mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, status.Error(codes.NotFound, "resource not found"))
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, status.Error(codes.NotFound, "resource not found"))
 			},
 			expectError: false,
 		},
@@ -315,8 +414,8 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
 				// Fail first attempt, succeed on second
-				client.On("CreateOrUpdateResource", mock.Anything).Return(&v1beta2.ReportResourceResponse{}, errors.New("temporary error")).Once()
-				client.On("CreateOrUpdateResource", mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil).Once()
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, errors.New("temporary error")).Once()
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil).Once()
 			},
 			expectError: false,
 		},
@@ -331,8 +430,8 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 			clientEnabled: true,
 			setupMock: func(client *mocks.MockClient) {
 				// Fail first attempt with non-NotFound error, succeed on second
-				client.On("DeleteResource", mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, errors.New("temporary error")).Once()
-				client.On("DeleteResource", mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil).Once()
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, errors.New("temporary error")).Once()
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil).Once()
 			},
 			expectError: false,
 		},
@@ -369,7 +468,7 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 				assert.Equal(t, parsedHeaders.Version, test.expectedVersion)
 			}
 
-			err = tester.inv.ProcessMessage(parsedHeaders, test.msg)
+			err = tester.inv.ProcessMessage(context.Background(), parsedHeaders, test.msg)
 			if test.expectProcessingError {
 				assert.NotNil(t, err)
 			} else {
@@ -381,84 +480,231 @@ func TestInventoryConsumer_ProcessMessage(t *testing.T) {
 		})
 	}
 }
-func TestCheckIfCommit(t *testing.T) {
+
+// groupTransformer is a stand-in for a transformer registered for another captured table
+type groupTransformer struct{}
+
+func (groupTransformer) IsDeleted(msgValue []byte) (bool, error) {
+	return len(msgValue) == 0, nil
+}
+
+func (groupTransformer) ToReportResourceRequest(ctx context.Context, msgValue []byte) (*v1beta2.ReportResourceRequest, error) {
+	return &v1beta2.ReportResourceRequest{Type: "workspace"}, nil
+}
+
+func (groupTransformer) ToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error) {
+	return nil, errors.New("groups can not be deleted")
+}
+
+func TestInventoryConsumer_MigrationTransformers(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		msg          *kafka.Message
+		setupMock    func(client *mocks.MockClient)
+		expectStage  string
+	}{
+		{
+			name:         "resource type header selects the registered transformer",
+			resourceType: "workspace",
+			msg:          &kafka.Message{Value: []byte(`{}`)},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("CreateOrUpdateResource", mock.Anything, &v1beta2.ReportResourceRequest{Type: "workspace"}).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:         "transformer errors are unprocessable",
+			resourceType: "workspace",
+			msg:          &kafka.Message{Value: []byte("")},
+			setupMock:    func(client *mocks.MockClient) {},
+			expectStage:  "TransformToDeleteResourceRequest",
+		},
+		{
+			name:         "unregistered resource type is unprocessable",
+			resourceType: "unknown",
+			msg:          &kafka.Message{Value: []byte(`{}`)},
+			setupMock:    func(client *mocks.MockClient) {},
+			expectStage:  "LookupTransformer",
+		},
+		{
+			name: "messages without a resource type use the host transformer",
+			msg: &kafka.Message{
+				Key:   []byte(testMigrationKey),
+				Value: []byte(testMigrationMessage),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name: "raw Debezium envelopes are reported from the after state",
+			msg: &kafka.Message{
+				Key:   []byte(testMigrationKey),
+				Value: []byte(`{"payload":{"before":null,"after":{"id":"00000000-0000-0000-0000-000000000000","groups":"[{\"id\":\"00000000-0000-0000-0000-000000000000\"}]"},"op":"c","source":{"table":"hosts"}}}`),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("CreateOrUpdateResource", mock.Anything, mock.MatchedBy(func(req *v1beta2.ReportResourceRequest) bool {
+					return req.GetRepresentations().GetMetadata().GetLocalResourceId() == "00000000-0000-0000-0000-000000000000"
+				})).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name: "raw Debezium delete envelopes are deleted",
+			msg: &kafka.Message{
+				Key:   []byte(testMigrationKey),
+				Value: []byte(`{"payload":{"before":{"id":"00000000-0000-0000-0000-000000000000"},"after":null,"op":"d","source":{"table":"hosts"}}}`),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("DeleteResource", mock.Anything, mock.Anything).Return(&v1beta2.DeleteResourceResponse{}, nil)
+			},
+		},
+		{
+			name: "raw Debezium envelopes with unsupported operations are unprocessable",
+			msg: &kafka.Message{
+				Key:   []byte(testMigrationKey),
+				Value: []byte(`{"payload":{"before":null,"after":null,"op":"t"}}`),
+			},
+			setupMock:   func(client *mocks.MockClient) {},
+			expectStage: "ParseDebeziumEnvelope",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+
+			client := &mocks.MockClient{}
+			client.On("IsEnabled").Return(true)
+			test.setupMock(client)
+			tester.inv.Client = client
+
+			registry := transforms.NewDefaultRegistry()
+			assert.Nil(t, registry.Register("workspace", groupTransformer{}))
+			tester.inv.Transformers = registry
+
+			test.msg.TopicPartition = kafka.TopicPartition{Topic: ToPointer("outbox.event.hbi.groups")}
+			if test.resourceType != "" {
+				test.msg.Headers = []kafka.Header{{Key: ResourceTypeHeader, Value: []byte(test.resourceType)}}
+			}
+
+			err := tester.inv.ProcessMessage(context.Background(), EventHeaders{Operation: OperationTypeMigration, Version: defaultApiVersion}, test.msg)
+			if test.expectStage != "" {
+				var unprocessable *UnprocessableError
+				assert.ErrorAs(t, err, &unprocessable)
+				assert.Equal(t, test.expectStage, unprocessable.Stage)
+			} else {
+				assert.Nil(t, err)
+			}
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryConsumer_CommitIfDue(t *testing.T) {
 	tests := []struct {
-		name      string
-		partition kafka.TopicPartition
-		expected  bool
+		name          string
+		processed     int
+		storedOffsets []kafka.TopicPartition
+		expectCommit  bool
 	}{
 		{
-			name: "modulus of the partition offset equates to true",
-			partition: kafka.TopicPartition{
-				Offset: kafka.Offset(10),
+			name:      "stored offsets are committed once the commit count is reached",
+			processed: 10,
+			storedOffsets: []kafka.TopicPartition{
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(7), Partition: 0},
 			},
-			expected: true,
+			expectCommit: true,
 		},
 		{
-			name: "modulus of the partition offset does not equate to true",
-			partition: kafka.TopicPartition{
-				Offset: kafka.Offset(1),
+			name:      "stored offsets are not committed before the commit count is reached",
+			processed: 1,
+			storedOffsets: []kafka.TopicPartition{
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(7), Partition: 0},
 			},
-			expected: false,
+			expectCommit: false,
+		},
+		{
+			name:         "nothing is committed when there are no stored offsets",
+			processed:    10,
+			expectCommit: false,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actual := CheckIfCommit(test.partition)
-			assert.Equal(t, test.expected, actual)
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+
+			c := &mocks.MockConsumer{}
+			if test.expectCommit {
+				c.On("CommitOffsets", mock.Anything).Return([]kafka.TopicPartition{}, nil)
+			}
+			tester.inv.Consumer = c
+			for _, offset := range test.storedOffsets {
+				tester.inv.OffsetStorage.Store(offset)
+			}
+			for range test.processed {
+				tester.inv.CommitPolicy.Processed()
+			}
+
+			tester.inv.commitIfDue()
+			if test.expectCommit {
+				assert.Equal(t, 0, tester.inv.OffsetStorage.Len())
+				assert.False(t, tester.inv.CommitPolicy.ShouldCommit(time.Now()))
+			} else {
+				c.AssertNotCalled(t, "CommitOffsets", mock.Anything)
+			}
+			c.AssertExpectations(t)
 		})
 	}
 }
 
 func TestCommitStoredOffsets(t *testing.T) {
 	tests := []struct {
-		name                                      string
-		storedOffsets, response, remainingOffsets []kafka.TopicPartition
-		responseErr                               error
+		name                                       string
+		storedOffsets, committed, remainingOffsets []kafka.TopicPartition
+		responseErr                                error
 	}{
 		{
 			name: "single stored offset is committed without error",
 			storedOffsets: []kafka.TopicPartition{
-				{Offset: kafka.Offset(10), Partition: 0},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(10), Partition: 0},
 			},
-			response: []kafka.TopicPartition{
-				{Offset: kafka.Offset(10), Partition: 0},
+			committed: []kafka.TopicPartition{
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(11), Partition: 0},
 			},
-			remainingOffsets: nil,
+			remainingOffsets: []kafka.TopicPartition{},
 			responseErr:      nil,
 		},
 		{
-			name: "all stored offsets are committed without error",
+			name: "next offset after the latest stored offset for each partition is committed without error",
 			storedOffsets: []kafka.TopicPartition{
-				{Offset: kafka.Offset(10), Partition: 0},
-				{Offset: kafka.Offset(11), Partition: 0},
-				{Offset: kafka.Offset(1), Partition: 1},
-				{Offset: kafka.Offset(2), Partition: 1},
-				{Offset: kafka.Offset(12), Partition: 0},
-				{Offset: kafka.Offset(13), Partition: 0},
-				{Offset: kafka.Offset(3), Partition: 1},
-				{Offset: kafka.Offset(4), Partition: 1},
-			},
-			response: []kafka.TopicPartition{
-				{Offset: kafka.Offset(10), Partition: 0},
-				{Offset: kafka.Offset(11), Partition: 0},
-				{Offset: kafka.Offset(1), Partition: 1},
-				{Offset: kafka.Offset(2), Partition: 1},
-				{Offset: kafka.Offset(12), Partition: 0},
-				{Offset: kafka.Offset(13), Partition: 0},
-				{Offset: kafka.Offset(3), Partition: 1},
-				{Offset: kafka.Offset(4), Partition: 1},
-			},
-			remainingOffsets: nil,
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(10), Partition: 0},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(11), Partition: 0},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(1), Partition: 1},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(2), Partition: 1},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(12), Partition: 0},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(13), Partition: 0},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(3), Partition: 1},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(4), Partition: 1},
+			},
+			committed: []kafka.TopicPartition{
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(14), Partition: 0},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(5), Partition: 1},
+			},
+			remainingOffsets: []kafka.TopicPartition{},
 			responseErr:      nil,
 		},
 		{
 			name: "Consumer.CommitOffsets returns error; offset storage is not cleared",
 			storedOffsets: []kafka.TopicPartition{
-				{Offset: kafka.Offset(10), Partition: 1},
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(10), Partition: 1},
+			},
+			committed: []kafka.TopicPartition{
+				{Topic: ToPointer("test-topic"), Offset: kafka.Offset(11), Partition: 1},
 			},
-			response:         nil,
-			remainingOffsets: []kafka.TopicPartition{{Offset: kafka.Offset(10), Partition: 1}},
+			remainingOffsets: []kafka.TopicPartition{{Topic: ToPointer("test-topic"), Offset: kafka.Offset(10), Partition: 1}},
 			responseErr:      errors.New("commit failed"),
 		},
 	}
@@ -469,14 +715,17 @@ func TestCommitStoredOffsets(t *testing.T) {
 			assert.Nil(t, errs)
 
 			c := &mocks.MockConsumer{}
-			c.On("CommitOffsets", mock.Anything).Return(test.response, test.responseErr)
+			c.On("CommitOffsets", test.committed).Return(test.committed, test.responseErr)
 			tester.inv.Consumer = c
-			tester.inv.OffsetStorage = test.storedOffsets
+			for _, offset := range test.storedOffsets {
+				tester.inv.OffsetStorage.Store(offset)
+			}
 
 			err := tester.inv.CommitStoredOffsets()
 			assert.Equal(t, err, test.responseErr)
-			assert.Equal(t, len(tester.inv.OffsetStorage), len(test.remainingOffsets))
-			assert.Equal(t, tester.inv.OffsetStorage, test.remainingOffsets)
+			assert.Equal(t, len(test.remainingOffsets), tester.inv.OffsetStorage.Len())
+			assert.Equal(t, test.remainingOffsets, tester.inv.OffsetStorage.Pending())
+			c.AssertExpectations(t)
 		})
 	}
 }
@@ -562,9 +811,7 @@ func TestInventoryConsumer_RebalanceCallback(t *testing.T) {
 			tester.inv.Consumer = mockConsumer
 
 			// Add some offsets to storage to simulate having stored offsets
-			tester.inv.OffsetStorage = []kafka.TopicPartition{
-				{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(5)},
-			}
+			tester.inv.OffsetStorage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(5)})
 
 			// Call the RebalanceCallback method
 			err := tester.inv.RebalanceCallback(nil, test.event)
@@ -572,6 +819,14 @@ func TestInventoryConsumer_RebalanceCallback(t *testing.T) {
 			// Assert expectations
 			assert.Equal(t, test.expectedError, err)
 			mockConsumer.AssertExpectations(t)
+
+			// Workers only run for partitions that are currently assigned
+			if _, isAssigned := test.event.(kafka.AssignedPartitions); isAssigned {
+				assert.Equal(t, 1, tester.inv.Workers.Len())
+			} else {
+				assert.Equal(t, 0, tester.inv.Workers.Len())
+			}
+			tester.inv.Workers.StopAll()
 		})
 	}
 }
@@ -736,12 +991,8 @@ func TestInventoryConsumer_Shutdown(t *testing.T) {
 
 			// Set up offset storage based on test requirements
 			if test.hasStoredOffsets {
-				tester.inv.OffsetStorage = []kafka.TopicPartition{
-					{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(5)},
-					{Topic: ToPointer("test-topic"), Partition: 1, Offset: kafka.Offset(10)},
-				}
-			} else {
-				tester.inv.OffsetStorage = []kafka.TopicPartition{}
+				tester.inv.OffsetStorage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(5)})
+				tester.inv.OffsetStorage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 1, Offset: kafka.Offset(10)})
 			}
 
 			// Call the Shutdown method
@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// saslCredentials holds the SASL password so it can be rotated while the consumer runs. The version is incremented
+// each time the password is rotated so consumers can tell whether they are using the latest password.
+type saslCredentials struct {
+	mu       sync.Mutex
+	password string
+	version  int
+}
+
+func (s *saslCredentials) get() (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.password, s.version
+}
+
+func (s *saslCredentials) set(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+	s.version++
+}
+
+// saslRotator is implemented by kafka consumers and producers that can change their SASL credentials without
+// reconnecting
+type saslRotator interface {
+	SetSaslCredentials(username, password string) error
+}
+
+// SetSASLPassword rotates the SASL password used to authenticate to Kafka. Running consumers and producers use it the
+// next time they authenticate to a broker, and consumers created when the consumer restarts are configured with it.
+func (c CompletedConfig) SetSASLPassword(password string) {
+	if c.completedConfig == nil || c.sasl == nil {
+		return
+	}
+	c.sasl.set(password)
+}
+
+// rotateSASLPassword sets a rotated SASL password on the kafka configs and returns the version of the password
+func (c CompletedConfig) rotateSASLPassword() (string, int, error) {
+	if c.AuthConfig == nil {
+		return "", 0, nil
+	}
+	if c.sasl == nil || !c.AuthConfig.Enabled {
+		return c.AuthConfig.SASLPassword, 0, nil
+	}
+	password, version := c.sasl.get()
+	if version == 0 {
+		return password, 0, nil
+	}
+	for _, config := range []*kafka.ConfigMap{c.KafkaConfig, c.ProducerConfig} {
+		if config == nil {
+			continue
+		}
+		if err := config.SetKey("sasl.password", password); err != nil {
+			return "", 0, fmt.Errorf("cannot set sasl.password value: %w", err)
+		}
+	}
+	return password, version, nil
+}
+
+// applySASLCredentials updates the consumer and producer if the SASL password was rotated since they were created
+func (i *InventoryConsumer) applySASLCredentials() {
+	if i.Config.completedConfig == nil || i.Config.sasl == nil || i.AuthOptions == nil || !i.AuthOptions.Enabled {
+		return
+	}
+	password, version := i.Config.sasl.get()
+	if version == i.saslVersion {
+		return
+	}
+	i.saslVersion = version
+	i.AuthOptions.SASLPassword = password
+
+	clients := []struct {
+		name   string
+		client interface{}
+	}{{"consumer", i.Consumer}, {"producer", i.Producer}}
+	for _, c := range clients {
+		rotator, ok := c.client.(saslRotator)
+		if !ok {
+			continue
+		}
+		if err := rotator.SetSaslCredentials(i.AuthOptions.SASLUsername, password); err != nil {
+			i.Logger.Errorf("failed to rotate SASL credentials of the kafka %s: %v", c.name, err)
+			continue
+		}
+		i.Logger.Infof("rotated SASL credentials of the kafka %s", c.name)
+	}
+}
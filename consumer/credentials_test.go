@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSASLTestConsumer(t *testing.T) (InventoryConsumer, *mocks.MockConsumer) {
+	options := NewOptions()
+	options.BootstrapServers = []string{"localhost:9092"}
+	options.Topics = []string{"test-topic"}
+	options.AuthOptions.Enabled = true
+	options.AuthOptions.SecurityProtocol = "SASL_SSL"
+	options.AuthOptions.SASLMechanism = "SCRAM-SHA-512"
+	options.AuthOptions.SASLUsername = "kic"
+	options.AuthOptions.SASLPassword = "first"
+
+	config, errs := NewConfig(options).Complete()
+	assert.Nil(t, errs)
+
+	_, logger := InitLogger("info", LoggerOptions{})
+	consumer := &mocks.MockConsumer{}
+	inv, err := New(config, nil, log.NewHelper(log.With(logger, "subsystem", "inventoryConsumer")), consumer)
+	assert.NoError(t, err)
+	return inv, consumer
+}
+
+func TestInventoryConsumer_ApplySASLCredentials(t *testing.T) {
+	inv, consumer := newSASLTestConsumer(t)
+	producer := &mocks.MockProducer{}
+	inv.Producer = producer
+
+	// nothing is rotated until the password changes
+	inv.applySASLCredentials()
+	consumer.AssertNotCalled(t, "SetSaslCredentials")
+
+	consumer.On("SetSaslCredentials", "kic", "second").Return(nil).Once()
+	producer.On("SetSaslCredentials", "kic", "second").Return(nil).Once()
+	inv.Config.SetSASLPassword("second")
+	inv.applySASLCredentials()
+	inv.applySASLCredentials()
+
+	consumer.AssertExpectations(t)
+	producer.AssertExpectations(t)
+	assert.Equal(t, "second", inv.AuthOptions.SASLPassword)
+}
+
+func TestNew_RotatedSASLPassword(t *testing.T) {
+	inv, _ := newSASLTestConsumer(t)
+	inv.Config.SetSASLPassword("second")
+
+	// consumers created when the consumer restarts use the rotated password
+	restarted, err := New(inv.Config, nil, inv.Logger, &mocks.MockConsumer{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, restarted.saslVersion)
+	assert.Equal(t, "second", restarted.AuthOptions.SASLPassword)
+
+	password, err := inv.Config.KafkaConfig.Get("sasl.password", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", password)
+}
+
+func TestCompletedConfig_SetSASLPasswordWithoutAuth(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	tester.completedConfig.SetSASLPassword("second")
+	_, version, err := tester.completedConfig.rotateSASLPassword()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	// a config that was not completed is ignored
+	CompletedConfig{}.SetSASLPassword("second")
+}
@@ -0,0 +1,135 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
+)
+
+// Headers added to every message produced to the dead-letter topic, alongside the original message headers
+const (
+	DeadLetterHeaderError           = "dlq-error"
+	DeadLetterHeaderStage           = "dlq-stage"
+	DeadLetterHeaderSourceTopic     = "dlq-source-topic"
+	DeadLetterHeaderSourcePartition = "dlq-source-partition"
+	DeadLetterHeaderSourceOffset    = "dlq-source-offset"
+	DeadLetterHeaderAttempts        = "dlq-attempts"
+)
+
+// ErrDeadLetterTimeout is returned when the delivery of a dead-lettered message is not reported within the
+// dead-letter timeout. The message is not committed and is retried instead.
+var ErrDeadLetterTimeout = errors.New("timed out waiting for dead-letter delivery")
+
+// Producer defines the kafka producer methods used to publish messages to the dead-letter topic
+type Producer interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	Flush(timeoutMs int) int
+	Close()
+}
+
+// UnprocessableError is returned when a message can never be processed as-is, such as when its headers or payload
+// cannot be parsed or transformed. Unlike request failures, retrying these messages will not succeed.
+type UnprocessableError struct {
+	// Stage is the processing step that failed, matching the operation label used for failure metrics
+	Stage string
+	// Attempts is the number of times processing was attempted
+	Attempts int
+	Err      error
+}
+
+func (e *UnprocessableError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *UnprocessableError) Unwrap() error {
+	return e.Err
+}
+
+// NewUnprocessableError wraps err as an UnprocessableError for the given stage
+func NewUnprocessableError(stage string, err error) *UnprocessableError {
+	return &UnprocessableError{Stage: stage, Attempts: 1, Err: err}
+}
+
+// DeadLetterEnabled returns true when a dead-letter topic is configured for the consumer or any topic and a producer
+// is available
+func (i *InventoryConsumer) DeadLetterEnabled() bool {
+	return i.Config.hasDeadLetterTopic() && i.Producer != nil
+}
+
+// DeadLetter produces a failed message to the dead-letter topic with its original key, value and headers plus
+// headers describing the failure, and waits for the delivery report until the dead-letter timeout or until ctx is
+// canceled. The message is produced to the dead-letter topic configured for its topic, or else to the consumer
+// dead-letter topic
+func (i *InventoryConsumer) DeadLetter(ctx context.Context, msg *kafka.Message, failure *UnprocessableError) error {
+	topic := i.deadLetterTopic(msg)
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	headers = append(headers, msg.Headers...)
+
+	var sourceTopic string
+	if msg.TopicPartition.Topic != nil {
+		sourceTopic = *msg.TopicPartition.Topic
+	}
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterHeaderError, Value: []byte(failure.Err.Error())},
+		kafka.Header{Key: DeadLetterHeaderStage, Value: []byte(failure.Stage)},
+		kafka.Header{Key: DeadLetterHeaderSourceTopic, Value: []byte(sourceTopic)},
+		kafka.Header{Key: DeadLetterHeaderSourcePartition, Value: []byte(strconv.Itoa(int(msg.TopicPartition.Partition)))},
+		kafka.Header{Key: DeadLetterHeaderSourceOffset, Value: []byte(msg.TopicPartition.Offset.String())},
+		kafka.Header{Key: DeadLetterHeaderAttempts, Value: []byte(strconv.Itoa(max(failure.Attempts, 1)))},
+	)
+
+	deliveryChan := make(chan kafka.Event, 1)
+	err := i.Producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	}, deliveryChan)
+	if err != nil {
+		return fmt.Errorf("failed to produce message to dead-letter topic: %w", err)
+	}
+
+	timeout := time.NewTimer(time.Duration(i.Config.DeadLetterTimeoutMs) * time.Millisecond)
+	defer timeout.Stop()
+	var event kafka.Event
+	select {
+	case event = <-deliveryChan:
+	case <-timeout.C:
+		return fmt.Errorf("%w after %dms", ErrDeadLetterTimeout, i.Config.DeadLetterTimeoutMs)
+	case <-ctx.Done():
+		return fmt.Errorf("canceled waiting for dead-letter delivery: %w", ctx.Err())
+	}
+	delivered, ok := event.(*kafka.Message)
+	if !ok {
+		return fmt.Errorf("unexpected dead-letter delivery event: %v", event)
+	}
+	if delivered.TopicPartition.Error != nil {
+		return fmt.Errorf("failed to deliver message to dead-letter topic: %w", delivered.TopicPartition.Error)
+	}
+	return nil
+}
+
+// handleUnprocessable routes an unprocessable message to the dead-letter topic when one is configured
+// It returns nil if the message was dead-lettered and can be committed, otherwise the original or produce error
+func (i *InventoryConsumer) handleUnprocessable(ctx context.Context, msg *kafka.Message, err error) error {
+	var failure *UnprocessableError
+	if !errors.As(err, &failure) || i.Producer == nil || i.deadLetterTopic(msg) == "" {
+		return err
+	}
+
+	if dlqErr := i.DeadLetter(ctx, msg, failure); dlqErr != nil {
+		i.Logger.Errorf("failed to dead-letter message: topic=%s partition=%d offset=%s: %v",
+			*msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset, dlqErr)
+		return errors.Join(err, dlqErr)
+	}
+	metricscollector.Incr(i.MetricsCollector.MsgsDeadLettered, failure.Stage, failure.Err)
+	i.Logger.Warnf("message sent to dead-letter topic %s: topic=%s partition=%d offset=%s stage=%s error=%v",
+		i.deadLetterTopic(msg), *msg.TopicPartition.Topic, msg.TopicPartition.Partition, msg.TopicPartition.Offset,
+		failure.Stage, failure.Err)
+	return nil
+}
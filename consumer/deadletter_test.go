@@ -0,0 +1,194 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInventoryConsumer_ProcessPartitionMessageDeadLetter(t *testing.T) {
+	tests := []struct {
+		name            string
+		deadLetterTopic string
+		headers         []kafka.Header
+		value           string
+		produceErr      error
+		expectProduce   bool
+		expectedStage   string
+		expectError     bool
+	}{
+		{
+			name:            "missing headers are dead-lettered",
+			deadLetterTopic: "test-topic.dlq",
+			headers:         []kafka.Header{{Key: "version", Value: []byte(defaultApiVersion)}},
+			value:           testCreateOrUpdateMessage,
+			expectProduce:   true,
+			expectedStage:   "ParseHeaders",
+			expectError:     false,
+		},
+		{
+			name:            "invalid payload is dead-lettered",
+			deadLetterTopic: "test-topic.dlq",
+			headers: []kafka.Header{
+				{Key: "operation", Value: []byte(OperationTypeReportResource)},
+				{Key: "version", Value: []byte(defaultApiVersion)},
+			},
+			value:         `{invalid`,
+			expectProduce: true,
+			expectedStage: "ParseCreateOrUpdateMessage",
+			expectError:   false,
+		},
+		{
+			name:            "failure to produce to the dead-letter topic returns an error",
+			deadLetterTopic: "test-topic.dlq",
+			headers:         []kafka.Header{{Key: "version", Value: []byte(defaultApiVersion)}},
+			value:           testCreateOrUpdateMessage,
+			produceErr:      errors.New("produce failed"),
+			expectProduce:   true,
+			expectedStage:   "ParseHeaders",
+			expectError:     true,
+		},
+		{
+			name:          "unprocessable message returns an error when no dead-letter topic is configured",
+			headers:       []kafka.Header{{Key: "version", Value: []byte(defaultApiVersion)}},
+			value:         testCreateOrUpdateMessage,
+			expectProduce: false,
+			expectError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+
+			client := &mocks.MockClient{}
+			client.On("IsEnabled").Return(false).Maybe()
+			tester.inv.Client = client
+
+			producer := &mocks.MockProducer{}
+			var produced *kafka.Message
+			if test.expectProduce {
+				producer.On("Produce", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+					produced = args.Get(0).(*kafka.Message)
+				}).Return(test.produceErr)
+			}
+			tester.inv.Producer = producer
+			tester.inv.Config.DeadLetterTopic = test.deadLetterTopic
+
+			msg := &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 2, Offset: kafka.Offset(42)},
+				Key:            []byte(testMessageKey),
+				Value:          []byte(test.value),
+				Headers:        test.headers,
+			}
+			err := tester.inv.processPartitionMessage(context.Background(), msg)
+			if test.expectError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			producer.AssertExpectations(t)
+			if test.expectProduce {
+				assert.Equal(t, test.deadLetterTopic, *produced.TopicPartition.Topic)
+				assert.Equal(t, msg.Key, produced.Key)
+				assert.Equal(t, msg.Value, produced.Value)
+				assert.Equal(t, test.expectedStage, headerValue(produced.Headers, DeadLetterHeaderStage))
+				assert.Equal(t, "test-topic", headerValue(produced.Headers, DeadLetterHeaderSourceTopic))
+				assert.Equal(t, "2", headerValue(produced.Headers, DeadLetterHeaderSourcePartition))
+				assert.Equal(t, "42", headerValue(produced.Headers, DeadLetterHeaderSourceOffset))
+				assert.Equal(t, "1", headerValue(produced.Headers, DeadLetterHeaderAttempts))
+				assert.NotEmpty(t, headerValue(produced.Headers, DeadLetterHeaderError))
+				assert.Equal(t, defaultApiVersion, headerValue(produced.Headers, "version"))
+			}
+		})
+	}
+}
+
+func TestInventoryConsumer_TerminalRequestErrorsAreDeadLettered(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, status.Error(codes.PermissionDenied, "reporter not allowed"))
+	tester.inv.Client = client
+
+	producer := &mocks.MockProducer{}
+	var produced *kafka.Message
+	producer.On("Produce", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		produced = args.Get(0).(*kafka.Message)
+	}).Return(nil)
+	tester.inv.Producer = producer
+	tester.inv.Config.DeadLetterTopic = "test-topic.dlq"
+
+	err := tester.inv.processPartitionMessage(context.Background(), &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic")},
+		Headers: []kafka.Header{
+			{Key: "operation", Value: []byte(OperationTypeReportResource)},
+			{Key: "version", Value: []byte(defaultApiVersion)},
+		},
+		Value: []byte(testCreateOrUpdateMessage),
+	})
+	assert.Nil(t, err)
+	client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 1)
+	producer.AssertExpectations(t)
+	assert.Equal(t, OperationTypeReportResource, headerValue(produced.Headers, DeadLetterHeaderStage))
+	assert.Equal(t, "1", headerValue(produced.Headers, DeadLetterHeaderAttempts))
+}
+
+// undeliveredProducer accepts messages but never reports their delivery
+type undeliveredProducer struct{}
+
+func (undeliveredProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	return nil
+}
+func (undeliveredProducer) Flush(timeoutMs int) int { return 0 }
+func (undeliveredProducer) Close()                  {}
+
+func TestInventoryConsumer_DeadLetterTimeout(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+	tester.inv.Producer = undeliveredProducer{}
+	tester.inv.Config.DeadLetterTopic = "test-topic.dlq"
+	tester.inv.Config.DeadLetterTimeoutMs = 10
+	msg := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic")}}
+	failure := NewUnprocessableError("ParseHeaders", errors.New("missing headers"))
+
+	// the message is retried instead of committed when its delivery is not reported in time
+	err := tester.inv.DeadLetter(context.Background(), msg, failure)
+	assert.ErrorIs(t, err, ErrDeadLetterTimeout)
+	err = tester.inv.handleUnprocessable(context.Background(), msg, failure)
+	assert.ErrorIs(t, err, ErrDeadLetterTimeout)
+
+	// stopping the partition stops waiting for the delivery
+	tester.inv.Config.DeadLetterTimeoutMs = 60000
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = tester.inv.DeadLetter(ctx, msg, failure)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestUnprocessableError(t *testing.T) {
+	cause := errors.New("bad payload")
+	err := NewUnprocessableError("ParseDeleteMessage", cause)
+
+	var failure *UnprocessableError
+	assert.True(t, errors.As(error(err), &failure))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, 1, failure.Attempts)
+	assert.Equal(t, "ParseDeleteMessage: bad payload", err.Error())
+}
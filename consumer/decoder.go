@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/schemaregistry"
+	metricscollector "github.com/project-kessel/inventory-consumer/metrics"
+)
+
+// Decoder decodes message keys and values into the JSON handled by the parsers and transforms, such as
+// schemaregistry.Decoder for messages serialized with Avro or Protobuf
+type Decoder interface {
+	Decode(data []byte) ([]byte, error)
+}
+
+// decodeMessage returns a copy of the message with its key and value decoded by the consumer's Decoder
+// The original message is left unchanged so unprocessable messages are dead-lettered as they were consumed
+func (i *InventoryConsumer) decodeMessage(msg *kafka.Message) (*kafka.Message, error) {
+	if i.Decoder == nil {
+		return msg, nil
+	}
+	key, err := i.Decoder.Decode(msg.Key)
+	if err == nil {
+		decoded := *msg
+		decoded.Key = key
+		decoded.Value, err = i.Decoder.Decode(msg.Value)
+		if err == nil {
+			return &decoded, nil
+		}
+	}
+
+	metricscollector.Incr(i.MetricsCollector.MsgProcessFailures, "DecodeMessage", err)
+	i.Logger.Errorf("failed to decode message: %v", err)
+	if errors.Is(err, schemaregistry.ErrRegistryUnavailable) {
+		// the schema could not be looked up yet, so the message is retried instead of dead-lettered
+		return nil, err
+	}
+	return nil, NewUnprocessableError("DecodeMessage", err)
+}
+
+// laneKey returns the decoded key of a message, so updates to the same resource are processed in order however their
+// key is serialized. Keys that can not be decoded are routed by their raw bytes, since the message is dead-lettered by
+// its worker; if the registry is unavailable, the error stops the partition so the message is retried.
+func (i *InventoryConsumer) laneKey(msg *kafka.Message) ([]byte, error) {
+	if i.Decoder == nil {
+		return msg.Key, nil
+	}
+	key, err := i.Decoder.Decode(msg.Key)
+	if errors.Is(err, schemaregistry.ErrRegistryUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return msg.Key, nil
+	}
+	return key, nil
+}
@@ -0,0 +1,177 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/schemaregistry"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// prefixDecoder decodes data by stripping a prefix, standing in for a schema registry decoder
+type prefixDecoder struct {
+	prefix []byte
+	err    error
+}
+
+func (d prefixDecoder) Decode(data []byte) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return bytes.TrimPrefix(data, d.prefix), nil
+}
+
+func TestInventoryConsumer_DecodeMessage(t *testing.T) {
+	prefix := []byte{0, 0, 0, 0, 1}
+	tests := []struct {
+		name              string
+		decoder           Decoder
+		setupMock         func(client *mocks.MockClient)
+		expectStage       string
+		expectUnavailable bool
+	}{
+		{
+			name:    "decoded messages are processed",
+			decoder: prefixDecoder{prefix: prefix},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.MatchedBy(func(req *v1beta2.ReportResourceRequest) bool {
+					return req.Type == "host"
+				})).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:        "decoding errors are unprocessable",
+			decoder:     prefixDecoder{err: errors.New("invalid Avro data")},
+			setupMock:   func(client *mocks.MockClient) {},
+			expectStage: "DecodeMessage",
+		},
+		{
+			name:              "unavailable registry errors are retried",
+			decoder:           prefixDecoder{err: fmt.Errorf("%w: connection refused", schemaregistry.ErrRegistryUnavailable)},
+			setupMock:         func(client *mocks.MockClient) {},
+			expectUnavailable: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+
+			client := &mocks.MockClient{}
+			test.setupMock(client)
+			tester.inv.Client = client
+			tester.inv.Decoder = test.decoder
+
+			topic := "test-topic"
+			value := append(append([]byte{}, prefix...), testCreateOrUpdateMessage...)
+			msg := &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic},
+				Headers: []kafka.Header{
+					{Key: "operation", Value: []byte(OperationTypeReportResource)},
+					{Key: "version", Value: []byte(defaultApiVersion)},
+				},
+				Key:   append(append([]byte{}, prefix...), testMessageKey...),
+				Value: value,
+			}
+			err := tester.inv.processPartitionMessage(context.Background(), msg)
+
+			switch {
+			case test.expectStage != "":
+				var unprocessable *UnprocessableError
+				assert.ErrorAs(t, err, &unprocessable)
+				assert.Equal(t, test.expectStage, unprocessable.Stage)
+			case test.expectUnavailable:
+				assert.ErrorIs(t, err, schemaregistry.ErrRegistryUnavailable)
+				var unprocessable *UnprocessableError
+				assert.False(t, errors.As(err, &unprocessable))
+			default:
+				assert.Nil(t, err)
+			}
+			// the consumed message is left as-is so it is dead-lettered in its original format
+			assert.Equal(t, value, msg.Value)
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestInventoryConsumer_DecodeStructuredCloudEvent(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.MatchedBy(func(req *v1beta2.ReportResourceRequest) bool {
+		return req.Type == "host" && req.ReporterType == "hbi"
+	})).Return(&v1beta2.ReportResourceResponse{}, nil)
+	tester.inv.Client = client
+	tester.inv.MessageFormat = MessageFormatCloudEvents
+	prefix := []byte{0, 0, 0, 0, 1}
+	tester.inv.Decoder = prefixDecoder{prefix: prefix}
+
+	// the event is only recognized once its value is decoded
+	topic := "test-topic"
+	msg := structuredCloudEvent(`{"specversion":"1.0","id":"1","source":"/hbi","type":"com.redhat.kessel.ReportResource.v1beta2","data":` + testReportResourceData + `}`)
+	msg.TopicPartition = kafka.TopicPartition{Topic: &topic}
+	msg.Value = append(append([]byte{}, prefix...), msg.Value...)
+
+	err := tester.inv.processPartitionMessage(context.Background(), msg)
+	assert.Nil(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestInventoryConsumer_LaneKey(t *testing.T) {
+	prefix := []byte{0, 0, 0, 0, 1}
+	key := append(append([]byte{}, prefix...), testMessageKey...)
+	tests := []struct {
+		name        string
+		decoder     Decoder
+		expectKey   []byte
+		expectError bool
+	}{
+		{
+			name:      "keys are used as-is without a decoder",
+			expectKey: key,
+		},
+		{
+			name:      "keys are decoded",
+			decoder:   prefixDecoder{prefix: prefix},
+			expectKey: []byte(testMessageKey),
+		},
+		{
+			name:      "keys that can not be decoded are used as-is",
+			decoder:   prefixDecoder{err: errors.New("invalid Avro data")},
+			expectKey: key,
+		},
+		{
+			name:        "unavailable registry errors are returned",
+			decoder:     prefixDecoder{err: fmt.Errorf("%w: connection refused", schemaregistry.ErrRegistryUnavailable)},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+			tester.inv.Decoder = test.decoder
+
+			laneKey, err := tester.inv.laneKey(&kafka.Message{Key: key})
+			if test.expectError {
+				assert.ErrorIs(t, err, schemaregistry.ErrRegistryUnavailable)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, test.expectKey, laneKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Handler processes a single message for the operation and API version it is registered for
+// Requests to Inventory API should be made with ctx so they are canceled when the consumer stops processing the message
+type Handler func(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error
+
+// HandlerKey identifies a Handler by the operation and version headers of a message
+type HandlerKey struct {
+	Operation string
+	Version   string
+}
+
+// HandlerRegistry maps operation and version headers to the Handler that processes the message
+// The registered operations and versions also determine which header values ParseHeaders accepts
+type HandlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[HandlerKey]Handler
+}
+
+// DefaultHandlers is the registry used by ParseHeaders and by consumers created with New
+// It is populated with the built-in operations and can be extended with Register
+var DefaultHandlers = NewDefaultHandlerRegistry()
+
+// NewHandlerRegistry returns an empty HandlerRegistry
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{handlers: make(map[HandlerKey]Handler)}
+}
+
+// NewDefaultHandlerRegistry returns a HandlerRegistry with the built-in operations registered
+func NewDefaultHandlerRegistry() *HandlerRegistry {
+	r := NewHandlerRegistry()
+	r.MustRegister(OperationTypeReportResource, APIVersionV1Beta2, func(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error {
+		return i.handleReportResource(ctx, headers, msg)
+	})
+	r.MustRegister(OperationTypeDeleteResource, APIVersionV1Beta2, func(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error {
+		return i.handleDeleteResource(ctx, headers, msg)
+	})
+	r.MustRegister(OperationTypeMigration, APIVersionV1Beta2, func(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error {
+		return i.handleMigration(ctx, headers, msg)
+	})
+	return r
+}
+
+// Register adds a Handler to the DefaultHandlers registry
+func Register(operation, version string, handler Handler) error {
+	return DefaultHandlers.Register(operation, version, handler)
+}
+
+// Register adds a Handler for the given operation and version
+// It returns an error if either value is empty or a Handler is already registered for the pair
+func (r *HandlerRegistry) Register(operation, version string, handler Handler) error {
+	if operation == "" || version == "" {
+		return fmt.Errorf("operation and version are required to register a handler")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler for operation=%s version=%s is nil", operation, version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := HandlerKey{Operation: operation, Version: version}
+	if _, ok := r.handlers[key]; ok {
+		return fmt.Errorf("handler already registered for operation=%s version=%s", operation, version)
+	}
+	r.handlers[key] = handler
+	return nil
+}
+
+// MustRegister is like Register but panics if the Handler cannot be registered
+func (r *HandlerRegistry) MustRegister(operation, version string, handler Handler) {
+	if err := r.Register(operation, version, handler); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the Handler registered for the given operation and version
+func (r *HandlerRegistry) Lookup(operation, version string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[HandlerKey{Operation: operation, Version: version}]
+	return handler, ok
+}
+
+// Keys returns the operation and version of every registered Handler, sorted by operation then version
+func (r *HandlerRegistry) Keys() []HandlerKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]HandlerKey, 0, len(r.handlers))
+	for key := range r.handlers {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].Operation != keys[b].Operation {
+			return keys[a].Operation < keys[b].Operation
+		}
+		return keys[a].Version < keys[b].Version
+	})
+	return keys
+}
+
+// ParseHeaders parses the header values in a kafka event and returns them as an EventHeaders object
+// It also verifies that all required headers are set and that a Handler is registered for their values
+func (r *HandlerRegistry) ParseHeaders(msg *kafka.Message) (EventHeaders, error) {
+	return r.ParseHeadersWithNames(msg, DefaultHeaderNames)
+}
+
+// ParseHeadersWithNames is like ParseHeaders but reads the operation and version from the first of the given header
+// keys set on the message, so producers using other header names such as event_type and api_version can be consumed
+func (r *HandlerRegistry) ParseHeadersWithNames(msg *kafka.Message, names HeaderNames) (EventHeaders, error) {
+	return r.ParseHeadersWithDefaults(msg, names, EventHeaders{})
+}
+
+// ParseHeadersWithDefaults is like ParseHeadersWithNames but uses the default operation and version for messages
+// without those headers, such as change events captured by Debezium
+func (r *HandlerRegistry) ParseHeadersWithDefaults(msg *kafka.Message, names HeaderNames, defaults EventHeaders) (EventHeaders, error) {
+	if len(names.Operation) == 0 || len(names.Version) == 0 {
+		return EventHeaders{}, fmt.Errorf("operation and version header names are required")
+	}
+
+	var headers EventHeaders
+	var operationKey, versionKey string
+	headers.Operation, operationKey = lookupHeader(msg.Headers, names.Operation)
+	headers.Version, versionKey = lookupHeader(msg.Headers, names.Version)
+	if headers.Operation == "" {
+		headers.Operation = defaults.Operation
+	}
+	if headers.Version == "" {
+		headers.Version = defaults.Version
+	}
+
+	if err := r.validate(headers, operationKey, versionKey); err != nil {
+		return EventHeaders{}, err
+	}
+	return headers, nil
+}
+
+// validate checks that the operation and version are set and have a registered handler
+// The operation and version keys name where the values were read from, so errors point at the offending header
+func (r *HandlerRegistry) validate(headers EventHeaders, operationKey, versionKey string) error {
+	var errs []error
+
+	// validate all header values are set and have valid values -- return all errors if multiple are found
+	validOperation, validVersion := false, false
+	for _, key := range r.Keys() {
+		validOperation = validOperation || key.Operation == headers.Operation
+		validVersion = validVersion || key.Version == headers.Version
+	}
+	if !validOperation {
+		errs = append(errs, fmt.Errorf("required header '%s' is missing or invalid: %s='%s'", operationKey, operationKey, headers.Operation))
+	}
+	if !validVersion {
+		errs = append(errs, fmt.Errorf("required header '%s' is missing or invalid: %s='%s'", versionKey, versionKey, headers.Version))
+	}
+	if errs == nil {
+		if _, ok := r.Lookup(headers.Operation, headers.Version); !ok {
+			errs = append(errs, fmt.Errorf("operation '%s' from header '%s' is not supported for version '%s'", headers.Operation, operationKey, headers.Version))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func noopHandler(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error {
+	return nil
+}
+
+func TestHandlerRegistry_Register(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		version   string
+		handler   Handler
+		expectErr bool
+	}{
+		{
+			name:      "new operation and version is registered",
+			operation: "CheckResource",
+			version:   "v1beta3",
+			handler:   noopHandler,
+			expectErr: false,
+		},
+		{
+			name:      "built-in operation for a new version is registered",
+			operation: OperationTypeReportResource,
+			version:   "v1beta3",
+			handler:   noopHandler,
+			expectErr: false,
+		},
+		{
+			name:      "duplicate operation and version is rejected",
+			operation: OperationTypeReportResource,
+			version:   APIVersionV1Beta2,
+			handler:   noopHandler,
+			expectErr: true,
+		},
+		{
+			name:      "empty operation is rejected",
+			operation: "",
+			version:   APIVersionV1Beta2,
+			handler:   noopHandler,
+			expectErr: true,
+		},
+		{
+			name:      "nil handler is rejected",
+			operation: "CheckResource",
+			version:   APIVersionV1Beta2,
+			handler:   nil,
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := NewDefaultHandlerRegistry()
+			err := registry.Register(test.operation, test.version, test.handler)
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			_, ok := registry.Lookup(test.operation, test.version)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestHandlerRegistry_ParseHeaders(t *testing.T) {
+	registry := NewDefaultHandlerRegistry()
+	assert.Nil(t, registry.Register("CheckResource", "v1beta3", noopHandler))
+
+	tests := []struct {
+		name      string
+		operation string
+		version   string
+		expectErr bool
+	}{
+		{
+			name:      "registered operation and version",
+			operation: "CheckResource",
+			version:   "v1beta3",
+			expectErr: false,
+		},
+		{
+			name:      "built-in operation and version",
+			operation: OperationTypeDeleteResource,
+			version:   APIVersionV1Beta2,
+			expectErr: false,
+		},
+		{
+			name:      "known operation and version without a handler for the pair",
+			operation: OperationTypeDeleteResource,
+			version:   "v1beta3",
+			expectErr: true,
+		},
+		{
+			name:      "unknown operation",
+			operation: "Unknown",
+			version:   APIVersionV1Beta2,
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers, err := registry.ParseHeaders(&kafka.Message{
+				Headers: []kafka.Header{
+					{Key: "operation", Value: []byte(test.operation)},
+					{Key: "version", Value: []byte(test.version)},
+				},
+			})
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, EventHeaders{Operation: test.operation, Version: test.version}, headers)
+		})
+	}
+}
+
+func TestInventoryConsumer_ProcessMessageUsesRegisteredHandler(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	handlerErr := errors.New("handler failed")
+	var handled *kafka.Message
+	registry := NewHandlerRegistry()
+	assert.Nil(t, registry.Register("CheckResource", "v1beta3", func(ctx context.Context, i *InventoryConsumer, headers EventHeaders, msg *kafka.Message) error {
+		handled = msg
+		return handlerErr
+	}))
+	tester.inv.Handlers = registry
+
+	msg := &kafka.Message{Value: []byte(`{}`)}
+	err := tester.inv.ProcessMessage(context.Background(), EventHeaders{Operation: "CheckResource", Version: "v1beta3"}, msg)
+	assert.ErrorIs(t, err, handlerErr)
+	assert.Same(t, msg, handled)
+
+	// operations without a registered handler are dropped
+	err = tester.inv.ProcessMessage(context.Background(), EventHeaders{Operation: OperationTypeReportResource, Version: APIVersionV1Beta2}, msg)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/spf13/pflag"
+)
+
+// HeaderNames are the header keys the operation and version of a message are read from, in order of precedence
+type HeaderNames struct {
+	Operation []string `mapstructure:"operation"`
+	Version   []string `mapstructure:"version"`
+}
+
+// DefaultHeaderNames reads the operation and version from the operation and version headers
+var DefaultHeaderNames = HeaderNames{
+	Operation: []string{"operation"},
+	Version:   []string{"version"},
+}
+
+// HeaderOptions sets the header keys the operation and version are read from, for all topics and per topic
+// Topic settings replace the default header keys for messages from that topic, e.g. for producers that set
+// event_type and api_version headers
+type HeaderOptions struct {
+	Operation []string               `mapstructure:"operation"`
+	Version   []string               `mapstructure:"version"`
+	Topics    map[string]HeaderNames `mapstructure:"topics"`
+}
+
+func NewHeaderOptions() *HeaderOptions {
+	return &HeaderOptions{
+		Operation: DefaultHeaderNames.Operation,
+		Version:   DefaultHeaderNames.Version,
+	}
+}
+
+func (o *HeaderOptions) AddFlags(fs *pflag.FlagSet, prefix string) {
+	if prefix != "" {
+		prefix = prefix + "."
+	}
+	fs.StringSliceVar(&o.Operation, prefix+"operation", o.Operation, "header keys the operation is read from, in order of precedence (default: operation)")
+	fs.StringSliceVar(&o.Version, prefix+"version", o.Version, "header keys the version is read from, in order of precedence (default: version)")
+}
+
+func (o *HeaderOptions) Validate() []error {
+	var errs []error
+
+	if len(o.Operation) == 0 || len(o.Version) == 0 {
+		errs = append(errs, fmt.Errorf("operation and version header names can not be empty"))
+	}
+	for topic, names := range o.Topics {
+		if len(names.Operation) == 0 && len(names.Version) == 0 {
+			errs = append(errs, fmt.Errorf("header names for topic %s must set operation or version", topic))
+		}
+	}
+	return errs
+}
+
+// NamesFor returns the header names used for messages from a topic
+func (o *HeaderOptions) NamesFor(topic string) HeaderNames {
+	names := HeaderNames{Operation: o.Operation, Version: o.Version}
+	if override, ok := o.Topics[topic]; ok {
+		if len(override.Operation) > 0 {
+			names.Operation = override.Operation
+		}
+		if len(override.Version) > 0 {
+			names.Version = override.Version
+		}
+	}
+	if len(names.Operation) == 0 {
+		names.Operation = DefaultHeaderNames.Operation
+	}
+	if len(names.Version) == 0 {
+		names.Version = DefaultHeaderNames.Version
+	}
+	return names
+}
+
+// lookupHeader returns the value of the first of the keys set on the message and the key it was read from
+// When none are set, the first key is returned so errors name the header that was expected
+func lookupHeader(headers []kafka.Header, keys []string) (string, string) {
+	for _, key := range keys {
+		if value, ok := lastHeader(headers, key); ok {
+			return value, key
+		}
+	}
+	return "", keys[0]
+}
+
+// lastHeader returns the value of the last header with the key, matching how repeated headers were decoded before
+func lastHeader(headers []kafka.Header, key string) (string, bool) {
+	value, found := "", false
+	for _, header := range headers {
+		if header.Key == key {
+			value, found = string(header.Value), true
+		}
+	}
+	return value, found
+}
@@ -0,0 +1,199 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHeaderOptions_AddFlags(t *testing.T) {
+	options := NewHeaderOptions()
+	prefix := "consumer.headers"
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	options.AddFlags(fs, prefix)
+
+	// topics can only be set in the config file
+	common.AllOptionsHaveFlags(t, prefix, fs, *options, []string{"topics"})
+}
+
+func TestHeaderOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *HeaderOptions
+		expectError bool
+	}{
+		{
+			name:        "default options are valid",
+			options:     NewHeaderOptions(),
+			expectError: false,
+		},
+		{
+			name: "topic header names are valid",
+			options: &HeaderOptions{
+				Operation: []string{"operation"},
+				Version:   []string{"version"},
+				Topics: map[string]HeaderNames{
+					"platform.events": {Operation: []string{"event_type"}, Version: []string{"api_version"}},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name:        "operation header names can not be empty",
+			options:     &HeaderOptions{Version: []string{"version"}},
+			expectError: true,
+		},
+		{
+			name: "topic header names can not both be empty",
+			options: &HeaderOptions{
+				Operation: []string{"operation"},
+				Version:   []string{"version"},
+				Topics:    map[string]HeaderNames{"platform.events": {}},
+			},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate()
+			if test.expectError {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
+
+func TestHeaderOptions_NamesFor(t *testing.T) {
+	options := &HeaderOptions{
+		Operation: []string{"operation", "event_type"},
+		Version:   []string{"version"},
+		Topics: map[string]HeaderNames{
+			"platform.events": {Operation: []string{"event_type"}, Version: []string{"api_version"}},
+			"platform.legacy": {Version: []string{"schema_version"}},
+		},
+	}
+
+	assert.Equal(t, HeaderNames{Operation: []string{"operation", "event_type"}, Version: []string{"version"}}, options.NamesFor("test-topic"))
+	assert.Equal(t, HeaderNames{Operation: []string{"event_type"}, Version: []string{"api_version"}}, options.NamesFor("platform.events"))
+	assert.Equal(t, HeaderNames{Operation: []string{"operation", "event_type"}, Version: []string{"schema_version"}}, options.NamesFor("platform.legacy"))
+	assert.Equal(t, DefaultHeaderNames, (&HeaderOptions{}).NamesFor("test-topic"))
+}
+
+func TestHandlerRegistry_ParseHeadersWithNames(t *testing.T) {
+	names := HeaderNames{Operation: []string{"event_type", "operation"}, Version: []string{"api_version"}}
+	tests := []struct {
+		name          string
+		headers       []kafka.Header
+		expected      EventHeaders
+		expectedError string
+	}{
+		{
+			name: "headers are read from the configured names",
+			headers: []kafka.Header{
+				{Key: "event_type", Value: []byte(OperationTypeReportResource)},
+				{Key: "api_version", Value: []byte(APIVersionV1Beta2)},
+			},
+			expected: EventHeaders{Operation: OperationTypeReportResource, Version: APIVersionV1Beta2},
+		},
+		{
+			name: "the first configured name set on the message is used",
+			headers: []kafka.Header{
+				{Key: "operation", Value: []byte(OperationTypeReportResource)},
+				{Key: "event_type", Value: []byte(OperationTypeDeleteResource)},
+				{Key: "api_version", Value: []byte(APIVersionV1Beta2)},
+			},
+			expected: EventHeaders{Operation: OperationTypeDeleteResource, Version: APIVersionV1Beta2},
+		},
+		{
+			name: "aliases are used when earlier names are not set",
+			headers: []kafka.Header{
+				{Key: "operation", Value: []byte(OperationTypeReportResource)},
+				{Key: "api_version", Value: []byte(APIVersionV1Beta2)},
+			},
+			expected: EventHeaders{Operation: OperationTypeReportResource, Version: APIVersionV1Beta2},
+		},
+		{
+			name: "unknown operations are reported with the header they came from",
+			headers: []kafka.Header{
+				{Key: "operation", Value: []byte("NotAnOperation")},
+				{Key: "api_version", Value: []byte(APIVersionV1Beta2)},
+			},
+			expectedError: "required header 'operation' is missing or invalid: operation='NotAnOperation'",
+		},
+		{
+			name: "missing headers are reported with the first configured name",
+			headers: []kafka.Header{
+				{Key: "event_type", Value: []byte(OperationTypeReportResource)},
+				{Key: "version", Value: []byte(APIVersionV1Beta2)},
+			},
+			expectedError: "required header 'api_version' is missing or invalid: api_version=''",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers, err := DefaultHandlers.ParseHeadersWithNames(&kafka.Message{Headers: test.headers}, names)
+			if test.expectedError != "" {
+				assert.ErrorContains(t, err, test.expectedError)
+				assert.Equal(t, EventHeaders{}, headers)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, test.expected, headers)
+			}
+		})
+	}
+}
+
+func TestInventoryConsumer_ProcessTopicHeaderNames(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.MatchedBy(func(req *v1beta2.ReportResourceRequest) bool {
+		return req.Type == "host" && req.ReporterType == "hbi"
+	})).Return(&v1beta2.ReportResourceResponse{}, nil)
+	tester.inv.Client = client
+	tester.inv.HeaderOptions = &HeaderOptions{
+		Operation: DefaultHeaderNames.Operation,
+		Version:   DefaultHeaderNames.Version,
+		Topics: map[string]HeaderNames{
+			"platform.events": {Operation: []string{"event_type"}, Version: []string{"api_version"}},
+		},
+	}
+
+	platformTopic := "platform.events"
+	err := tester.inv.processPartitionMessage(context.Background(), &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &platformTopic},
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(OperationTypeReportResource)},
+			{Key: "api_version", Value: []byte(APIVersionV1Beta2)},
+		},
+		Value: []byte(testCreateOrUpdateMessage),
+	})
+	assert.Nil(t, err)
+
+	// other topics still read the default operation and version headers
+	topic := "test-topic"
+	err = tester.inv.processPartitionMessage(context.Background(), &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(OperationTypeReportResource)},
+			{Key: "api_version", Value: []byte(APIVersionV1Beta2)},
+		},
+		Value: []byte(testCreateOrUpdateMessage),
+	})
+	var unprocessable *UnprocessableError
+	assert.ErrorAs(t, err, &unprocessable)
+	assert.Equal(t, "ParseHeaders", unprocessable.Stage)
+	client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 1)
+}
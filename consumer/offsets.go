@@ -0,0 +1,92 @@
+package consumer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// partitionKey uniquely identifies a topic partition for use as a map key
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+func newPartitionKey(tp kafka.TopicPartition) partitionKey {
+	var topic string
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return partitionKey{topic: topic, partition: tp.Partition}
+}
+
+// OffsetStorage tracks the latest processed offset for each partition that has not yet been committed
+// It is safe for concurrent use by partition workers and the consumer loop
+type OffsetStorage struct {
+	mu      sync.Mutex
+	offsets map[partitionKey]kafka.TopicPartition
+}
+
+// NewOffsetStorage returns an empty OffsetStorage
+func NewOffsetStorage() *OffsetStorage {
+	return &OffsetStorage{offsets: make(map[partitionKey]kafka.TopicPartition)}
+}
+
+// Store records the offset of a processed message, replacing any earlier offset stored for the same partition
+func (s *OffsetStorage) Store(tp kafka.TopicPartition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := newPartitionKey(tp)
+	if current, ok := s.offsets[key]; ok && current.Offset >= tp.Offset {
+		return
+	}
+	s.offsets[key] = tp
+}
+
+// Pending returns the stored offsets awaiting commit ordered by topic and partition
+func (s *OffsetStorage) Pending() []kafka.TopicPartition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make([]kafka.TopicPartition, 0, len(s.offsets))
+	for _, tp := range s.offsets {
+		pending = append(pending, tp)
+	}
+	sort.Slice(pending, func(a, b int) bool {
+		keyA, keyB := newPartitionKey(pending[a]), newPartitionKey(pending[b])
+		if keyA.topic != keyB.topic {
+			return keyA.topic < keyB.topic
+		}
+		return keyA.partition < keyB.partition
+	})
+	return pending
+}
+
+// Len returns the number of partitions with offsets awaiting commit
+func (s *OffsetStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.offsets)
+}
+
+// Clear removes the committed offsets from storage
+// Partitions that have advanced past the committed offset since it was read are left in place
+func (s *OffsetStorage) Clear(committed []kafka.TopicPartition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range committed {
+		key := newPartitionKey(tp)
+		if current, ok := s.offsets[key]; ok && current.Offset <= tp.Offset {
+			delete(s.offsets, key)
+		}
+	}
+}
+
+// Remove drops any stored offsets for the given partitions regardless of their offset
+func (s *OffsetStorage) Remove(partitions []kafka.TopicPartition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range partitions {
+		delete(s.offsets, newPartitionKey(tp))
+	}
+}
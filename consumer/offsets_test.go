@@ -0,0 +1,33 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetStorage(t *testing.T) {
+	storage := NewOffsetStorage()
+	storage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 1, Offset: kafka.Offset(4)})
+	storage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(7)})
+	// an older offset never replaces a newer one
+	storage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(3)})
+
+	pending := storage.Pending()
+	assert.Equal(t, []kafka.TopicPartition{
+		{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(7)},
+		{Topic: ToPointer("test-topic"), Partition: 1, Offset: kafka.Offset(4)},
+	}, pending)
+
+	// partition 0 advances after pending offsets were read and must survive the clear
+	storage.Store(kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(8)})
+	storage.Clear(pending)
+	assert.Equal(t, []kafka.TopicPartition{
+		{Topic: ToPointer("test-topic"), Partition: 0, Offset: kafka.Offset(8)},
+	}, storage.Pending())
+
+	storage.Remove([]kafka.TopicPartition{{Topic: ToPointer("test-topic"), Partition: 0}})
+	assert.Equal(t, 0, storage.Len())
+}
@@ -2,41 +2,69 @@ package consumer
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/project-kessel/inventory-consumer/consumer/auth"
+	"github.com/project-kessel/inventory-consumer/consumer/batch"
+	"github.com/project-kessel/inventory-consumer/consumer/breaker"
 	"github.com/project-kessel/inventory-consumer/consumer/retry"
+	"github.com/project-kessel/inventory-consumer/consumer/schemaregistry"
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
 	"github.com/spf13/pflag"
 )
 
 type Options struct {
-	Enabled            bool           `mapstructure:"enabled"`
-	BootstrapServers   []string       `mapstructure:"bootstrap-servers"`
-	ConsumerGroupID    string         `mapstructure:"consumer-group-id"`
-	Topics             []string       `mapstructure:"topics"`
-	SessionTimeout     string         `mapstructure:"session-timeout"`
-	HeartbeatInterval  string         `mapstructure:"heartbeat-interval"`
-	MaxPollInterval    string         `mapstructure:"max-poll-interval"`
-	EnableAutoCommit   string         `mapstructure:"enable-auto-commit"`
-	AutoOffsetReset    string         `mapstructure:"auto-offset-reset"`
-	StatisticsInterval string         `mapstructure:"statistics-interval-ms"`
-	Debug              string         `mapstructure:"debug"`
-	RetryOptions       *retry.Options `mapstructure:"retry-options"`
-	AuthOptions        *auth.Options  `mapstructure:"auth"`
+	Enabled               bool                     `mapstructure:"enabled"`
+	BootstrapServers      []string                 `mapstructure:"bootstrap-servers"`
+	ConsumerGroupID       string                   `mapstructure:"consumer-group-id"`
+	Topics                []string                 `mapstructure:"topics"`
+	SessionTimeout        string                   `mapstructure:"session-timeout"`
+	HeartbeatInterval     string                   `mapstructure:"heartbeat-interval"`
+	MaxPollInterval       string                   `mapstructure:"max-poll-interval"`
+	EnableAutoCommit      string                   `mapstructure:"enable-auto-commit"`
+	AutoOffsetReset       string                   `mapstructure:"auto-offset-reset"`
+	StatisticsInterval    string                   `mapstructure:"statistics-interval-ms"`
+	Debug                 string                   `mapstructure:"debug"`
+	WorkersPerPartition   int                      `mapstructure:"workers-per-partition"`
+	DeadLetterTopic       string                   `mapstructure:"dead-letter-topic"`
+	DeadLetterTimeoutMs   int                      `mapstructure:"dead-letter-timeout-ms"`
+	MessageFormat         string                   `mapstructure:"message-format"`
+	CommitCount           int                      `mapstructure:"commit-count"`
+	CommitIntervalMs      int                      `mapstructure:"commit-interval-ms"`
+	Mappings              []transforms.MappingSpec `mapstructure:"mappings"`
+	TopicOptions          map[string]*TopicOptions `mapstructure:"topic-options"`
+	HostOptions           *transforms.HostOptions  `mapstructure:"host"`
+	SchemaRegistryOptions *schemaregistry.Options  `mapstructure:"schema-registry"`
+	CircuitBreakerOptions *breaker.Options         `mapstructure:"circuit-breaker"`
+	BatchOptions          *batch.Options           `mapstructure:"batch"`
+	HeaderOptions         *HeaderOptions           `mapstructure:"headers"`
+	RetryOptions          *retry.Options           `mapstructure:"retry-options"`
+	AuthOptions           *auth.Options            `mapstructure:"auth"`
 }
 
 func NewOptions() *Options {
 	return &Options{
-		Enabled:            true,
-		ConsumerGroupID:    "kic",
-		SessionTimeout:     "45000",
-		HeartbeatInterval:  "3000",
-		MaxPollInterval:    "300000",
-		EnableAutoCommit:   "false",
-		AutoOffsetReset:    "earliest",
-		StatisticsInterval: "60000",
-		Debug:              "",
-		AuthOptions:        auth.NewOptions(),
-		RetryOptions:       retry.NewOptions(),
+		Enabled:               true,
+		ConsumerGroupID:       "kic",
+		SessionTimeout:        "45000",
+		HeartbeatInterval:     "3000",
+		MaxPollInterval:       "300000",
+		EnableAutoCommit:      "false",
+		AutoOffsetReset:       "earliest",
+		StatisticsInterval:    "60000",
+		Debug:                 "",
+		WorkersPerPartition:   1,
+		DeadLetterTimeoutMs:   30000,
+		MessageFormat:         MessageFormatHeaders,
+		CommitCount:           10,
+		CommitIntervalMs:      5000,
+		HostOptions:           transforms.NewHostOptions(),
+		SchemaRegistryOptions: schemaregistry.NewOptions(),
+		CircuitBreakerOptions: breaker.NewOptions(),
+		BatchOptions:          batch.NewOptions(),
+		HeaderOptions:         NewHeaderOptions(),
+		AuthOptions:           auth.NewOptions(),
+		RetryOptions:          retry.NewOptions(),
 	}
 }
 
@@ -55,7 +83,18 @@ func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
 	fs.StringVar(&o.AutoOffsetReset, prefix+"auto-offset-reset", o.AutoOffsetReset, "action to take when there is no initial offset in offset store (default: earliest)")
 	fs.StringVar(&o.StatisticsInterval, prefix+"statistics-interval-ms", o.StatisticsInterval, "librdkafka statistics emit interval (default: 30000ms)")
 	fs.StringVar(&o.Debug, prefix+"debug", o.Debug, "a comma-separated list of debug contexts to enable (default: \"\"")
+	fs.StringVar(&o.DeadLetterTopic, prefix+"dead-letter-topic", o.DeadLetterTopic, "topic that unprocessable messages are produced to before the consumer commits past them (default: \"\", disabled)")
+	fs.IntVar(&o.DeadLetterTimeoutMs, prefix+"dead-letter-timeout-ms", o.DeadLetterTimeoutMs, "time to wait for a message to be delivered to the dead-letter topic before the message is retried (default: 30000ms)")
+	fs.StringVar(&o.MessageFormat, prefix+"message-format", o.MessageFormat, "how the operation and version of messages are read: headers uses the operation and version headers, cloudevents also accepts CloudEvents in binary or structured mode, migration processes every message as a migration message (default: headers)")
+	fs.IntVar(&o.CommitCount, prefix+"commit-count", o.CommitCount, "number of processed offsets stored before offsets are committed, 0 disables (default: 10)")
+	fs.IntVar(&o.CommitIntervalMs, prefix+"commit-interval-ms", o.CommitIntervalMs, "maximum time between commits of processed offsets, 0 disables (default: 5000ms)")
+	fs.IntVar(&o.WorkersPerPartition, prefix+"workers-per-partition", o.WorkersPerPartition, "number of workers per partition; messages are assigned to a worker by key so updates to the same resource stay ordered (default: 1)")
 
+	o.HostOptions.AddFlags(fs, prefix+"host")
+	o.SchemaRegistryOptions.AddFlags(fs, prefix+"schema-registry")
+	o.CircuitBreakerOptions.AddFlags(fs, prefix+"circuit-breaker")
+	o.BatchOptions.AddFlags(fs, prefix+"batch")
+	o.HeaderOptions.AddFlags(fs, prefix+"headers")
 	o.AuthOptions.AddFlags(fs, prefix+"auth")
 	o.RetryOptions.AddFlags(fs, prefix+"retry-options")
 }
@@ -67,12 +106,90 @@ func (o *Options) Validate() []error {
 		errs = append(errs, fmt.Errorf("bootstrap servers can not be empty"))
 	}
 
-	if len(o.Topics) == 0 && o.Enabled {
+	if len(o.Topics) == 0 && len(o.TopicOptions) == 0 && o.Enabled {
 		errs = append(errs, fmt.Errorf("topic value can not be empty"))
 	}
+
+	if o.WorkersPerPartition < 1 && o.Enabled {
+		errs = append(errs, fmt.Errorf("workers per partition must be at least 1"))
+	}
+
+	if o.DeadLetterTimeoutMs < 1 && o.hasDeadLetterTopic() {
+		errs = append(errs, fmt.Errorf("dead-letter timeout must be at least 1ms"))
+	}
+
+	if !validMessageFormat(o.MessageFormat) {
+		errs = append(errs, fmt.Errorf("message format must be %s, %s or %s: message-format='%s'", MessageFormatHeaders, MessageFormatCloudEvents, MessageFormatMigration, o.MessageFormat))
+	}
+
+	if o.CommitCount < 0 || o.CommitIntervalMs < 0 {
+		errs = append(errs, fmt.Errorf("commit count and commit interval can not be negative"))
+	} else if o.CommitCount == 0 && o.CommitIntervalMs == 0 && o.Enabled {
+		errs = append(errs, fmt.Errorf("at least one of commit count or commit interval must be set"))
+	}
+
+	if o.HostOptions != nil {
+		errs = append(errs, o.HostOptions.Validate()...)
+	}
+
+	if o.SchemaRegistryOptions != nil {
+		errs = append(errs, o.SchemaRegistryOptions.Validate()...)
+	}
+
+	if o.RetryOptions != nil {
+		errs = append(errs, o.RetryOptions.Validate()...)
+	}
+
+	if o.CircuitBreakerOptions != nil {
+		errs = append(errs, o.CircuitBreakerOptions.Validate()...)
+	}
+
+	if o.BatchOptions != nil {
+		errs = append(errs, o.BatchOptions.Validate()...)
+	}
+
+	if o.HeaderOptions != nil {
+		errs = append(errs, o.HeaderOptions.Validate()...)
+	}
+
+	// mappings and topic options are only set through the config file since they can not be expressed as flags
+	topics := make([]string, 0, len(o.TopicOptions))
+	for topic := range o.TopicOptions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		if o.TopicOptions[topic] != nil {
+			errs = append(errs, o.TopicOptions[topic].Validate(topic)...)
+		}
+	}
+	for _, mapping := range o.Mappings {
+		if err := mapping.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	return errs
 }
 
 func (o *Options) Complete() []error {
-	return nil
+	var errs []error
+
+	if o.AuthOptions != nil {
+		errs = append(errs, o.AuthOptions.Complete()...)
+	}
+
+	return errs
+}
+
+// hasDeadLetterTopic returns true if a dead-letter topic is configured for the consumer or any topic
+func (o *Options) hasDeadLetterTopic() bool {
+	if o.DeadLetterTopic != "" {
+		return true
+	}
+	for _, options := range o.TopicOptions {
+		if options != nil && options.DeadLetterTopic != "" {
+			return true
+		}
+	}
+	return false
 }
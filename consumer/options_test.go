@@ -1,12 +1,18 @@
 package consumer
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/project-kessel/inventory-consumer/consumer/auth"
+	"github.com/project-kessel/inventory-consumer/consumer/batch"
+	"github.com/project-kessel/inventory-consumer/consumer/breaker"
 	"github.com/project-kessel/inventory-consumer/consumer/retry"
+	"github.com/project-kessel/inventory-consumer/consumer/schemaregistry"
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
 	"github.com/project-kessel/inventory-consumer/internal/common"
 	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,17 +23,27 @@ func TestNewOptions(t *testing.T) {
 	}{
 		options: NewOptions(),
 		expectedOptions: &Options{
-			Enabled:            true,
-			ConsumerGroupID:    "kic",
-			SessionTimeout:     "45000",
-			HeartbeatInterval:  "3000",
-			MaxPollInterval:    "300000",
-			EnableAutoCommit:   "false",
-			AutoOffsetReset:    "earliest",
-			StatisticsInterval: "60000",
-			Debug:              "",
-			AuthOptions:        auth.NewOptions(),
-			RetryOptions:       retry.NewOptions(),
+			Enabled:               true,
+			ConsumerGroupID:       "kic",
+			SessionTimeout:        "45000",
+			HeartbeatInterval:     "3000",
+			MaxPollInterval:       "300000",
+			EnableAutoCommit:      "false",
+			AutoOffsetReset:       "earliest",
+			StatisticsInterval:    "60000",
+			Debug:                 "",
+			WorkersPerPartition:   1,
+			DeadLetterTimeoutMs:   30000,
+			MessageFormat:         MessageFormatHeaders,
+			CommitCount:           10,
+			CommitIntervalMs:      5000,
+			HostOptions:           transforms.NewHostOptions(),
+			SchemaRegistryOptions: schemaregistry.NewOptions(),
+			CircuitBreakerOptions: breaker.NewOptions(),
+			BatchOptions:          batch.NewOptions(),
+			HeaderOptions:         NewHeaderOptions(),
+			AuthOptions:           auth.NewOptions(),
+			RetryOptions:          retry.NewOptions(),
 		},
 	}
 	assert.Equal(t, test.expectedOptions, NewOptions())
@@ -44,9 +60,9 @@ func TestOptions_AddFlags(t *testing.T) {
 	test.options.AddFlags(fs, prefix)
 
 	// the below logic ensures that every possible option defined in the Options type
-	// has a defined flag for that option; auth and retry-options are skipped in favor of testing
-	// in their own packages
-	common.AllOptionsHaveFlags(t, prefix, fs, *test.options, []string{"auth", "retry-options"})
+	// has a defined flag for that option; auth, retry-options, host, schema-registry, circuit-breaker, batch and headers are skipped in favor
+	// of testing them separately, and mappings and topic-options can only be set in the config file
+	common.AllOptionsHaveFlags(t, prefix, fs, *test.options, []string{"auth", "retry-options", "mappings", "topic-options", "host", "schema-registry", "circuit-breaker", "batch", "headers"})
 }
 
 func TestOptions_Validate(t *testing.T) {
@@ -94,6 +110,59 @@ func TestOptions_Validate(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "workers per partition is less than one",
+			options: &Options{
+				Enabled: true,
+				BootstrapServers: []string{
+					"test-server:9092",
+				},
+				Topics:              []string{"test-topic"},
+				WorkersPerPartition: 0,
+			},
+			expectError: true,
+		},
+		{
+			name: "commit count and commit interval are both disabled",
+			options: &Options{
+				Enabled: true,
+				BootstrapServers: []string{
+					"test-server:9092",
+				},
+				Topics:              []string{"test-topic"},
+				WorkersPerPartition: 1,
+				CommitCount:         0,
+				CommitIntervalMs:    0,
+			},
+			expectError: true,
+		},
+		{
+			name: "dead-letter timeout is not set",
+			options: &Options{
+				Enabled: true,
+				BootstrapServers: []string{
+					"test-server:9092",
+				},
+				Topics:              []string{"test-topic"},
+				WorkersPerPartition: 1,
+				CommitCount:         10,
+				MessageFormat:       MessageFormatHeaders,
+				DeadLetterTopic:     "test-topic.dlq",
+				DeadLetterTimeoutMs: 0,
+			},
+			expectError: true,
+		},
+		{
+			name: "message format is not supported",
+			options: &Options{
+				BootstrapServers: []string{
+					"test-server:9092",
+				},
+				Topics:        []string{"test-topic"},
+				MessageFormat: "avro",
+			},
+			expectError: true,
+		},
 		{
 			name: "bootstrap servers and/or topic can be empty if consumer disabled",
 			options: &Options{
@@ -116,3 +185,43 @@ func TestOptions_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestOptions_MappingsFromConfigFile(t *testing.T) {
+	config := `
+consumer:
+  mappings:
+  - resource-type: workspace
+    reporter-type: hbi
+    topic: outbox.event.hbi.groups
+    fields:
+    - target: type
+      default: workspace
+    - target: representations.metadata.local_resource_id
+      source: payload.id
+      required: true
+`
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.Nil(t, v.ReadConfig(strings.NewReader(config)))
+
+	options := NewOptions()
+	assert.Nil(t, v.UnmarshalKey("consumer", options))
+	assert.Equal(t, []transforms.MappingSpec{
+		{
+			ResourceType: "workspace",
+			ReporterType: "hbi",
+			Topic:        "outbox.event.hbi.groups",
+			Fields: []transforms.FieldMapping{
+				{Target: "type", Default: "workspace"},
+				{Target: "representations.metadata.local_resource_id", Source: "payload.id", Required: true},
+			},
+		},
+	}, options.Mappings)
+
+	options.BootstrapServers = []string{"test-server:9092"}
+	options.Topics = []string{"test-topic"}
+	assert.Nil(t, options.Validate())
+
+	options.Mappings[0].ResourceType = ""
+	assert.NotNil(t, options.Validate())
+}
@@ -4,66 +4,24 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"errors"
-
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
-	"github.com/mitchellh/mapstructure"
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
 )
 
-// defines all required headers for message processing
-
 // ParseHeaders parses the header values in a kafka event and returns them as an EventHeaders object
-// It also verifies that all required headers are set
+// It also verifies that all required headers are set and are supported by the DefaultHandlers registry
 func ParseHeaders(msg *kafka.Message) (EventHeaders, error) {
-	var errs []error
-	var headers EventHeaders
-
-	mapHeaders := make(map[string]interface{})
-	for _, v := range msg.Headers {
-		// ignores any extra headers
-		if _, ok := requiredHeaders[v.Key]; ok {
-			mapHeaders[v.Key] = string(v.Value)
-		}
-	}
-	config := &mapstructure.DecoderConfig{
-		ErrorUnused: true,
-		Result:      &headers,
-	}
-	decoder, err := mapstructure.NewDecoder(config)
-	if err != nil {
-		return EventHeaders{}, fmt.Errorf("error creating decoder: %w", err)
-	}
-	if err := decoder.Decode(mapHeaders); err != nil {
-		return EventHeaders{}, fmt.Errorf("error decoding headers: %w", err)
-	}
-	// validate all header values are set and have valid values -- return all errors if multiple are found
-	if _, ok := validOperations[headers.Operation]; !ok {
-		errs = append(errs, fmt.Errorf("required header 'operation' is missing or invalid: operation='%s'", headers.Operation))
-	}
-	if _, ok := validApiVersions[headers.Version]; !ok {
-		errs = append(errs, fmt.Errorf("required header 'version' is missing or invalid: version='%s'", headers.Version))
-	}
-	if errs != nil {
-		return EventHeaders{}, errors.Join(errs...)
-	}
-	return headers, nil
+	return DefaultHandlers.ParseHeaders(msg)
 }
 
 // ParseCreateOrUpdateMessage parses a kafka event and converts the data into the specified create/update request data type passed
 func ParseCreateOrUpdateMessage(msg []byte, output interface{}) error {
-	var msgPayload *MessagePayload
-
-	// msg value is expected to be a valid JSON body for the passed request type
-	err := json.Unmarshal(msg, &msgPayload)
+	// msg value is expected to be a valid JSON body for the passed request type, with or without the schema envelope
+	payloadJson, err := transforms.Payload(msg)
 	if err != nil {
 		return fmt.Errorf("error unmarshaling msgPayload: %w", err)
 	}
 
-	payloadJson, err := json.Marshal(msgPayload.RequestPayload)
-	if err != nil {
-		return fmt.Errorf("error marshaling request payload: %w", err)
-	}
-
 	err = json.Unmarshal(payloadJson, &output)
 	if err != nil {
 		return fmt.Errorf("error unmarshaling request payload: %w", err)
@@ -73,22 +31,97 @@ func ParseCreateOrUpdateMessage(msg []byte, output interface{}) error {
 
 // ParseDeleteMessage parses a kafka event and converts the data into the specified delete request data type passed
 func ParseDeleteMessage(msg []byte, output interface{}) error {
-	var msgPayload *MessagePayload
-
-	// msg value is expected to be a valid JSON body for a single relation
-	err := json.Unmarshal(msg, &msgPayload)
+	// msg value is expected to be a valid JSON body for a single relation, with or without the schema envelope
+	payloadJson, err := transforms.Payload(msg)
 	if err != nil {
 		return fmt.Errorf("error unmarshaling msgPayload: %w", err)
 	}
 
-	payloadJson, err := json.Marshal(msgPayload.RequestPayload)
-	if err != nil {
-		return fmt.Errorf("error marshaling tuple payload: %w", err)
-	}
-
 	err = json.Unmarshal(payloadJson, &output)
 	if err != nil {
 		return fmt.Errorf("error unmarshaling tuple payload: %w", err)
 	}
 	return nil
 }
+
+// Debezium change event operations
+const (
+	DebeziumOpCreate = "c"
+	DebeziumOpUpdate = "u"
+	DebeziumOpDelete = "d"
+	DebeziumOpRead   = "r"
+)
+
+// DebeziumEnvelope is a Debezium change event as produced by connectors that do not configure the
+// ExtractNewRecordState SMT; Before and After hold the row state before and after the change
+type DebeziumEnvelope struct {
+	Before json.RawMessage        `json:"before"`
+	After  json.RawMessage        `json:"after"`
+	Op     string                 `json:"op"`
+	Source map[string]interface{} `json:"source"`
+}
+
+// ParseDebeziumEnvelope parses a message value as a Debezium envelope, either on its own or wrapped in a schema and payload
+// It returns false when the message is not an envelope, such as tombstones and messages unwrapped by ExtractNewRecordState
+func ParseDebeziumEnvelope(msg []byte) (*DebeziumEnvelope, bool, error) {
+	// messages that are not JSON objects are left to the transformer to handle
+	payload, err := transforms.Payload(msg)
+	if err != nil {
+		return nil, false, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || !isDebeziumEnvelope(fields) {
+		return nil, false, nil
+	}
+
+	var envelope DebeziumEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, false, fmt.Errorf("error unmarshaling Debezium envelope: %w", err)
+	}
+	switch envelope.Op {
+	case DebeziumOpCreate, DebeziumOpUpdate, DebeziumOpRead:
+		if isNullJSON(envelope.After) {
+			return nil, false, fmt.Errorf("debezium envelope with op '%s' has no after state", envelope.Op)
+		}
+	case DebeziumOpDelete:
+	default:
+		return nil, false, fmt.Errorf("unsupported Debezium operation: op='%s'", envelope.Op)
+	}
+	return &envelope, true, nil
+}
+
+// IsDelete returns true if the envelope records the deletion of a row
+func (e *DebeziumEnvelope) IsDelete() bool {
+	return e.Op == DebeziumOpDelete
+}
+
+// Record returns the row state in the format produced by the ExtractNewRecordState SMT, so it can be passed to transformers
+// Deletes return the before state, and all other operations the after state
+func (e *DebeziumEnvelope) Record() ([]byte, error) {
+	state := e.After
+	if e.IsDelete() {
+		state = e.Before
+	}
+	if isNullJSON(state) {
+		state = json.RawMessage("null")
+	}
+	record, err := json.Marshal(map[string]json.RawMessage{"payload": state})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling Debezium record: %w", err)
+	}
+	return record, nil
+}
+
+// isDebeziumEnvelope returns true if the object has an op and a before or after state
+func isDebeziumEnvelope(fields map[string]json.RawMessage) bool {
+	if _, ok := fields["op"]; !ok {
+		return false
+	}
+	_, hasBefore := fields["before"]
+	_, hasAfter := fields["after"]
+	return hasBefore || hasAfter
+}
+
+func isNullJSON(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
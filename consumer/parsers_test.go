@@ -153,6 +153,15 @@ func TestParseCreateOrUpdateMessage(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(expected.Representations.Reporter.AsMap(), req.Representations.Reporter.AsMap()))
 }
 
+func TestParseCreateOrUpdateMessage_Schemaless(t *testing.T) {
+	var req v1beta2.ReportResourceRequest
+	err := ParseCreateOrUpdateMessage([]byte(`{"type":"host","reporter_type":"hbi","reporter_instance_id":"00000000-0000-0000-0000-000000000000"}`), &req)
+	assert.Nil(t, err)
+	assert.Equal(t, "host", req.Type)
+	assert.Equal(t, "hbi", req.ReporterType)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", req.ReporterInstanceId)
+}
+
 func TestParseDeleteMessage(t *testing.T) {
 	expected := makeDeleteResourceRequest()
 	var req v1beta2.DeleteResourceRequest
@@ -163,3 +172,94 @@ func TestParseDeleteMessage(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(expected.Reference.Reporter, req.Reference.Reporter))
 
 }
+
+func TestParseDebeziumEnvelope(t *testing.T) {
+	tests := []struct {
+		name         string
+		msg          string
+		expectOk     bool
+		expectDelete bool
+		expectRecord string
+		expectErr    bool
+	}{
+		{
+			name:         "envelope with schema",
+			msg:          `{"schema":{},"payload":{"before":null,"after":{"id":"1"},"op":"c","source":{"table":"hosts"}}}`,
+			expectOk:     true,
+			expectRecord: `{"payload":{"id":"1"}}`,
+		},
+		{
+			name:         "envelope without schema",
+			msg:          `{"before":{"id":"1"},"after":{"id":"1","name":"updated"},"op":"u","source":{"table":"hosts"}}`,
+			expectOk:     true,
+			expectRecord: `{"payload":{"id":"1","name":"updated"}}`,
+		},
+		{
+			name:         "snapshot reads are reported",
+			msg:          `{"payload":{"before":null,"after":{"id":"1"},"op":"r"}}`,
+			expectOk:     true,
+			expectRecord: `{"payload":{"id":"1"}}`,
+		},
+		{
+			name:         "deletes return the before state",
+			msg:          `{"payload":{"before":{"id":"1"},"after":null,"op":"d"}}`,
+			expectOk:     true,
+			expectDelete: true,
+			expectRecord: `{"payload":{"id":"1"}}`,
+		},
+		{
+			name:         "schemaless envelope",
+			msg:          `{"before":null,"after":{"id":"1"},"op":"c"}`,
+			expectOk:     true,
+			expectRecord: `{"payload":{"id":"1"}}`,
+		},
+		{
+			name:     "unwrapped records are not envelopes",
+			msg:      `{"schema":{},"payload":{"id":"1","groups":"[]"}}`,
+			expectOk: false,
+		},
+		{
+			name:     "tombstones are not envelopes",
+			msg:      ``,
+			expectOk: false,
+		},
+		{
+			name:      "unsupported operations are errors",
+			msg:       `{"payload":{"before":null,"after":null,"op":"t"}}`,
+			expectErr: true,
+		},
+		{
+			name:      "updates without an after state are errors",
+			msg:       `{"payload":{"before":{"id":"1"},"after":null,"op":"u"}}`,
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envelope, ok, err := ParseDebeziumEnvelope([]byte(test.msg))
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectOk, ok)
+			if !test.expectOk {
+				assert.Nil(t, envelope)
+				return
+			}
+			assert.Equal(t, test.expectDelete, envelope.IsDelete())
+			record, err := envelope.Record()
+			assert.Nil(t, err)
+			assert.JSONEq(t, test.expectRecord, string(record))
+		})
+	}
+}
+
+func TestParseDeleteMessage_Schemaless(t *testing.T) {
+	var req v1beta2.DeleteResourceRequest
+	err := ParseDeleteMessage([]byte(`{"reference":{"resource_type":"host","resource_id":"00000000-0000-0000-0000-000000000000","reporter":{"type":"hbi"}}}`), &req)
+	assert.Nil(t, err)
+	assert.Equal(t, "host", req.Reference.ResourceType)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", req.Reference.ResourceId)
+	assert.Equal(t, "hbi", req.Reference.Reporter.Type)
+}
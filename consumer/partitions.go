@@ -0,0 +1,418 @@
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
+)
+
+// partitionQueueSize is the number of messages queued for a partition before it is paused, so a slow partition never
+// blocks the poll loop. The partition is resumed once its queue drains to half this size.
+const partitionQueueSize = 100
+
+// PartitionWorkers runs a set of workers for each assigned partition so a slow request on one partition
+// does not stall the others. Within a partition, messages are routed to a worker by hashing their key so
+// updates to the same resource are processed in the order they are dispatched while unrelated resources
+// are processed concurrently. With a single worker per partition, the whole partition is processed in order.
+type PartitionWorkers struct {
+	mu                  sync.Mutex
+	workers             map[partitionKey]*partitionWorker
+	workersPerPartition int
+	process             func(context.Context, *kafka.Message) error
+	completed           func(kafka.TopicPartition)
+	wait                func(context.Context, error) bool
+	key                 func(*kafka.Message) ([]byte, error)
+	errs                chan error
+}
+
+// partitionWorker processes the messages of a single partition across one or more key-ordered lanes
+type partitionWorker struct {
+	topic     string
+	partition int32
+	lanes     []*lane
+	offsets   *offsetTracker
+	deferred  sync.Map
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	// queued is the number of messages dispatched to the lanes that have not been picked up by a worker yet, or that
+	// were deferred and are not done
+	queued atomic.Int64
+	// throttled is true while the partition is paused because its queue is full; p.mu must be held
+	throttled bool
+}
+
+// lane is an unbounded queue of messages processed in order by a single worker
+type lane struct {
+	mu     sync.Mutex
+	queue  []*kafka.Message
+	signal chan struct{}
+}
+
+// NewPartitionWorkers returns a PartitionWorkers that calls process for each dispatched message using
+// workersPerPartition workers per partition. Whenever the lowest contiguous processed offset of a partition
+// advances, completed is called with that offset so it can be stored for commit. The context passed to process is
+// canceled when the partition is stopped.
+func NewPartitionWorkers(workersPerPartition int, process func(context.Context, *kafka.Message) error, completed func(kafka.TopicPartition)) *PartitionWorkers {
+	return &PartitionWorkers{
+		workers:             make(map[partitionKey]*partitionWorker),
+		workersPerPartition: max(workersPerPartition, 1),
+		process:             process,
+		completed:           completed,
+		errs:                make(chan error, 1),
+	}
+}
+
+// Errors returns a channel that receives the first processing error reported by any worker
+// A partition stops processing after one of its workers reports an error so later offsets are never committed past it
+func (p *PartitionWorkers) Errors() <-chan error {
+	return p.errs
+}
+
+// SetWait sets a function called when processing a message fails. If it returns true, the message is processed again
+// instead of stopping the partition. It should block until the message is worth retrying, or return false once ctx is
+// canceled, such as when waiting for the circuit breaker to allow requests again.
+func (p *PartitionWorkers) SetWait(wait func(ctx context.Context, err error) bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wait = wait
+}
+
+// SetKey sets a function returning the key a message is routed to a lane by, such as its decoded key, instead of the
+// raw message key. If it returns an error, the message's partition is stopped like after a processing error.
+func (p *PartitionWorkers) SetKey(key func(msg *kafka.Message) ([]byte, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key = key
+}
+
+// Defer is called by process for a message whose outcome is only known after process returns, such as a request sent
+// in a batch. The message's offset is not completed when process returns; instead the returned function must be called
+// once with the outcome. A failed message stops its partition like an error returned by process. Deferred messages count
+// towards the partition's queue until they are done, so a partition waiting on its batches is paused like one with a
+// full queue. If the partition is no longer running, the returned function does nothing.
+func (p *PartitionWorkers) Defer(msg *kafka.Message) func(error) {
+	p.mu.Lock()
+	w, ok := p.workers[newPartitionKey(msg.TopicPartition)]
+	p.mu.Unlock()
+	if !ok {
+		return func(error) {}
+	}
+
+	offset := msg.TopicPartition.Offset
+	w.deferred.Store(offset, true)
+	w.queued.Add(1)
+	return func(err error) {
+		w.queued.Add(-1)
+		w.finish(offset, err, p.completed, p.errs)
+	}
+}
+
+// Start launches workers for each partition that does not already have them
+func (p *PartitionWorkers) Start(partitions []kafka.TopicPartition) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tp := range partitions {
+		p.startLocked(newPartitionKey(tp))
+	}
+}
+
+// Dispatch queues a message on the worker for its partition and key, starting the partition's workers if the
+// partition has not been assigned yet. It never blocks: partitions whose queues are full are paused through
+// Backpressure instead. If the partition has stopped after an error, the message is dropped and left uncommitted.
+func (p *PartitionWorkers) Dispatch(msg *kafka.Message) {
+	p.mu.Lock()
+	w := p.startLocked(newPartitionKey(msg.TopicPartition))
+	keyFor := p.key
+	p.mu.Unlock()
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	l := w.lanes[0]
+	if len(w.lanes) > 1 {
+		key := msg.Key
+		if keyFor != nil {
+			var err error
+			if key, err = keyFor(msg); err != nil {
+				// the message is left uncommitted and re-read once the consumer restarts
+				select {
+				case p.errs <- err:
+				default:
+				}
+				w.halt()
+				return
+			}
+		}
+		l = w.lanes[laneForKey(key, len(w.lanes))]
+	}
+
+	// offsets are tracked in dispatch order so commits never advance past a message still being processed
+	w.offsets.add(msg.TopicPartition.Offset)
+	w.queued.Add(1)
+	l.push(msg)
+}
+
+// Backpressure returns the partitions whose queues are full and should be paused, and the throttled partitions whose
+// queues have drained and can be resumed. Callers report the partitions they paused or resumed with SetThrottled.
+func (p *PartitionWorkers) Backpressure() (full, drained []kafka.TopicPartition) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, w := range p.workers {
+		queued := w.queued.Load()
+		topic := key.topic
+		tp := kafka.TopicPartition{Topic: &topic, Partition: key.partition}
+		if !w.throttled && queued >= partitionQueueSize {
+			full = append(full, tp)
+		} else if w.throttled && queued <= partitionQueueSize/2 {
+			drained = append(drained, tp)
+		}
+	}
+	return full, drained
+}
+
+// SetThrottled records whether the given partitions are paused because their queues are full
+func (p *PartitionWorkers) SetThrottled(partitions []kafka.TopicPartition, throttled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, tp := range partitions {
+		if w, ok := p.workers[newPartitionKey(tp)]; ok {
+			w.throttled = throttled
+		}
+	}
+}
+
+// IsThrottled returns true if the partition is paused because its queue is full
+func (p *PartitionWorkers) IsThrottled(tp kafka.TopicPartition) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.workers[newPartitionKey(tp)]
+	return ok && w.throttled
+}
+
+// InFlight returns the number of dispatched messages that have not finished processing on partitions that are running
+func (p *PartitionWorkers) InFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, w := range p.workers {
+		if w.ctx.Err() == nil {
+			n += w.offsets.unfinished()
+		}
+	}
+	return n
+}
+
+// Stop cancels the in-flight requests of the workers for the given partitions and waits for them to exit
+// Any queued or canceled messages that were not yet processed are discarded and will be re-read by the next partition owner
+func (p *PartitionWorkers) Stop(partitions []kafka.TopicPartition) {
+	var stopping []*partitionWorker
+	p.mu.Lock()
+	for _, tp := range partitions {
+		key := newPartitionKey(tp)
+		if w, ok := p.workers[key]; ok {
+			w.halt()
+			stopping = append(stopping, w)
+			delete(p.workers, key)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, w := range stopping {
+		<-w.done
+	}
+}
+
+// StopAll stops every running worker and waits for them to exit
+func (p *PartitionWorkers) StopAll() {
+	p.mu.Lock()
+	partitions := make([]kafka.TopicPartition, 0, len(p.workers))
+	for key := range p.workers {
+		topic := key.topic
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: key.partition})
+	}
+	p.mu.Unlock()
+	p.Stop(partitions)
+}
+
+// Len returns the number of partitions that currently have workers
+func (p *PartitionWorkers) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.workers)
+}
+
+// startLocked returns the worker for a partition, creating it if needed; p.mu must be held
+func (p *PartitionWorkers) startLocked(key partitionKey) *partitionWorker {
+	if w, ok := p.workers[key]; ok {
+		return w
+	}
+	w := &partitionWorker{
+		topic:     key.topic,
+		partition: key.partition,
+		lanes:     make([]*lane, p.workersPerPartition),
+		offsets:   newOffsetTracker(),
+		done:      make(chan struct{}),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for idx := range w.lanes {
+		w.lanes[idx] = &lane{signal: make(chan struct{}, 1)}
+		wg.Add(1)
+		go func(l *lane) {
+			defer wg.Done()
+			w.run(l, p.process, p.completed, p.wait, p.errs)
+		}(w.lanes[idx])
+	}
+	go func() {
+		wg.Wait()
+		close(w.done)
+	}()
+	p.workers[key] = w
+	return w
+}
+
+// halt signals every lane of the partition to stop and cancels their in-flight requests
+func (w *partitionWorker) halt() {
+	w.cancel()
+}
+
+func (w *partitionWorker) run(l *lane, process func(context.Context, *kafka.Message) error, completed func(kafka.TopicPartition), wait func(context.Context, error) bool, errs chan<- error) {
+	for {
+		msg, ok := l.pop(w.ctx)
+		// a stop request takes priority over any queued messages
+		if !ok || w.ctx.Err() != nil {
+			return
+		}
+		w.queued.Add(-1)
+
+		err := process(w.ctx, msg)
+		for err != nil && wait != nil && wait(w.ctx, err) {
+			err = process(w.ctx, msg)
+		}
+		if _, deferred := w.deferred.LoadAndDelete(msg.TopicPartition.Offset); deferred && err == nil {
+			continue
+		}
+		if !w.finish(msg.TopicPartition.Offset, err, completed, errs) {
+			return
+		}
+	}
+}
+
+// push queues a message and wakes the lane's worker
+func (l *lane) push(msg *kafka.Message) {
+	l.mu.Lock()
+	l.queue = append(l.queue, msg)
+	l.mu.Unlock()
+	select {
+	case l.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next queued message, returning false if ctx is canceled first
+func (l *lane) pop(ctx context.Context) (*kafka.Message, bool) {
+	for {
+		l.mu.Lock()
+		if len(l.queue) > 0 {
+			msg := l.queue[0]
+			l.queue[0] = nil
+			l.queue = l.queue[1:]
+			l.mu.Unlock()
+			return msg, true
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.signal:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// finish completes a processed offset, or reports the error and stops the partition if processing failed.
+// It returns false if the partition is stopped.
+func (w *partitionWorker) finish(offset kafka.Offset, err error, completed func(kafka.TopicPartition), errs chan<- error) bool {
+	if err != nil {
+		// a message canceled by stopping the partition is not an error; it is re-read by the next owner
+		if w.ctx.Err() != nil {
+			return false
+		}
+		select {
+		case errs <- err:
+		default:
+		}
+		// the remaining lanes stop as well since nothing past this offset can be committed
+		w.halt()
+		return false
+	}
+	if offset, advanced := w.offsets.complete(offset); advanced && completed != nil {
+		topic := w.topic
+		completed(kafka.TopicPartition{Topic: &topic, Partition: w.partition, Offset: offset})
+	}
+	return true
+}
+
+// laneForKey hashes the resource ID of a message key to select one of n lanes
+func laneForKey(key []byte, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(messageKeyID(key)))
+	return int(h.Sum32() % uint32(n))
+}
+
+// messageKeyID returns the resource ID captured in a Debezium message key
+// Object and string keys are supported with or without the schema envelope (see transforms.KeyID); any other key is used as-is
+func messageKeyID(key []byte) string {
+	if id, err := transforms.KeyID(key); err == nil && id != "" {
+		return id
+	}
+	return string(key)
+}
+
+// offsetTracker records the offsets dispatched for a partition in order and determines the highest offset
+// below which every dispatched message has been processed
+type offsetTracker struct {
+	mu       sync.Mutex
+	pending  []kafka.Offset
+	finished map[kafka.Offset]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{finished: make(map[kafka.Offset]bool)}
+}
+
+// add records a dispatched offset
+func (t *offsetTracker) add(offset kafka.Offset) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, offset)
+}
+
+// unfinished returns the number of dispatched offsets that have not been processed
+func (t *offsetTracker) unfinished() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending) - len(t.finished)
+}
+
+// complete marks an offset as processed and returns the lowest contiguous processed offset if it advanced
+func (t *offsetTracker) complete(offset kafka.Offset) (kafka.Offset, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished[offset] = true
+
+	var watermark kafka.Offset
+	advanced := false
+	for len(t.pending) > 0 && t.finished[t.pending[0]] {
+		watermark = t.pending[0]
+		delete(t.finished, watermark)
+		t.pending = t.pending[1:]
+		advanced = true
+	}
+	return watermark, advanced
+}
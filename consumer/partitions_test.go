@@ -0,0 +1,496 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestMessage(partition int32, offset int) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: ToPointer("test-topic"), Partition: partition, Offset: kafka.Offset(offset)},
+	}
+}
+
+func makeKeyedTestMessage(partition int32, offset int, id string) *kafka.Message {
+	msg := makeTestMessage(partition, offset)
+	msg.Key = []byte(`{"payload":{"id":"` + id + `"}}`)
+	return msg
+}
+
+func TestPartitionWorkers_ProcessesPartitionInOrder(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[int32][]kafka.Offset)
+	var wg sync.WaitGroup
+
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		processed[msg.TopicPartition.Partition] = append(processed[msg.TopicPartition.Partition], msg.TopicPartition.Offset)
+		return nil
+	}, nil)
+	defer workers.StopAll()
+
+	for offset := 0; offset < 20; offset++ {
+		for partition := int32(0); partition < 3; partition++ {
+			wg.Add(1)
+			workers.Dispatch(makeTestMessage(partition, offset))
+		}
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, workers.Len())
+	for partition := int32(0); partition < 3; partition++ {
+		assert.Len(t, processed[partition], 20)
+		for idx, offset := range processed[partition] {
+			assert.Equal(t, kafka.Offset(idx), offset)
+		}
+	}
+}
+
+func TestPartitionWorkers_SlowPartitionDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	fastDone := make(chan struct{})
+
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		if msg.TopicPartition.Partition == 0 {
+			<-release
+			return nil
+		}
+		close(fastDone)
+		return nil
+	}, nil)
+	defer workers.StopAll()
+
+	workers.Dispatch(makeTestMessage(0, 0))
+	workers.Dispatch(makeTestMessage(1, 0))
+
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("partition 1 was blocked by partition 0")
+	}
+	close(release)
+}
+
+func TestPartitionWorkers_ErrorStopsPartition(t *testing.T) {
+	var mu sync.Mutex
+	var processed []kafka.Offset
+	processErr := errors.New("processing failed")
+
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, msg.TopicPartition.Offset)
+		if msg.TopicPartition.Offset == 1 {
+			return processErr
+		}
+		return nil
+	}, nil)
+	defer workers.StopAll()
+
+	for offset := 0; offset < 5; offset++ {
+		workers.Dispatch(makeTestMessage(0, offset))
+	}
+
+	select {
+	case err := <-workers.Errors():
+		assert.Equal(t, processErr, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected worker error")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []kafka.Offset{0, 1}, processed)
+}
+
+func TestPartitionWorkers_WaitReprocessesMessage(t *testing.T) {
+	var mu sync.Mutex
+	var processed []kafka.Offset
+	processErr := errors.New("processing failed")
+	done := make(chan struct{})
+
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, msg.TopicPartition.Offset)
+		// the first message fails twice before it is processed
+		if len(processed) < 3 {
+			return processErr
+		}
+		if msg.TopicPartition.Offset == 1 {
+			close(done)
+		}
+		return nil
+	}, nil)
+	workers.SetWait(func(ctx context.Context, err error) bool { return errors.Is(err, processErr) })
+	defer workers.StopAll()
+
+	workers.Dispatch(makeTestMessage(0, 0))
+	workers.Dispatch(makeTestMessage(0, 1))
+
+	select {
+	case <-done:
+	case err := <-workers.Errors():
+		t.Fatalf("unexpected worker error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected messages to be processed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []kafka.Offset{0, 0, 0, 1}, processed)
+}
+
+func TestPartitionWorkers_StopCancelsInFlightMessage(t *testing.T) {
+	started := make(chan struct{})
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, func(kafka.TopicPartition) {
+		t.Error("canceled message must not be completed")
+	})
+
+	workers.Dispatch(makeTestMessage(0, 0))
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		workers.StopAll()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopping the partition did not cancel the in-flight message")
+	}
+
+	// a canceled message is re-read by the next partition owner rather than reported as an error
+	select {
+	case err := <-workers.Errors():
+		t.Fatalf("unexpected worker error: %v", err)
+	default:
+	}
+}
+
+func TestPartitionWorkers_DeferredMessages(t *testing.T) {
+	var mu sync.Mutex
+	var completed []kafka.Offset
+	dones := make(chan func(error), 3)
+
+	var workers *PartitionWorkers
+	workers = NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		dones <- workers.Defer(msg)
+		return nil
+	}, func(tp kafka.TopicPartition) {
+		mu.Lock()
+		defer mu.Unlock()
+		completed = append(completed, tp.Offset)
+	})
+	defer workers.StopAll()
+
+	for offset := 0; offset < 3; offset++ {
+		workers.Dispatch(makeTestMessage(0, offset))
+	}
+	first, second, third := <-dones, <-dones, <-dones
+
+	// offsets are only completed once every earlier deferred message is done
+	second(nil)
+	mu.Lock()
+	assert.Empty(t, completed)
+	mu.Unlock()
+	first(nil)
+	mu.Lock()
+	assert.Equal(t, []kafka.Offset{1}, completed)
+	mu.Unlock()
+
+	// a failed deferred message stops the partition
+	processErr := errors.New("processing failed")
+	third(processErr)
+	select {
+	case err := <-workers.Errors():
+		assert.Equal(t, processErr, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected worker error")
+	}
+}
+
+func TestPartitionWorkers_FullQueueDoesNotBlockDispatch(t *testing.T) {
+	release := make(chan struct{})
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}, nil)
+	defer workers.StopAll()
+
+	dispatched := make(chan struct{})
+	go func() {
+		for offset := 0; offset < partitionQueueSize*2; offset++ {
+			workers.Dispatch(makeTestMessage(0, offset))
+		}
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch blocked on a full partition queue")
+	}
+
+	// the full partition is reported once so it can be paused
+	full, drained := workers.Backpressure()
+	assert.Equal(t, []kafka.TopicPartition{{Topic: ToPointer("test-topic"), Partition: 0}}, full)
+	assert.Empty(t, drained)
+	workers.SetThrottled(full, true)
+	assert.True(t, workers.IsThrottled(full[0]))
+	full, drained = workers.Backpressure()
+	assert.Empty(t, full)
+	assert.Empty(t, drained)
+
+	// it is reported as drained once its queue is half empty
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, drained = workers.Backpressure()
+		return len(drained) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	workers.SetThrottled(drained, false)
+	assert.False(t, workers.IsThrottled(drained[0]))
+}
+
+func TestPartitionWorkers_StartAndStop(t *testing.T) {
+	workers := NewPartitionWorkers(1, func(ctx context.Context, msg *kafka.Message) error { return nil }, nil)
+	partitions := []kafka.TopicPartition{
+		{Topic: ToPointer("test-topic"), Partition: 0},
+		{Topic: ToPointer("test-topic"), Partition: 1},
+		{Topic: ToPointer("other-topic"), Partition: 0},
+	}
+
+	workers.Start(partitions)
+	assert.Equal(t, 3, workers.Len())
+
+	// starting an already running partition does not create a second worker
+	workers.Start(partitions[:1])
+	assert.Equal(t, 3, workers.Len())
+
+	workers.Stop(partitions[:2])
+	assert.Equal(t, 1, workers.Len())
+
+	workers.StopAll()
+	assert.Equal(t, 0, workers.Len())
+}
+
+func TestPartitionWorkers_KeyOrderedWithinPartition(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[string][]kafka.Offset)
+	var wg sync.WaitGroup
+
+	workers := NewPartitionWorkers(4, func(ctx context.Context, msg *kafka.Message) error {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		id := messageKeyID(msg.Key)
+		processed[id] = append(processed[id], msg.TopicPartition.Offset)
+		return nil
+	}, nil)
+	defer workers.StopAll()
+
+	ids := []string{"host-a", "host-b", "host-c", "host-d", "host-e"}
+	for offset := 0; offset < 50; offset++ {
+		wg.Add(1)
+		workers.Dispatch(makeKeyedTestMessage(0, offset, ids[offset%len(ids)]))
+	}
+	wg.Wait()
+
+	for idx, id := range ids {
+		assert.Len(t, processed[id], 10)
+		for seq, offset := range processed[id] {
+			assert.Equal(t, kafka.Offset(idx+seq*len(ids)), offset)
+		}
+	}
+}
+
+func TestPartitionWorkers_UnrelatedKeysProcessConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	slowStarted := make(chan struct{})
+	fastDone := make(chan struct{})
+	var committed []kafka.Offset
+	var mu sync.Mutex
+
+	// find two ids that hash to different lanes so the slow key cannot block the fast one
+	slowID, fastID := "host-0", ""
+	for idx := 1; fastID == ""; idx++ {
+		candidate := fmt.Sprintf("host-%d", idx)
+		if laneForKey([]byte(`{"payload":{"id":"`+candidate+`"}}`), 2) != laneForKey([]byte(`{"payload":{"id":"`+slowID+`"}}`), 2) {
+			fastID = candidate
+		}
+	}
+
+	workers := NewPartitionWorkers(2, func(ctx context.Context, msg *kafka.Message) error {
+		if messageKeyID(msg.Key) == slowID {
+			close(slowStarted)
+			<-release
+			return nil
+		}
+		close(fastDone)
+		return nil
+	}, func(tp kafka.TopicPartition) {
+		mu.Lock()
+		defer mu.Unlock()
+		committed = append(committed, tp.Offset)
+	})
+
+	workers.Dispatch(makeKeyedTestMessage(0, 0, slowID))
+	workers.Dispatch(makeKeyedTestMessage(0, 1, fastID))
+
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("unrelated key was blocked by a slow key in the same partition")
+	}
+	// the slow message must be in flight before stopping, otherwise it is discarded from the queue
+	<-slowStarted
+
+	// offset 1 is processed but cannot be committed until offset 0 completes
+	mu.Lock()
+	assert.Empty(t, committed)
+	mu.Unlock()
+
+	close(release)
+	workers.StopAll()
+	// offset 0 is only stored on its own if it completes before offset 1 is marked processed
+	assert.NotEmpty(t, committed)
+	assert.Equal(t, kafka.Offset(1), committed[len(committed)-1])
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker()
+	for _, offset := range []kafka.Offset{3, 4, 7, 8} {
+		tracker.add(offset)
+	}
+
+	_, advanced := tracker.complete(7)
+	assert.False(t, advanced)
+	_, advanced = tracker.complete(4)
+	assert.False(t, advanced)
+
+	watermark, advanced := tracker.complete(3)
+	assert.True(t, advanced)
+	assert.Equal(t, kafka.Offset(7), watermark)
+
+	watermark, advanced = tracker.complete(8)
+	assert.True(t, advanced)
+	assert.Equal(t, kafka.Offset(8), watermark)
+}
+
+func TestMessageKeyID(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      []byte
+		expected string
+	}{
+		{
+			name:     "struct payload key",
+			key:      []byte(testMigrationKey),
+			expected: "00000000-0000-0000-0000-000000000000",
+		},
+		{
+			name:     "string payload key",
+			key:      []byte(testMessageKey),
+			expected: "00000000-0000-0000-0000-000000000000",
+		},
+		{
+			name:     "schemaless struct key",
+			key:      []byte(`{"id":"00000000-0000-0000-0000-000000000000"}`),
+			expected: "00000000-0000-0000-0000-000000000000",
+		},
+		{
+			name:     "plain key",
+			key:      []byte("my-key"),
+			expected: "my-key",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, messageKeyID(test.key))
+		})
+	}
+}
+
+func TestPartitionWorkers_SetKeyRoutesByKey(t *testing.T) {
+	const workersPerPartition = 8
+	// the raw keys of the two messages route them to different lanes, but they share a key once the prefix is stripped
+	key := []byte(`{"payload":{"id":"host-1"}}`)
+	encoded := append([]byte{0}, key...)
+	for laneForKey(encoded, workersPerPartition) == laneForKey(key, workersPerPartition) {
+		encoded = append([]byte{0}, encoded...)
+	}
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var processed []kafka.Offset
+	var wg sync.WaitGroup
+	wg.Add(2)
+	workers := NewPartitionWorkers(workersPerPartition, func(ctx context.Context, msg *kafka.Message) error {
+		defer wg.Done()
+		if msg.TopicPartition.Offset == 0 {
+			<-release
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, msg.TopicPartition.Offset)
+		return nil
+	}, nil)
+	workers.SetKey(func(msg *kafka.Message) ([]byte, error) {
+		return bytes.TrimLeft(msg.Key, "\x00"), nil
+	})
+	defer workers.StopAll()
+
+	first := makeTestMessage(0, 0)
+	first.Key = key
+	second := makeTestMessage(0, 1)
+	second.Key = encoded
+	workers.Dispatch(first)
+	workers.Dispatch(second)
+
+	// the second message waits for the first since they are routed to the same lane
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []kafka.Offset{0, 1}, processed)
+}
+
+func TestPartitionWorkers_SetKeyErrorStopsPartition(t *testing.T) {
+	keyErr := errors.New("registry unavailable")
+	workers := NewPartitionWorkers(2, func(ctx context.Context, msg *kafka.Message) error {
+		t.Error("message with a key error was processed")
+		return nil
+	}, nil)
+	workers.SetKey(func(msg *kafka.Message) ([]byte, error) { return nil, keyErr })
+	defer workers.StopAll()
+
+	workers.Dispatch(makeKeyedTestMessage(0, 0, "host-1"))
+
+	select {
+	case err := <-workers.Errors():
+		assert.Equal(t, keyErr, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected key error")
+	}
+	assert.Equal(t, 0, workers.InFlight())
+}
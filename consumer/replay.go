@@ -0,0 +1,238 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	// replayTimeoutMs is the timeout used for metadata and offset lookups when replaying a dead-letter topic
+	replayTimeoutMs = 10000
+	// replayReadTimeout is how long to wait for the next message before checking if the replay has stalled
+	replayReadTimeout = time.Second
+	// replayMaxIdleReads is the number of consecutive empty reads allowed before a replay gives up on the remaining partitions
+	replayMaxIdleReads = 30
+)
+
+// ReplayReader defines the kafka consumer methods used to read a dead-letter topic from a start to an end offset
+type ReplayReader interface {
+	GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error)
+	QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (low, high int64, err error)
+	OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) (offsets []kafka.TopicPartition, err error)
+	Assign(partitions []kafka.TopicPartition) (err error)
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+}
+
+// ReplayFilter selects which dead-lettered messages are replayed; zero values match every message
+type ReplayFilter struct {
+	// Partition limits the replay to a single partition; -1 replays all partitions
+	Partition int32
+	// StartOffset and EndOffset are inclusive bounds applied to each partition; -1 leaves the bound open
+	StartOffset int64
+	EndOffset   int64
+	// StartTime and EndTime are inclusive bounds on the time a message was dead-lettered
+	StartTime time.Time
+	EndTime   time.Time
+	// Stage matches the processing stage recorded in the dead-letter headers
+	Stage string
+	// ResourceID matches the resource ID captured in the message key
+	ResourceID string
+}
+
+// NewReplayFilter returns a ReplayFilter that matches every message
+func NewReplayFilter() ReplayFilter {
+	return ReplayFilter{Partition: -1, StartOffset: -1, EndOffset: -1}
+}
+
+// Matches returns true if the message satisfies every bound set on the filter
+func (f ReplayFilter) Matches(msg *kafka.Message) bool {
+	if f.Partition >= 0 && msg.TopicPartition.Partition != f.Partition {
+		return false
+	}
+	offset := int64(msg.TopicPartition.Offset)
+	if f.StartOffset >= 0 && offset < f.StartOffset {
+		return false
+	}
+	if f.EndOffset >= 0 && offset > f.EndOffset {
+		return false
+	}
+	if !f.StartTime.IsZero() && msg.Timestamp.Before(f.StartTime) {
+		return false
+	}
+	if !f.EndTime.IsZero() && msg.Timestamp.After(f.EndTime) {
+		return false
+	}
+	if f.Stage != "" && headerValue(msg.Headers, DeadLetterHeaderStage) != f.Stage {
+		return false
+	}
+	if f.ResourceID != "" && messageKeyID(msg.Key) != f.ResourceID {
+		return false
+	}
+	return true
+}
+
+// ReplayResult summarizes a dead-letter replay
+type ReplayResult struct {
+	Replayed int
+	Skipped  int
+	Failed   int
+}
+
+// Replay reads a dead-letter topic from the earliest offset allowed by the filter up to its current end and feeds each
+// matching message back through ProcessMessage using the original message headers and the settings of the topic it was
+// dead-lettered from. Messages that fail again are logged and counted, and the replay continues with the next message.
+// Offsets are never committed. Canceling ctx stops the replay and cancels the in-flight request.
+func (i *InventoryConsumer) Replay(ctx context.Context, reader ReplayReader, topic string, filter ReplayFilter) (ReplayResult, error) {
+	var result ReplayResult
+
+	ranges, err := replayRanges(reader, topic, filter)
+	if err != nil {
+		return result, err
+	}
+	if len(ranges) == 0 {
+		i.Logger.Infof("no messages to replay from topic %s", topic)
+		return result, nil
+	}
+
+	assignments := make([]kafka.TopicPartition, 0, len(ranges))
+	for partition, bounds := range ranges {
+		assignments = append(assignments, kafka.TopicPartition{Topic: &topic, Partition: partition, Offset: kafka.Offset(bounds[0])})
+	}
+	if err := reader.Assign(assignments); err != nil {
+		return result, fmt.Errorf("failed to assign partitions for topic %s: %w", topic, err)
+	}
+
+	idleReads := 0
+	for len(ranges) > 0 {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		msg, err := reader.ReadMessage(replayReadTimeout)
+		if err != nil {
+			var kafkaErr kafka.Error
+			if errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrTimedOut {
+				idleReads++
+				if idleReads >= replayMaxIdleReads {
+					return result, fmt.Errorf("timed out waiting for messages from %d partition(s) of topic %s", len(ranges), topic)
+				}
+				continue
+			}
+			return result, fmt.Errorf("failed to read message from topic %s: %w", topic, err)
+		}
+		idleReads = 0
+
+		partition := msg.TopicPartition.Partition
+		bounds, ok := ranges[partition]
+		if !ok {
+			continue
+		}
+		offset := int64(msg.TopicPartition.Offset)
+		if offset >= bounds[1] {
+			delete(ranges, partition)
+		}
+		if offset > bounds[1] {
+			continue
+		}
+
+		if !filter.Matches(msg) {
+			result.Skipped++
+			continue
+		}
+
+		// dead-lettered messages are in their original format, so they are decoded before they are parsed
+		decoded, err := i.decodeMessage(sourceMessage(msg))
+		if err == nil {
+			var headers EventHeaders
+			var event *kafka.Message
+			headers, event, err = i.parseMessage(decoded)
+			if err == nil {
+				err = i.ProcessMessage(ctx, headers, event)
+			}
+		}
+		if err != nil {
+			result.Failed++
+			i.Logger.Errorf("failed to replay message: partition=%d offset=%s: %v", partition, msg.TopicPartition.Offset, err)
+			continue
+		}
+		result.Replayed++
+		i.Logger.Infof("replayed message: partition=%d offset=%s source=%s[%s]@%s", partition, msg.TopicPartition.Offset,
+			headerValue(msg.Headers, DeadLetterHeaderSourceTopic),
+			headerValue(msg.Headers, DeadLetterHeaderSourcePartition),
+			headerValue(msg.Headers, DeadLetterHeaderSourceOffset))
+	}
+	return result, nil
+}
+
+// sourceMessage returns a copy of a dead-lettered message with the topic it was dead-lettered from, so the per-topic
+// settings of that topic are used to process it. Messages without the source topic header keep the dead-letter topic.
+func sourceMessage(msg *kafka.Message) *kafka.Message {
+	topic := headerValue(msg.Headers, DeadLetterHeaderSourceTopic)
+	if topic == "" {
+		return msg
+	}
+	source := *msg
+	source.TopicPartition.Topic = &topic
+	return &source
+}
+
+// replayRanges returns the inclusive [start, end] offsets to read for each partition of the topic that may contain
+// messages matching the filter
+func replayRanges(reader ReplayReader, topic string, filter ReplayFilter) (map[int32][2]int64, error) {
+	metadata, err := reader.GetMetadata(&topic, false, replayTimeoutMs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata for topic %s: %w", topic, err)
+	}
+	topicMetadata, ok := metadata.Topics[topic]
+	if !ok || topicMetadata.Error.Code() != kafka.ErrNoError {
+		return nil, fmt.Errorf("topic %s not found: %v", topic, topicMetadata.Error)
+	}
+
+	ranges := make(map[int32][2]int64)
+	for _, partitionMetadata := range topicMetadata.Partitions {
+		partition := partitionMetadata.ID
+		if filter.Partition >= 0 && partition != filter.Partition {
+			continue
+		}
+		low, high, err := reader.QueryWatermarkOffsets(topic, partition, replayTimeoutMs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query offsets for partition %d: %w", partition, err)
+		}
+
+		start, end := max(low, filter.StartOffset), high-1
+		if filter.EndOffset >= 0 {
+			end = min(end, filter.EndOffset)
+		}
+		if !filter.StartTime.IsZero() {
+			offsets, err := reader.OffsetsForTimes([]kafka.TopicPartition{
+				{Topic: &topic, Partition: partition, Offset: kafka.Offset(filter.StartTime.UnixMilli())},
+			}, replayTimeoutMs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up offsets by time for partition %d: %w", partition, err)
+			}
+			// a negative offset means no message was dead-lettered on the partition after the start time
+			if len(offsets) == 0 || offsets[0].Offset < 0 {
+				continue
+			}
+			start = max(start, int64(offsets[0].Offset))
+		}
+		if start > end {
+			continue
+		}
+		ranges[partition] = [2]int64{start, end}
+	}
+	return ranges, nil
+}
+
+// headerValue returns the value of the first header with the given key
+func headerValue(headers []kafka.Header, key string) string {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
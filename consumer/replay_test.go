@@ -0,0 +1,197 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	. "github.com/project-kessel/inventory-api/cmd/common"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testDeadLetterTopic = "test-topic.dlq"
+
+func makeDeadLetterMessage(partition int32, offset int, stage string, key string, value string) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: ToPointer(testDeadLetterTopic), Partition: partition, Offset: kafka.Offset(offset)},
+		Key:            []byte(key),
+		Value:          []byte(value),
+		Timestamp:      time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC),
+		Headers: []kafka.Header{
+			{Key: "operation", Value: []byte(OperationTypeReportResource)},
+			{Key: "version", Value: []byte(defaultApiVersion)},
+			{Key: DeadLetterHeaderStage, Value: []byte(stage)},
+		},
+	}
+}
+
+func TestReplayFilter_Matches(t *testing.T) {
+	msg := makeDeadLetterMessage(1, 15, "ParseCreateOrUpdateMessage", testMigrationKey, testCreateOrUpdateMessage)
+
+	tests := []struct {
+		name     string
+		filter   func(*ReplayFilter)
+		expected bool
+	}{
+		{
+			name:     "empty filter matches every message",
+			filter:   func(f *ReplayFilter) {},
+			expected: true,
+		},
+		{
+			name:     "partition does not match",
+			filter:   func(f *ReplayFilter) { f.Partition = 0 },
+			expected: false,
+		},
+		{
+			name:     "offset within range",
+			filter:   func(f *ReplayFilter) { f.StartOffset, f.EndOffset = 10, 15 },
+			expected: true,
+		},
+		{
+			name:     "offset before range",
+			filter:   func(f *ReplayFilter) { f.StartOffset = 16 },
+			expected: false,
+		},
+		{
+			name:     "time within range",
+			filter:   func(f *ReplayFilter) { f.StartTime, f.EndTime = msg.Timestamp.Add(-time.Hour), msg.Timestamp },
+			expected: true,
+		},
+		{
+			name:     "time after range",
+			filter:   func(f *ReplayFilter) { f.EndTime = msg.Timestamp.Add(-time.Minute) },
+			expected: false,
+		},
+		{
+			name:     "stage matches",
+			filter:   func(f *ReplayFilter) { f.Stage = "ParseCreateOrUpdateMessage" },
+			expected: true,
+		},
+		{
+			name:     "stage does not match",
+			filter:   func(f *ReplayFilter) { f.Stage = "ParseHeaders" },
+			expected: false,
+		},
+		{
+			name:     "resource id matches",
+			filter:   func(f *ReplayFilter) { f.ResourceID = "00000000-0000-0000-0000-000000000000" },
+			expected: true,
+		},
+		{
+			name:     "resource id does not match",
+			filter:   func(f *ReplayFilter) { f.ResourceID = "11111111-1111-1111-1111-111111111111" },
+			expected: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := NewReplayFilter()
+			test.filter(&filter)
+			assert.Equal(t, test.expected, filter.Matches(msg))
+		})
+	}
+}
+
+func TestInventoryConsumer_Replay(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil).Twice()
+	tester.inv.Client = client
+
+	reader := &mocks.MockReplayReader{}
+	reader.On("GetMetadata", mock.Anything, false, mock.Anything).Return(&kafka.Metadata{
+		Topics: map[string]kafka.TopicMetadata{
+			testDeadLetterTopic: {
+				Topic:      testDeadLetterTopic,
+				Partitions: []kafka.PartitionMetadata{{ID: 0}, {ID: 1}},
+			},
+		},
+	}, nil)
+	reader.On("QueryWatermarkOffsets", testDeadLetterTopic, int32(0), mock.Anything).Return(int64(0), int64(3), nil)
+	// partition 1 is empty and is not assigned
+	reader.On("QueryWatermarkOffsets", testDeadLetterTopic, int32(1), mock.Anything).Return(int64(5), int64(5), nil)
+	reader.On("Assign", []kafka.TopicPartition{{Topic: ToPointer(testDeadLetterTopic), Partition: 0, Offset: kafka.Offset(0)}}).Return(nil)
+	reader.On("ReadMessage", mock.Anything).Return(makeDeadLetterMessage(0, 0, "ParseCreateOrUpdateMessage", testMessageKey, testCreateOrUpdateMessage), nil).Once()
+	reader.On("ReadMessage", mock.Anything).Return(nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)).Once()
+	reader.On("ReadMessage", mock.Anything).Return(makeDeadLetterMessage(0, 1, "ParseHeaders", testMessageKey, testCreateOrUpdateMessage), nil).Once()
+	reader.On("ReadMessage", mock.Anything).Return(makeDeadLetterMessage(0, 2, "ParseCreateOrUpdateMessage", testMessageKey, testCreateOrUpdateMessage), nil).Once()
+
+	filter := NewReplayFilter()
+	filter.Stage = "ParseCreateOrUpdateMessage"
+	result, err := tester.inv.Replay(context.Background(), reader, testDeadLetterTopic, filter)
+	assert.Nil(t, err)
+	assert.Equal(t, ReplayResult{Replayed: 2, Skipped: 1, Failed: 0}, result)
+	reader.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestInventoryConsumer_ReplayCountsFailures(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true).Maybe()
+	tester.inv.Client = client
+
+	reader := &mocks.MockReplayReader{}
+	reader.On("GetMetadata", mock.Anything, false, mock.Anything).Return(&kafka.Metadata{
+		Topics: map[string]kafka.TopicMetadata{
+			testDeadLetterTopic: {Topic: testDeadLetterTopic, Partitions: []kafka.PartitionMetadata{{ID: 0}}},
+		},
+	}, nil)
+	reader.On("QueryWatermarkOffsets", testDeadLetterTopic, int32(0), mock.Anything).Return(int64(0), int64(10), nil)
+	reader.On("Assign", []kafka.TopicPartition{{Topic: ToPointer(testDeadLetterTopic), Partition: 0, Offset: kafka.Offset(4)}}).Return(nil)
+	reader.On("ReadMessage", mock.Anything).Return(makeDeadLetterMessage(0, 4, "ParseCreateOrUpdateMessage", testMessageKey, `{invalid`), nil).Once()
+
+	filter := NewReplayFilter()
+	filter.StartOffset, filter.EndOffset = 4, 4
+	result, err := tester.inv.Replay(context.Background(), reader, testDeadLetterTopic, filter)
+	assert.Nil(t, err)
+	assert.Equal(t, ReplayResult{Replayed: 0, Skipped: 0, Failed: 1}, result)
+	reader.AssertExpectations(t)
+}
+
+func TestInventoryConsumer_ReplayUsesSourceTopicOptions(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil).Once()
+	tester.inv.Client = client
+	// messages from the source topic have no operation and version headers
+	tester.inv.TopicOptions = map[string]*TopicOptions{
+		"platform.events": {Operation: OperationTypeReportResource},
+	}
+
+	msg := makeDeadLetterMessage(0, 0, "ProcessMessage", testMessageKey, testCreateOrUpdateMessage)
+	msg.Headers = []kafka.Header{{Key: DeadLetterHeaderSourceTopic, Value: []byte("platform.events")}}
+	reader := &mocks.MockReplayReader{}
+	reader.On("GetMetadata", mock.Anything, false, mock.Anything).Return(&kafka.Metadata{
+		Topics: map[string]kafka.TopicMetadata{
+			testDeadLetterTopic: {Topic: testDeadLetterTopic, Partitions: []kafka.PartitionMetadata{{ID: 0}}},
+		},
+	}, nil)
+	reader.On("QueryWatermarkOffsets", testDeadLetterTopic, int32(0), mock.Anything).Return(int64(0), int64(1), nil)
+	reader.On("Assign", []kafka.TopicPartition{{Topic: ToPointer(testDeadLetterTopic), Partition: 0, Offset: kafka.Offset(0)}}).Return(nil)
+	reader.On("ReadMessage", mock.Anything).Return(msg, nil).Once()
+
+	result, err := tester.inv.Replay(context.Background(), reader, testDeadLetterTopic, NewReplayFilter())
+	assert.Nil(t, err)
+	assert.Equal(t, ReplayResult{Replayed: 1}, result)
+	// the message read from the dead-letter topic is unchanged
+	assert.Equal(t, testDeadLetterTopic, *msg.TopicPartition.Topic)
+	reader.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
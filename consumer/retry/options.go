@@ -1,14 +1,27 @@
 package retry
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/spf13/pflag"
 )
 
 type Options struct {
-	ConsumerMaxRetries  int `mapstructure:"consumer-max-retries"`
-	OperationMaxRetries int `mapstructure:"operation-max-retries"`
-	BackoffFactor       int `mapstructure:"backoff-factor"`
-	MaxBackoffSeconds   int `mapstructure:"max-backoff-seconds"`
+	ConsumerMaxRetries  int                          `mapstructure:"consumer-max-retries"`
+	OperationMaxRetries int                          `mapstructure:"operation-max-retries"`
+	BackoffFactor       int                          `mapstructure:"backoff-factor"`
+	MaxBackoffSeconds   int                          `mapstructure:"max-backoff-seconds"`
+	RetryableCodes      []string                     `mapstructure:"retryable-codes"`
+	TerminalCodes       []string                     `mapstructure:"terminal-codes"`
+	Operations          map[string]*OperationOptions `mapstructure:"operations"`
+}
+
+// OperationOptions overrides the retries for a single Inventory API operation; unset fields use the retry options
+type OperationOptions struct {
+	OperationMaxRetries int      `mapstructure:"operation-max-retries"`
+	RetryableCodes      []string `mapstructure:"retryable-codes"`
+	TerminalCodes       []string `mapstructure:"terminal-codes"`
 }
 
 func NewOptions() *Options {
@@ -17,6 +30,7 @@ func NewOptions() *Options {
 		OperationMaxRetries: 3,
 		BackoffFactor:       5,
 		MaxBackoffSeconds:   30,
+		TerminalCodes:       slices.Clone(DefaultTerminalCodes),
 	}
 }
 
@@ -28,4 +42,47 @@ func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
 	fs.IntVar(&o.OperationMaxRetries, prefix+"operation-max-retries", o.OperationMaxRetries, "sets the max number of retries to execute a request before failing out (default: 3)")
 	fs.IntVar(&o.BackoffFactor, prefix+"backoff-factor", o.BackoffFactor, "value used to calculate backoff between requests/restarts (default: 5)")
 	fs.IntVar(&o.MaxBackoffSeconds, prefix+"max-backoff-seconds", o.MaxBackoffSeconds, "maximum amount of time between retries for the consumer in seconds (default: 30)")
+	fs.StringSliceVar(&o.RetryableCodes, prefix+"retryable-codes", o.RetryableCodes, "gRPC codes of failed requests that are retried; when set, failed requests with any other code are not retried and are sent to the dead-letter topic")
+	fs.StringSliceVar(&o.TerminalCodes, prefix+"terminal-codes", o.TerminalCodes, "gRPC codes of failed requests that are not retried and are sent to the dead-letter topic, when retryable codes are not set")
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+
+	errs = append(errs, validateCodes("", o.RetryableCodes, o.TerminalCodes)...)
+	for operation, override := range o.Operations {
+		if override != nil {
+			errs = append(errs, validateCodes(operation, override.RetryableCodes, override.TerminalCodes)...)
+		}
+	}
+	return errs
+}
+
+// validateCodes checks that every code name is a gRPC code and that no code is both retryable and terminal
+func validateCodes(operation string, retryableCodes, terminalCodes []string) []error {
+	var errs []error
+	if operation != "" {
+		operation = " for operation " + operation
+	}
+
+	retryable := make(map[string]bool)
+	for _, name := range retryableCodes {
+		code, err := ParseCode(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid retryable code%s: %w", operation, err))
+			continue
+		}
+		retryable[code.String()] = true
+	}
+	for _, name := range terminalCodes {
+		code, err := ParseCode(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid terminal code%s: %w", operation, err))
+			continue
+		}
+		if retryable[code.String()] {
+			errs = append(errs, fmt.Errorf("code %s%s can not be both retryable and terminal", code, operation))
+		}
+	}
+	return errs
 }
@@ -19,6 +19,7 @@ func TestNewOptions(t *testing.T) {
 			OperationMaxRetries: 3,
 			BackoffFactor:       5,
 			MaxBackoffSeconds:   30,
+			TerminalCodes:       DefaultTerminalCodes,
 		},
 	}
 	assert.Equal(t, test.expectedOptions, NewOptions())
@@ -34,5 +35,54 @@ func TestOptions_AddFlags(t *testing.T) {
 	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
 	test.options.AddFlags(fs, prefix)
 
-	common.AllOptionsHaveFlags(t, prefix, fs, *test.options, nil)
+	// operations can only be set in the config file
+	common.AllOptionsHaveFlags(t, prefix, fs, *test.options, []string{"operations"})
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *Options
+		expectError bool
+	}{
+		{
+			name:        "default options are valid",
+			options:     NewOptions(),
+			expectError: false,
+		},
+		{
+			name:        "code names are case insensitive",
+			options:     &Options{RetryableCodes: []string{"UNAVAILABLE", "deadline_exceeded"}, TerminalCodes: []string{"InvalidArgument"}},
+			expectError: false,
+		},
+		{
+			name:        "unknown codes are invalid",
+			options:     &Options{TerminalCodes: []string{"Unavailable", "NotACode"}},
+			expectError: true,
+		},
+		{
+			name:        "codes can not be both retryable and terminal",
+			options:     &Options{RetryableCodes: []string{"Unavailable"}, TerminalCodes: []string{"UNAVAILABLE"}},
+			expectError: true,
+		},
+		{
+			name: "operation codes are validated",
+			options: &Options{
+				Operations: map[string]*OperationOptions{
+					"DeleteResource": {TerminalCodes: []string{"NotACode"}},
+				},
+			},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate()
+			if test.expectError {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
 }
@@ -0,0 +1,115 @@
+package retry
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// backoffUnit is the delay multiplied by the backoff factor to get the base delay before the first retry
+const backoffUnit = 300 * time.Millisecond
+
+// DefaultTerminalCodes are the gRPC codes of failed requests that will never succeed as-is
+var DefaultTerminalCodes = []string{
+	codes.InvalidArgument.String(),
+	codes.AlreadyExists.String(),
+	codes.PermissionDenied.String(),
+	codes.FailedPrecondition.String(),
+	codes.OutOfRange.String(),
+	codes.Unimplemented.String(),
+}
+
+// Policy decides whether a failed request is retried and how long to wait before the next attempt
+type Policy struct {
+	// MaxAttempts is the number of times a request is attempted, or -1 to retry until it succeeds
+	MaxAttempts int
+	// BaseBackoff is the maximum delay before the first retry, doubling with each attempt up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// RetryableCodes, when not empty, are the only gRPC codes that are retried and every other code is terminal.
+	// Otherwise, TerminalCodes are terminal and other codes are retried. Errors that are not gRPC errors are retried.
+	RetryableCodes map[codes.Code]bool
+	TerminalCodes  map[codes.Code]bool
+}
+
+// Policy returns the retry policy for an Inventory API operation, such as ReportResource, applying any overrides
+// configured for the operation. Code names are expected to have been checked by Validate; unknown names are ignored.
+func (o *Options) Policy(operation string) *Policy {
+	maxAttempts := o.OperationMaxRetries
+	retryableCodes, terminalCodes := o.RetryableCodes, o.TerminalCodes
+	if override, ok := o.Operations[operation]; ok && override != nil {
+		if override.OperationMaxRetries != 0 {
+			maxAttempts = override.OperationMaxRetries
+		}
+		if len(override.RetryableCodes) > 0 {
+			retryableCodes = override.RetryableCodes
+		}
+		if len(override.TerminalCodes) > 0 {
+			terminalCodes = override.TerminalCodes
+		}
+	}
+
+	policy := &Policy{
+		MaxAttempts:    maxAttempts,
+		BaseBackoff:    time.Duration(o.BackoffFactor) * backoffUnit,
+		MaxBackoff:     time.Duration(o.MaxBackoffSeconds) * time.Second,
+		RetryableCodes: make(map[codes.Code]bool),
+		TerminalCodes:  make(map[codes.Code]bool),
+	}
+	for _, name := range retryableCodes {
+		if code, err := ParseCode(name); err == nil {
+			policy.RetryableCodes[code] = true
+		}
+	}
+	for _, name := range terminalCodes {
+		if code, err := ParseCode(name); err == nil {
+			policy.TerminalCodes[code] = true
+		}
+	}
+	return policy
+}
+
+// ShouldRetry returns true if another attempt is allowed after the given number of failed attempts
+func (p *Policy) ShouldRetry(attempts int) bool {
+	return p.MaxAttempts == -1 || attempts < p.MaxAttempts
+}
+
+// IsTerminal returns true if the error has a gRPC code classified as terminal, so retrying the request is pointless
+func (p *Policy) IsTerminal(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	if len(p.RetryableCodes) > 0 {
+		return !p.RetryableCodes[st.Code()]
+	}
+	return p.TerminalCodes[st.Code()]
+}
+
+// Backoff returns the delay before retrying after the given number of failed attempts, using exponential backoff with
+// full jitter so consumers retrying the same failure do not retry in lockstep
+func (p *Policy) Backoff(attempts int) time.Duration {
+	if attempts < 1 || p.BaseBackoff <= 0 || p.MaxBackoff <= 0 {
+		return 0
+	}
+	ceiling := p.BaseBackoff
+	for n := 1; n < attempts && ceiling < p.MaxBackoff; n++ {
+		ceiling *= 2
+	}
+	return rand.N(min(ceiling, p.MaxBackoff) + 1)
+}
+
+// ParseCode returns the gRPC code for a name such as Unavailable or UNAVAILABLE
+func ParseCode(name string) (codes.Code, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if strings.ToLower(code.String()) == normalized {
+			return code, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown gRPC code %s", name)
+}
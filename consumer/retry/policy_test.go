@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOptions_Policy(t *testing.T) {
+	options := NewOptions()
+	options.Operations = map[string]*OperationOptions{
+		"DeleteResource": {
+			OperationMaxRetries: 5,
+			TerminalCodes:       []string{"InvalidArgument", "NotFound"},
+		},
+	}
+
+	policy := options.Policy("ReportResource")
+	assert.Equal(t, 3, policy.MaxAttempts)
+	assert.Equal(t, 1500*time.Millisecond, policy.BaseBackoff)
+	assert.Equal(t, 30*time.Second, policy.MaxBackoff)
+	assert.True(t, policy.TerminalCodes[codes.PermissionDenied])
+	assert.False(t, policy.TerminalCodes[codes.NotFound])
+	assert.Empty(t, policy.RetryableCodes)
+
+	policy = options.Policy("DeleteResource")
+	assert.Equal(t, 5, policy.MaxAttempts)
+	assert.True(t, policy.TerminalCodes[codes.NotFound])
+	assert.False(t, policy.TerminalCodes[codes.PermissionDenied])
+
+	options.Operations["DeleteResource"].RetryableCodes = []string{"Unavailable"}
+	policy = options.Policy("DeleteResource")
+	assert.True(t, policy.RetryableCodes[codes.Unavailable])
+	assert.False(t, policy.RetryableCodes[codes.Internal])
+}
+
+func TestPolicy_IsTerminal(t *testing.T) {
+	policy := NewOptions().Policy("")
+	tests := []struct {
+		name     string
+		err      error
+		terminal bool
+	}{
+		{name: "invalid argument is terminal", err: status.Error(codes.InvalidArgument, "bad request"), terminal: true},
+		{name: "permission denied is terminal", err: status.Error(codes.PermissionDenied, "denied"), terminal: true},
+		{name: "wrapped terminal errors are terminal", err: fmt.Errorf("request failed: %w", status.Error(codes.InvalidArgument, "bad request")), terminal: true},
+		{name: "unavailable is retried", err: status.Error(codes.Unavailable, "unavailable"), terminal: false},
+		{name: "unclassified codes are retried", err: status.Error(codes.NotFound, "not found"), terminal: false},
+		{name: "errors without a code are retried", err: errors.New("connection reset"), terminal: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.terminal, policy.IsTerminal(test.err))
+		})
+	}
+}
+
+func TestPolicy_IsTerminalWithRetryableCodes(t *testing.T) {
+	options := NewOptions()
+	options.RetryableCodes = []string{"Unavailable", "DeadlineExceeded"}
+	options.TerminalCodes = []string{"InvalidArgument"}
+	policy := options.Policy("")
+	tests := []struct {
+		name     string
+		err      error
+		terminal bool
+	}{
+		{name: "retryable codes are retried", err: status.Error(codes.Unavailable, "unavailable"), terminal: false},
+		{name: "terminal codes are terminal", err: status.Error(codes.InvalidArgument, "bad request"), terminal: true},
+		{name: "codes that are not retryable are terminal", err: status.Error(codes.Internal, "internal"), terminal: true},
+		{name: "errors without a code are retried", err: errors.New("connection reset"), terminal: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.terminal, policy.IsTerminal(test.err))
+		})
+	}
+}
+
+func TestPolicy_ShouldRetry(t *testing.T) {
+	policy := &Policy{MaxAttempts: 2}
+	assert.True(t, policy.ShouldRetry(1))
+	assert.False(t, policy.ShouldRetry(2))
+
+	policy = &Policy{MaxAttempts: -1}
+	assert.True(t, policy.ShouldRetry(100))
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	policy := &Policy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempts, ceiling := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	} {
+		for range 100 {
+			backoff := policy.Backoff(attempts)
+			assert.GreaterOrEqual(t, backoff, time.Duration(0))
+			assert.LessOrEqual(t, backoff, ceiling)
+		}
+	}
+
+	assert.Equal(t, time.Duration(0), policy.Backoff(0))
+	assert.Equal(t, time.Duration(0), (&Policy{MaxBackoff: time.Second}).Backoff(3))
+}
+
+func TestParseCode(t *testing.T) {
+	for _, name := range []string{"InvalidArgument", "INVALID_ARGUMENT", "invalidargument"} {
+		code, err := ParseCode(name)
+		assert.Nil(t, err)
+		assert.Equal(t, codes.InvalidArgument, code)
+	}
+	_, err := ParseCode("NotACode")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,106 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Schema types returned by the registry; schemas without a type are Avro
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+)
+
+// ErrRegistryUnavailable is returned when the schema registry could not be reached or failed to respond; unlike other
+// decoding errors, these are worth retrying
+var ErrRegistryUnavailable = errors.New("schema registry unavailable")
+
+// Schema is a schema as returned by a Confluent compatible schema registry
+type Schema struct {
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType"`
+	References []Reference `json:"references"`
+}
+
+// Reference is a schema imported by another schema, such as a Protobuf import
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Type returns the schema type, defaulting to Avro
+func (s *Schema) Type() string {
+	if s.SchemaType == "" {
+		return SchemaTypeAvro
+	}
+	return s.SchemaType
+}
+
+// Client looks up schemas from a Confluent compatible schema registry
+type Client struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewClient(options *Options) *Client {
+	return &Client{
+		url:      strings.TrimSuffix(options.URL, "/"),
+		username: options.Username,
+		password: options.Password,
+		client:   &http.Client{Timeout: time.Duration(options.TimeoutSeconds) * time.Second},
+	}
+}
+
+// SchemaByID returns the schema registered with the given ID
+func (c *Client) SchemaByID(id int) (*Schema, error) {
+	return c.get(fmt.Sprintf("/schemas/ids/%d", id))
+}
+
+// SchemaBySubjectVersion returns the schema registered for a version of a subject, used to resolve references
+func (c *Client) SchemaBySubjectVersion(subject string, version int) (*Schema, error) {
+	return c.get(fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(subject), version))
+}
+
+func (c *Client) get(path string) (*Schema, error) {
+	req, err := http.NewRequest(http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating schema registry request: %v", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrRegistryUnavailable, path, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrRegistryUnavailable, path, err)
+	}
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: %s: unexpected status %s", ErrRegistryUnavailable, path, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("schema registry lookup %s failed: %s: %s", path, resp.Status, body)
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(body, &schema); err != nil {
+		return nil, fmt.Errorf("error unmarshaling schema registry response for %s: %v", path, err)
+	}
+	return &schema, nil
+}
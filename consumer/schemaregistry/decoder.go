@@ -0,0 +1,230 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// magicByte starts every message serialized in the schema registry wire format
+	magicByte = 0x0
+	// headerSize is the size of the magic byte followed by the 4 byte schema ID
+	headerSize = 5
+	// protobufSchemaFile is the file name the Protobuf schema being decoded is compiled as
+	protobufSchemaFile = "schema.proto"
+)
+
+// codec decodes the serialized data following the wire format header into JSON
+type codec interface {
+	decode(data []byte) ([]byte, error)
+}
+
+// Decoder decodes message keys and values serialized by the Confluent Avro, Protobuf and JSON Schema serializers into
+// the JSON the parsers and transforms expect. Schemas are looked up by the ID in each message and cached, since a
+// registered schema never changes.
+type Decoder struct {
+	registry *Client
+
+	mu     sync.RWMutex
+	codecs map[int]codec
+}
+
+func NewDecoder(registry *Client) *Decoder {
+	return &Decoder{
+		registry: registry,
+		codecs:   make(map[int]codec),
+	}
+}
+
+// IsWireFormat returns true if the data starts with the schema registry wire format header
+func IsWireFormat(data []byte) bool {
+	return len(data) >= headerSize && data[0] == magicByte
+}
+
+// Decode returns the JSON encoding of data serialized in the schema registry wire format
+// Any other data, such as tombstones and JSON written by the JsonConverter, is returned as-is
+func (d *Decoder) Decode(data []byte) ([]byte, error) {
+	if !IsWireFormat(data) {
+		return data, nil
+	}
+	id := int(binary.BigEndian.Uint32(data[1:headerSize]))
+	c, err := d.codec(id)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := c.decode(data[headerSize:])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding message with schema ID %d: %w", id, err)
+	}
+	return decoded, nil
+}
+
+func (d *Decoder) codec(id int) (codec, error) {
+	d.mu.RLock()
+	c, ok := d.codecs[id]
+	d.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	schema, err := d.registry.SchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+	switch schema.Type() {
+	case SchemaTypeAvro:
+		c, err = newAvroCodec(schema)
+	case SchemaTypeProtobuf:
+		c, err = newProtobufCodec(schema, d.registry)
+	case SchemaTypeJSON:
+		c = jsonCodec{}
+	default:
+		err = fmt.Errorf("unsupported schema type %s", schema.SchemaType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading schema ID %d: %w", id, err)
+	}
+
+	d.mu.Lock()
+	d.codecs[id] = c
+	d.mu.Unlock()
+	return c, nil
+}
+
+// avroCodec decodes Avro binary data into standard JSON, where union values are not wrapped in their type name
+type avroCodec struct {
+	codec *goavro.Codec
+}
+
+func newAvroCodec(schema *Schema) (*avroCodec, error) {
+	if len(schema.References) > 0 {
+		return nil, fmt.Errorf("avro schema references are not supported")
+	}
+	c, err := goavro.NewCodecForStandardJSONFull(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	return &avroCodec{codec: c}, nil
+}
+
+func (a *avroCodec) decode(data []byte) ([]byte, error) {
+	native, _, err := a.codec.NativeFromBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return a.codec.TextualFromNative(nil, native)
+}
+
+// protobufCodec decodes Protobuf data into JSON using the field names of the schema
+type protobufCodec struct {
+	file protoreflect.FileDescriptor
+}
+
+func newProtobufCodec(schema *Schema, registry *Client) (*protobufCodec, error) {
+	sources := map[string]string{protobufSchemaFile: schema.Schema}
+	if err := resolveReferences(schema.References, registry, sources); err != nil {
+		return nil, err
+	}
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), protobufSchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Protobuf schema: %w", err)
+	}
+	return &protobufCodec{file: files[0]}, nil
+}
+
+// resolveReferences adds the source of each referenced schema, and the schemas they reference, by import name
+func resolveReferences(references []Reference, registry *Client, sources map[string]string) error {
+	for _, reference := range references {
+		if _, ok := sources[reference.Name]; ok {
+			continue
+		}
+		schema, err := registry.SchemaBySubjectVersion(reference.Subject, reference.Version)
+		if err != nil {
+			return fmt.Errorf("error resolving schema reference %s: %w", reference.Name, err)
+		}
+		sources[reference.Name] = schema.Schema
+		if err := resolveReferences(schema.References, registry, sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *protobufCodec) decode(data []byte) ([]byte, error) {
+	indexes, n, err := readMessageIndexes(data)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := p.messageDescriptor(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(data[n:], msg); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(msg)
+}
+
+// messageDescriptor returns the message type selected by the message indexes, which index the top level messages of
+// the schema followed by any nested messages
+func (p *protobufCodec) messageDescriptor(indexes []int) (protoreflect.MessageDescriptor, error) {
+	messages := p.file.Messages()
+	var descriptor protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index < 0 || index >= messages.Len() {
+			return nil, fmt.Errorf("message index %v not found in schema", indexes)
+		}
+		descriptor = messages.Get(index)
+		messages = descriptor.Messages()
+	}
+	return descriptor, nil
+}
+
+// readMessageIndexes reads the zig-zag varint encoded message indexes that precede Protobuf data, returning them and
+// the number of bytes read. A count of 0 is shorthand for the first message in the schema.
+func readMessageIndexes(data []byte) ([]int, int, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 {
+		return nil, 0, fmt.Errorf("invalid Protobuf message indexes")
+	}
+	if count == 0 {
+		return []int{0}, n, nil
+	}
+	indexes := make([]int, 0, count)
+	for range count {
+		index, read := binary.Varint(data[n:])
+		if read <= 0 {
+			return nil, 0, fmt.Errorf("invalid Protobuf message indexes")
+		}
+		indexes = append(indexes, int(index))
+		n += read
+	}
+	return indexes, n, nil
+}
+
+// jsonCodec passes through data serialized by the JSON Schema serializer, which is already JSON
+type jsonCodec struct{}
+
+func (jsonCodec) decode(data []byte) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	return data, nil
+}
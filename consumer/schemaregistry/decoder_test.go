@@ -0,0 +1,331 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	testHostID      = "00000000-0000-0000-0000-000000000000"
+	testWorkspaceID = "11111111-1111-1111-1111-111111111111"
+
+	testAvroSchema = `{
+		"type": "record",
+		"name": "Value",
+		"namespace": "hbi.hosts",
+		"fields": [
+			{"name": "id", "type": "string"},
+			{"name": "ansible_host", "type": ["null", "string"], "default": null},
+			{"name": "groups", "type": ["null", "string"], "default": null}
+		]
+	}`
+
+	testProtobufSchema = `syntax = "proto3";
+package hbi.hosts;
+
+message Key {
+  string id = 1;
+}
+
+message Value {
+  string id = 1;
+  string ansible_host = 2;
+  repeated Group groups = 3;
+
+  message Group {
+    string id = 1;
+  }
+}`
+
+	testProtobufReferenceSchema = `syntax = "proto3";
+package hbi.hosts;
+
+import "group.proto";
+
+message Value {
+  string id = 1;
+  hbi.groups.Group group = 2;
+}`
+
+	testProtobufGroupSchema = `syntax = "proto3";
+package hbi.groups;
+
+message Group {
+  string id = 1;
+}`
+)
+
+// fakeRegistry is an in-process schema registry serving the schemas registered in it
+type fakeRegistry struct {
+	*httptest.Server
+	schemas  map[string]Schema
+	requests atomic.Int32
+	status   int
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	registry := &fakeRegistry{schemas: make(map[string]Schema)}
+	registry.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry.requests.Add(1)
+		if registry.status != 0 {
+			w.WriteHeader(registry.status)
+			return
+		}
+		schema, ok := registry.schemas[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		_ = json.NewEncoder(w).Encode(schema)
+	}))
+	t.Cleanup(registry.Close)
+	return registry
+}
+
+func (f *fakeRegistry) register(id int, schema Schema) {
+	f.schemas[fmt.Sprintf("/schemas/ids/%d", id)] = schema
+}
+
+func (f *fakeRegistry) registerSubject(subject string, version int, schema Schema) {
+	f.schemas[fmt.Sprintf("/subjects/%s/versions/%d", subject, version)] = schema
+}
+
+func (f *fakeRegistry) decoder() *Decoder {
+	options := NewOptions()
+	options.URL = f.URL
+	return NewDecoder(NewClient(options))
+}
+
+// wireFormat prefixes data with the magic byte and schema ID
+func wireFormat(id int, data []byte) []byte {
+	header := []byte{magicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return append(header, data...)
+}
+
+func testAvroMessage(t *testing.T, native map[string]interface{}) []byte {
+	codec, err := goavro.NewCodec(testAvroSchema)
+	assert.Nil(t, err)
+	data, err := codec.BinaryFromNative(nil, native)
+	assert.Nil(t, err)
+	return data
+}
+
+func testProtobufValue(id, ansibleHost string, groupIDs ...string) []byte {
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendString(data, id)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendString(data, ansibleHost)
+	for _, groupID := range groupIDs {
+		var group []byte
+		group = protowire.AppendTag(group, 1, protowire.BytesType)
+		group = protowire.AppendString(group, groupID)
+		data = protowire.AppendTag(data, 3, protowire.BytesType)
+		data = protowire.AppendBytes(data, group)
+	}
+	return data
+}
+
+// messageIndexes encodes the Protobuf message indexes that precede the serialized message
+func messageIndexes(indexes ...int) []byte {
+	data := binary.AppendVarint(nil, int64(len(indexes)))
+	for _, index := range indexes {
+		data = binary.AppendVarint(data, int64(index))
+	}
+	return data
+}
+
+func TestDecoder_Avro(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.register(1, Schema{Schema: testAvroSchema})
+	decoder := registry.decoder()
+
+	msg := wireFormat(1, testAvroMessage(t, map[string]interface{}{
+		"id":           testHostID,
+		"ansible_host": nil,
+		"groups":       goavro.Union("string", `[{"id":"`+testWorkspaceID+`"}]`),
+	}))
+	decoded, err := decoder.Decode(msg)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"id":"`+testHostID+`","ansible_host":null,"groups":"[{\"id\":\"`+testWorkspaceID+`\"}]"}`, string(decoded))
+
+	// schemas are cached by ID
+	_, err = decoder.Decode(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), registry.requests.Load())
+}
+
+func TestDecoder_Protobuf(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.register(2, Schema{Schema: testProtobufSchema, SchemaType: SchemaTypeProtobuf})
+	decoder := registry.decoder()
+
+	tests := []struct {
+		name     string
+		msg      []byte
+		expected string
+	}{
+		{
+			name:     "first message shorthand",
+			msg:      wireFormat(2, append([]byte{0}, protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), testHostID)...)),
+			expected: `{"id":"` + testHostID + `"}`,
+		},
+		{
+			name:     "message selected by index",
+			msg:      wireFormat(2, append(messageIndexes(1), testProtobufValue(testHostID, "my-ansible-host", testWorkspaceID)...)),
+			expected: `{"id":"` + testHostID + `","ansible_host":"my-ansible-host","groups":[{"id":"` + testWorkspaceID + `"}]}`,
+		},
+		{
+			name:     "unset fields are included",
+			msg:      wireFormat(2, append(messageIndexes(1), protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), testHostID)...)),
+			expected: `{"id":"` + testHostID + `","ansible_host":"","groups":[]}`,
+		},
+		{
+			name:     "nested message selected by index",
+			msg:      wireFormat(2, append(messageIndexes(1, 0), protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), testWorkspaceID)...)),
+			expected: `{"id":"` + testWorkspaceID + `"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := decoder.Decode(test.msg)
+			assert.Nil(t, err)
+			assert.JSONEq(t, test.expected, string(decoded))
+		})
+	}
+}
+
+func TestDecoder_ProtobufReferences(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.register(3, Schema{
+		Schema:     testProtobufReferenceSchema,
+		SchemaType: SchemaTypeProtobuf,
+		References: []Reference{{Name: "group.proto", Subject: "hbi.groups-value", Version: 1}},
+	})
+	registry.registerSubject("hbi.groups-value", 1, Schema{Schema: testProtobufGroupSchema, SchemaType: SchemaTypeProtobuf})
+	decoder := registry.decoder()
+
+	group := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), testWorkspaceID)
+	value := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), testHostID)
+	value = protowire.AppendBytes(protowire.AppendTag(value, 2, protowire.BytesType), group)
+
+	decoded, err := decoder.Decode(wireFormat(3, append([]byte{0}, value...)))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"id":"`+testHostID+`","group":{"id":"`+testWorkspaceID+`"}}`, string(decoded))
+}
+
+func TestDecoder_JSONSchema(t *testing.T) {
+	registry := newFakeRegistry(t)
+	registry.register(4, Schema{Schema: `{"type":"object"}`, SchemaType: SchemaTypeJSON})
+	decoder := registry.decoder()
+
+	decoded, err := decoder.Decode(wireFormat(4, []byte(`{"id":"`+testHostID+`"}`)))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"id":"`+testHostID+`"}`, string(decoded))
+}
+
+func TestDecoder_NotWireFormat(t *testing.T) {
+	registry := newFakeRegistry(t)
+	decoder := registry.decoder()
+
+	for _, msg := range [][]byte{nil, {}, []byte(`{"payload":{"id":"` + testHostID + `"}}`), []byte(testHostID)} {
+		decoded, err := decoder.Decode(msg)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, decoded)
+	}
+	assert.Equal(t, int32(0), registry.requests.Load())
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	tests := []struct {
+		name              string
+		setup             func(registry *fakeRegistry)
+		msg               []byte
+		expectUnavailable bool
+		errorContains     string
+	}{
+		{
+			name:          "unknown schema ID",
+			setup:         func(registry *fakeRegistry) {},
+			msg:           wireFormat(9, []byte{}),
+			errorContains: "404",
+		},
+		{
+			name:              "registry errors are unavailable",
+			setup:             func(registry *fakeRegistry) { registry.status = http.StatusServiceUnavailable },
+			msg:               wireFormat(1, []byte{}),
+			expectUnavailable: true,
+		},
+		{
+			name:          "invalid Avro schema",
+			setup:         func(registry *fakeRegistry) { registry.register(1, Schema{Schema: `{"type":"unknown"}`}) },
+			msg:           wireFormat(1, []byte{}),
+			errorContains: "invalid Avro schema",
+		},
+		{
+			name:          "truncated Avro data",
+			setup:         func(registry *fakeRegistry) { registry.register(1, Schema{Schema: testAvroSchema}) },
+			msg:           wireFormat(1, []byte{0x02}),
+			errorContains: "error decoding message with schema ID 1",
+		},
+		{
+			name: "Protobuf message index out of range",
+			setup: func(registry *fakeRegistry) {
+				registry.register(2, Schema{Schema: testProtobufSchema, SchemaType: SchemaTypeProtobuf})
+			},
+			msg:           wireFormat(2, messageIndexes(5)),
+			errorContains: "not found in schema",
+		},
+		{
+			name: "unsupported schema type",
+			setup: func(registry *fakeRegistry) {
+				registry.register(1, Schema{Schema: `{}`, SchemaType: "XML"})
+			},
+			msg:           wireFormat(1, []byte{}),
+			errorContains: "unsupported schema type XML",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			registry := newFakeRegistry(t)
+			test.setup(registry)
+
+			_, err := registry.decoder().Decode(test.msg)
+			assert.NotNil(t, err)
+			assert.Equal(t, test.expectUnavailable, errors.Is(err, ErrRegistryUnavailable))
+			if test.errorContains != "" {
+				assert.True(t, strings.Contains(err.Error(), test.errorContains), err.Error())
+			}
+		})
+	}
+}
+
+func TestClient_BasicAuth(t *testing.T) {
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		_, _ = w.Write([]byte(`{"schema":"\"string\""}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&Options{URL: server.URL + "/", Username: "user", Password: "secret", TimeoutSeconds: 10})
+	schema, err := client.SchemaByID(1)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemaTypeAvro, schema.Type())
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+}
@@ -0,0 +1,45 @@
+package schemaregistry
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/pflag"
+)
+
+type Options struct {
+	URL            string `mapstructure:"url"`
+	Username       string `mapstructure:"username"`
+	Password       string `mapstructure:"password"`
+	TimeoutSeconds int    `mapstructure:"timeout-seconds"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		TimeoutSeconds: 10,
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
+	if prefix != "" {
+		prefix = prefix + "."
+	}
+	fs.StringVar(&o.URL, prefix+"url", o.URL, "URL of the schema registry used to decode Avro and Protobuf messages (default: \"\", disabled)")
+	fs.StringVar(&o.Username, prefix+"username", o.Username, "sets the username used for basic authentication with the schema registry")
+	fs.StringVar(&o.Password, prefix+"password", o.Password, "sets the password used for basic authentication with the schema registry")
+	fs.IntVar(&o.TimeoutSeconds, prefix+"timeout-seconds", o.TimeoutSeconds, "timeout for requests to the schema registry (default: 10)")
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+
+	if o.URL != "" {
+		if u, err := url.Parse(o.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("schema registry URL must be an absolute URL: url='%s'", o.URL))
+		}
+	}
+	if o.TimeoutSeconds < 1 {
+		errs = append(errs, fmt.Errorf("schema registry timeout seconds must be at least 1"))
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package schemaregistry
+
+import (
+	"testing"
+
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	expectedOptions := &Options{
+		TimeoutSeconds: 10,
+	}
+	assert.Equal(t, expectedOptions, NewOptions())
+}
+
+func TestOptions_AddFlags(t *testing.T) {
+	options := NewOptions()
+	prefix := "consumer.schema-registry"
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	options.AddFlags(fs, prefix)
+
+	common.AllOptionsHaveFlags(t, prefix, fs, *options, nil)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *Options
+		expectError bool
+	}{
+		{
+			name:        "default options are valid",
+			options:     NewOptions(),
+			expectError: false,
+		},
+		{
+			name:        "registry URL is valid",
+			options:     &Options{URL: "http://schema-registry:8081", TimeoutSeconds: 10},
+			expectError: false,
+		},
+		{
+			name:        "relative registry URL is invalid",
+			options:     &Options{URL: "schema-registry:8081/", TimeoutSeconds: 10},
+			expectError: true,
+		},
+		{
+			name:        "timeout must be positive",
+			options:     &Options{TimeoutSeconds: 0},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate()
+			if test.expectError {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
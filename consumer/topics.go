@@ -0,0 +1,167 @@
+package consumer
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/retry"
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
+)
+
+// TopicOptions configures how messages from a single topic are processed, so topics with different producers can be
+// consumed by one deployment. Unset fields use the consumer wide settings.
+type TopicOptions struct {
+	// MessageFormat overrides consumer.message-format for the topic
+	MessageFormat string `mapstructure:"message-format"`
+	// Operation and Version are used for messages without operation and version headers; the version defaults to
+	// v1beta2. With the migration message format, Version is the version of every message and Operation is unset
+	Operation string `mapstructure:"operation"`
+	Version   string `mapstructure:"version"`
+	// Transformer is the resource type of the transformer used for migration messages without a resource-type header
+	Transformer string `mapstructure:"transformer"`
+	// DeadLetterTopic overrides consumer.dead-letter-topic for the topic
+	DeadLetterTopic string `mapstructure:"dead-letter-topic"`
+	// RetryOptions overrides the request retries for the topic; unset fields use consumer.retry-options
+	RetryOptions *retry.Options `mapstructure:"retry-options"`
+}
+
+// Validate returns errors for invalid settings of the given topic
+func (o *TopicOptions) Validate(topic string) []error {
+	var errs []error
+
+	if !validMessageFormat(o.MessageFormat) {
+		errs = append(errs, fmt.Errorf("message format for topic %s must be %s, %s or %s: message-format='%s'", topic, MessageFormatHeaders, MessageFormatCloudEvents, MessageFormatMigration, o.MessageFormat))
+	}
+	if o.MessageFormat == MessageFormatMigration {
+		if o.Operation != "" {
+			errs = append(errs, fmt.Errorf("a default operation for topic %s can not be used with the %s message format", topic, MessageFormatMigration))
+		}
+	} else if o.Version != "" && o.Operation == "" {
+		errs = append(errs, fmt.Errorf("a default version for topic %s requires a default operation or the %s message format", topic, MessageFormatMigration))
+	}
+	if o.RetryOptions != nil {
+		if o.RetryOptions.OperationMaxRetries < -1 || o.RetryOptions.BackoffFactor < 0 || o.RetryOptions.MaxBackoffSeconds < 0 {
+			errs = append(errs, fmt.Errorf("retry options for topic %s can not be negative", topic))
+		}
+		for _, err := range o.RetryOptions.Validate() {
+			errs = append(errs, fmt.Errorf("invalid retry options for topic %s: %w", topic, err))
+		}
+	}
+	return errs
+}
+
+// defaultHeaders returns the operation and version used for messages from the topic without headers
+func (o *TopicOptions) defaultHeaders() EventHeaders {
+	if o == nil || o.Operation == "" {
+		return EventHeaders{}
+	}
+	headers := EventHeaders{Operation: o.Operation, Version: o.Version}
+	if headers.Version == "" {
+		headers.Version = APIVersionV1Beta2
+	}
+	return headers
+}
+
+// migrationHeaders returns the operation and version used for messages from a topic with the migration message format;
+// the version defaults to v1beta2
+func (o *TopicOptions) migrationHeaders() EventHeaders {
+	headers := EventHeaders{Operation: OperationTypeMigration, Version: APIVersionV1Beta2}
+	if o != nil && o.Version != "" {
+		headers.Version = o.Version
+	}
+	return headers
+}
+
+// validMessageFormat returns true for the supported message formats; an empty format uses headers, like the default
+func validMessageFormat(format string) bool {
+	return format == "" || format == MessageFormatHeaders || format == MessageFormatCloudEvents || format == MessageFormatMigration
+}
+
+// subscribedTopics returns the configured topics followed by any topics only configured with topic options
+func subscribedTopics(topics []string, topicOptions map[string]*TopicOptions) []string {
+	subscribed := slices.Clone(topics)
+	var configured []string
+	for topic := range topicOptions {
+		if !slices.Contains(subscribed, topic) {
+			configured = append(configured, topic)
+		}
+	}
+	sort.Strings(configured)
+	return append(subscribed, configured...)
+}
+
+// newTopicOptions returns a copy of the topic options with unset retry options taken from the consumer retry options,
+// and checks that each configured transformer is registered
+func newTopicOptions(topicOptions map[string]*TopicOptions, retryOptions *retry.Options, transformers *transforms.Registry) (map[string]*TopicOptions, error) {
+	completed := make(map[string]*TopicOptions, len(topicOptions))
+	for topic, options := range topicOptions {
+		if options == nil {
+			continue
+		}
+		if options.Transformer != "" {
+			if _, err := transformers.Lookup(options.Transformer, topic); err != nil {
+				return nil, fmt.Errorf("invalid transformer for topic %s: %w", topic, err)
+			}
+		}
+
+		topicOptions := *options
+		if options.RetryOptions != nil {
+			merged := *retryOptions
+			if options.RetryOptions.OperationMaxRetries != 0 {
+				merged.OperationMaxRetries = options.RetryOptions.OperationMaxRetries
+			}
+			if options.RetryOptions.BackoffFactor != 0 {
+				merged.BackoffFactor = options.RetryOptions.BackoffFactor
+			}
+			if options.RetryOptions.MaxBackoffSeconds != 0 {
+				merged.MaxBackoffSeconds = options.RetryOptions.MaxBackoffSeconds
+			}
+			if len(options.RetryOptions.RetryableCodes) > 0 {
+				merged.RetryableCodes = options.RetryOptions.RetryableCodes
+			}
+			if len(options.RetryOptions.TerminalCodes) > 0 {
+				merged.TerminalCodes = options.RetryOptions.TerminalCodes
+			}
+			if len(options.RetryOptions.Operations) > 0 {
+				merged.Operations = options.RetryOptions.Operations
+			}
+			topicOptions.RetryOptions = &merged
+		}
+		completed[topic] = &topicOptions
+	}
+	return completed, nil
+}
+
+// topicOptions returns the options configured for the topic of a message, or nil if there are none
+func (i *InventoryConsumer) topicOptions(msg *kafka.Message) *TopicOptions {
+	if msg.TopicPartition.Topic == nil {
+		return nil
+	}
+	return i.TopicOptions[*msg.TopicPartition.Topic]
+}
+
+// messageFormat returns the message format used for a message
+func (i *InventoryConsumer) messageFormat(msg *kafka.Message) string {
+	if options := i.topicOptions(msg); options != nil && options.MessageFormat != "" {
+		return options.MessageFormat
+	}
+	return i.MessageFormat
+}
+
+// retryOptions returns the request retry options used for a message
+func (i *InventoryConsumer) retryOptions(msg *kafka.Message) *retry.Options {
+	if options := i.topicOptions(msg); options != nil && options.RetryOptions != nil {
+		return options.RetryOptions
+	}
+	return i.RetryOptions
+}
+
+// deadLetterTopic returns the topic unprocessable messages are produced to, or an empty string if there is none
+func (i *InventoryConsumer) deadLetterTopic(msg *kafka.Message) string {
+	if options := i.topicOptions(msg); options != nil && options.DeadLetterTopic != "" {
+		return options.DeadLetterTopic
+	}
+	return i.Config.DeadLetterTopic
+}
@@ -0,0 +1,318 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/project-kessel/inventory-consumer/consumer/retry"
+	"github.com/project-kessel/inventory-consumer/consumer/transforms"
+	"github.com/project-kessel/inventory-consumer/consumer/types"
+	"github.com/project-kessel/inventory-consumer/internal/mocks"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testOutboxTopic    = "outbox.event.hbi.hosts"
+	testMigrationTopic = "host-inventory.hbi.hosts"
+	// testMigrationFormatTopic is a migration topic configured with the migration message format
+	testMigrationFormatTopic = "host-inventory.hbi.hosts.snapshot"
+)
+
+func TestTopicOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *TopicOptions
+		expectError bool
+	}{
+		{
+			name:        "empty topic options are valid",
+			options:     &TopicOptions{},
+			expectError: false,
+		},
+		{
+			name: "migration topic options are valid",
+			options: &TopicOptions{
+				MessageFormat:   MessageFormatHeaders,
+				Operation:       OperationTypeMigration,
+				Transformer:     types.HostResourceType,
+				DeadLetterTopic: testMigrationTopic + ".dlq",
+				RetryOptions:    &retry.Options{OperationMaxRetries: 5},
+			},
+			expectError: false,
+		},
+		{
+			name: "migration message format topic options are valid",
+			options: &TopicOptions{
+				MessageFormat: MessageFormatMigration,
+				Version:       APIVersionV1Beta2,
+				Transformer:   types.HostResourceType,
+			},
+			expectError: false,
+		},
+		{
+			name:        "migration message format can not be used with a default operation",
+			options:     &TopicOptions{MessageFormat: MessageFormatMigration, Operation: OperationTypeReportResource},
+			expectError: true,
+		},
+		{
+			name:        "message format is not supported",
+			options:     &TopicOptions{MessageFormat: "avro"},
+			expectError: true,
+		},
+		{
+			name:        "default version requires a default operation",
+			options:     &TopicOptions{Version: APIVersionV1Beta2},
+			expectError: true,
+		},
+		{
+			name:        "retry options can not be negative",
+			options:     &TopicOptions{RetryOptions: &retry.Options{BackoffFactor: -1}},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate("test-topic")
+			if test.expectError {
+				assert.NotEmpty(t, errs)
+			} else {
+				assert.Empty(t, errs)
+			}
+		})
+	}
+}
+
+func TestInventoryConsumer_ParseMessageMigrationFormat(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	tester.inv.TopicOptions = map[string]*TopicOptions{
+		testMigrationTopic: {MessageFormat: MessageFormatMigration},
+	}
+
+	// messages from the topic are migration messages, whatever their operation header
+	topic := testMigrationTopic
+	headers, _, err := tester.inv.parseMessage(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Headers:        []kafka.Header{{Key: "operation", Value: []byte(OperationTypeReportResource)}},
+		Value:          []byte(testMigrationMessage),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, EventHeaders{Operation: OperationTypeMigration, Version: APIVersionV1Beta2}, headers)
+
+	// other topics still read their headers
+	topic = testOutboxTopic
+	_, _, err = tester.inv.parseMessage(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Value:          []byte(testMigrationMessage),
+	})
+	var failure *UnprocessableError
+	assert.ErrorAs(t, err, &failure)
+}
+
+func TestOptions_ValidateTopicOptions(t *testing.T) {
+	options := &Options{
+		Enabled:             true,
+		BootstrapServers:    []string{"test-server:9092"},
+		WorkersPerPartition: 1,
+		CommitCount:         10,
+		TopicOptions: map[string]*TopicOptions{
+			testMigrationTopic: {Operation: OperationTypeMigration},
+		},
+	}
+	assert.Empty(t, options.Validate())
+
+	options.TopicOptions[testOutboxTopic] = &TopicOptions{MessageFormat: "avro"}
+	assert.NotEmpty(t, options.Validate())
+}
+
+func TestSubscribedTopics(t *testing.T) {
+	topicOptions := map[string]*TopicOptions{
+		testOutboxTopic:    {},
+		testMigrationTopic: {Operation: OperationTypeMigration},
+		"another-topic":    {},
+	}
+	assert.Equal(t, []string{testOutboxTopic, "another-topic", testMigrationTopic}, subscribedTopics([]string{testOutboxTopic}, topicOptions))
+	assert.Equal(t, []string{testOutboxTopic}, subscribedTopics([]string{testOutboxTopic}, nil))
+}
+
+func TestConfig_CompleteWithTopicDeadLetterTopic(t *testing.T) {
+	o := NewOptions()
+	o.BootstrapServers = []string{"localhost:9092"}
+	o.TopicOptions = map[string]*TopicOptions{
+		testMigrationTopic: {Operation: OperationTypeMigration, DeadLetterTopic: testMigrationTopic + ".dlq"},
+	}
+	completed, errs := NewConfig(o).Complete()
+	assert.Nil(t, errs)
+	assert.Equal(t, []string{testMigrationTopic}, completed.Topics)
+	assert.NotNil(t, completed.ProducerConfig)
+}
+
+func TestNewTopicOptions(t *testing.T) {
+	globalRetryOptions := retry.NewOptions()
+	topicOptions, err := newTopicOptions(map[string]*TopicOptions{
+		testMigrationTopic: {
+			Operation:    OperationTypeMigration,
+			Transformer:  types.HostResourceType,
+			RetryOptions: &retry.Options{OperationMaxRetries: 10},
+		},
+		testOutboxTopic: {},
+	}, globalRetryOptions, transforms.NewDefaultRegistry())
+	assert.Nil(t, err)
+
+	// unset retry options are taken from the consumer retry options
+	assert.Equal(t, &retry.Options{
+		ConsumerMaxRetries:  globalRetryOptions.ConsumerMaxRetries,
+		OperationMaxRetries: 10,
+		BackoffFactor:       globalRetryOptions.BackoffFactor,
+		MaxBackoffSeconds:   globalRetryOptions.MaxBackoffSeconds,
+		RetryableCodes:      globalRetryOptions.RetryableCodes,
+		TerminalCodes:       globalRetryOptions.TerminalCodes,
+	}, topicOptions[testMigrationTopic].RetryOptions)
+	assert.Nil(t, topicOptions[testOutboxTopic].RetryOptions)
+
+	_, err = newTopicOptions(map[string]*TopicOptions{
+		testMigrationTopic: {Transformer: "unknown"},
+	}, globalRetryOptions, transforms.NewDefaultRegistry())
+	assert.ErrorContains(t, err, "invalid transformer for topic "+testMigrationTopic)
+}
+
+func TestInventoryConsumer_TopicRouting(t *testing.T) {
+	tests := []struct {
+		name               string
+		topic              string
+		msg                *kafka.Message
+		setupMock          func(client *mocks.MockClient)
+		expectDeadLetterTo string
+	}{
+		{
+			name:  "messages without headers use the default operation for the topic",
+			topic: testMigrationTopic,
+			msg: &kafka.Message{
+				Key:   []byte(testMigrationKey),
+				Value: []byte(testMigrationMessage),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:  "messages from a topic with the migration message format are migration messages",
+			topic: testMigrationFormatTopic,
+			msg: &kafka.Message{
+				Key:   []byte(testMigrationKey),
+				Value: []byte(testMigrationMessage),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:  "messages with headers on a topic with a default operation use their headers",
+			topic: testMigrationTopic,
+			msg: &kafka.Message{
+				Headers: []kafka.Header{
+					{Key: "operation", Value: []byte(OperationTypeReportResource)},
+					{Key: "version", Value: []byte(APIVersionV1Beta2)},
+				},
+				Value: []byte(testCreateOrUpdateMessage),
+			},
+			setupMock: func(client *mocks.MockClient) {
+				client.On("IsEnabled").Return(true)
+				client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, nil)
+			},
+		},
+		{
+			name:               "unprocessable messages are dead-lettered to the topic dead-letter topic",
+			topic:              testMigrationTopic,
+			msg:                &kafka.Message{Value: []byte(`{"payload":{"op":"x","before":null,"after":{}}}`)},
+			setupMock:          func(client *mocks.MockClient) { client.On("IsEnabled").Return(true) },
+			expectDeadLetterTo: testMigrationTopic + ".dlq",
+		},
+		{
+			name:               "topics without options use the consumer settings",
+			topic:              testOutboxTopic,
+			msg:                &kafka.Message{Value: []byte(testCreateOrUpdateMessage)},
+			setupMock:          func(client *mocks.MockClient) {},
+			expectDeadLetterTo: "test-topic.dlq",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tester := TestCase{}
+			errs := tester.TestSetup()
+			assert.Nil(t, errs)
+
+			client := &mocks.MockClient{}
+			test.setupMock(client)
+			tester.inv.Client = client
+
+			topicOptions, err := newTopicOptions(map[string]*TopicOptions{
+				testMigrationTopic: {
+					Operation:       OperationTypeMigration,
+					Transformer:     types.HostResourceType,
+					DeadLetterTopic: testMigrationTopic + ".dlq",
+				},
+				testMigrationFormatTopic: {
+					MessageFormat: MessageFormatMigration,
+					Transformer:   types.HostResourceType,
+				},
+			}, tester.inv.RetryOptions, tester.inv.Transformers)
+			assert.Nil(t, err)
+			tester.inv.TopicOptions = topicOptions
+			tester.inv.Config.DeadLetterTopic = "test-topic.dlq"
+
+			producer := &mocks.MockProducer{}
+			var produced *kafka.Message
+			if test.expectDeadLetterTo != "" {
+				producer.On("Produce", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+					produced = args.Get(0).(*kafka.Message)
+				}).Return(nil)
+			}
+			tester.inv.Producer = producer
+
+			topic := test.topic
+			test.msg.TopicPartition = kafka.TopicPartition{Topic: &topic}
+			err = tester.inv.processPartitionMessage(context.Background(), test.msg)
+			assert.Nil(t, err)
+
+			client.AssertExpectations(t)
+			producer.AssertExpectations(t)
+			if test.expectDeadLetterTo != "" {
+				assert.Equal(t, test.expectDeadLetterTo, *produced.TopicPartition.Topic)
+			}
+		})
+	}
+}
+
+func TestInventoryConsumer_TopicRetryOptions(t *testing.T) {
+	tester := TestCase{}
+	errs := tester.TestSetup()
+	assert.Nil(t, errs)
+
+	client := &mocks.MockClient{}
+	client.On("IsEnabled").Return(true)
+	client.On("CreateOrUpdateResource", mock.Anything, mock.Anything).Return(&v1beta2.ReportResourceResponse{}, errors.New("unavailable"))
+	tester.inv.Client = client
+
+	topicOptions, err := newTopicOptions(map[string]*TopicOptions{
+		testOutboxTopic: {RetryOptions: &retry.Options{OperationMaxRetries: 1}},
+	}, tester.inv.RetryOptions, tester.inv.Transformers)
+	assert.Nil(t, err)
+	tester.inv.TopicOptions = topicOptions
+
+	topic := testOutboxTopic
+	err = tester.inv.ProcessMessage(context.Background(), EventHeaders{Operation: OperationTypeReportResource, Version: APIVersionV1Beta2}, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Value:          []byte(testCreateOrUpdateMessage),
+	})
+	assert.ErrorIs(t, err, ErrMaxRetries)
+	client.AssertNumberOfCalls(t, "CreateOrUpdateResource", 1)
+}
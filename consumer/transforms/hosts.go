@@ -1,84 +1,221 @@
 package transforms
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"text/template"
 
 	"github.com/project-kessel/inventory-consumer/consumer/types"
 	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
 )
 
+// HostTransformer transforms HBI hosts, reporting them with the configured reporter identity and links
+type HostTransformer struct {
+	reporterInstanceID   string
+	reporterVersion      string
+	apiHref              *template.Template
+	consoleHref          *template.Template
+	ungroupedWorkspaceID string
+	multipleGroups       string
+	workspaces           WorkspaceResolver
+	mapping              *MappingTransformer
+}
+
+var (
+	// ErrHostHasNoGroups is returned for hosts without a group when no ungrouped workspace is configured
+	ErrHostHasNoGroups = errors.New("host has no groups and no ungrouped workspace is configured")
+	// ErrHostHasMultipleGroups is returned for hosts in more than one group when multiple groups are rejected
+	ErrHostHasMultipleGroups = errors.New("host has multiple groups")
+)
+
+// DefaultHostTransformer transforms hosts using the default HostOptions
+var DefaultHostTransformer = mustNewHostTransformer(NewHostOptions())
+
+// DefaultHostMapping returns the mapping hosts are reported with unless a mapping for the host resource type is
+// configured. Besides the payload of the message, sources can use the values the HostTransformer derives from its
+// options under host: reporter_instance_id, reporter_version, api_href, console_href and workspace_id.
+func DefaultHostMapping() MappingSpec {
+	return MappingSpec{
+		ResourceType: types.HostResourceType,
+		ReporterType: types.HostReporterType,
+		Fields: []FieldMapping{
+			{Target: "type", Default: types.HostResourceType},
+			{Target: "reporter_type", Default: types.HostReporterType},
+			{Target: "reporter_instance_id", Source: "host.reporter_instance_id"},
+			{Target: "representations.metadata.local_resource_id", Source: "payload.id", Default: ""},
+			{Target: "representations.metadata.api_href", Source: "host.api_href"},
+			{Target: "representations.metadata.console_href", Source: "host.console_href"},
+			{Target: "representations.metadata.reporter_version", Source: "host.reporter_version"},
+			{Target: "representations.reporter.satellite_id", Source: "payload.satellite_id", Default: ""},
+			{Target: "representations.reporter.sub_manager_id", Source: "payload.subscription_manager_id", Default: ""},
+			{Target: "representations.reporter.insights_inventory_id", Source: "payload.insights_id", Default: ""},
+			{Target: "representations.reporter.ansible_host", Source: "payload.ansible_host", Default: ""},
+			{Target: "representations.common.workspace_id", Source: "host.workspace_id"},
+		},
+	}
+}
+
+// NewHostTransformer returns a HostTransformer for the given options, parsing the href templates
+func NewHostTransformer(options *HostOptions) (*HostTransformer, error) {
+	if options == nil {
+		options = NewHostOptions()
+	}
+	apiHref, err := template.New("api-href").Option("missingkey=error").Parse(options.APIHref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host API href template: %w", err)
+	}
+	consoleHref, err := template.New("console-href").Option("missingkey=error").Parse(options.ConsoleHref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host console href template: %w", err)
+	}
+	workspaces, err := NewWorkspaceResolver(options)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := NewMappingTransformer(DefaultHostMapping())
+	if err != nil {
+		return nil, err
+	}
+	return &HostTransformer{
+		reporterInstanceID:   options.ReporterInstanceID,
+		reporterVersion:      options.ReporterVersion,
+		apiHref:              apiHref,
+		consoleHref:          consoleHref,
+		ungroupedWorkspaceID: options.UngroupedWorkspaceID,
+		multipleGroups:       options.MultipleGroups,
+		workspaces:           workspaces,
+		mapping:              mapping,
+	}, nil
+}
+
+func mustNewHostTransformer(options *HostOptions) *HostTransformer {
+	transformer, err := NewHostTransformer(options)
+	if err != nil {
+		panic(err)
+	}
+	return transformer
+}
+
+func (h *HostTransformer) IsDeleted(msgValue []byte) (bool, error) {
+	return IsHostDeleted(msgValue)
+}
+
+func (h *HostTransformer) ToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error) {
+	return h.mapping.ToDeleteResourceRequest(msgValue, msgKey)
+}
+
 // TransformHostToReportResourceRequest transforms a Debezium message into a kesselv2.ReportResourceRequest
+// using the default HostOptions
 func TransformHostToReportResourceRequest(msg []byte) (*v1beta2.ReportResourceRequest, error) {
+	return DefaultHostTransformer.ToReportResourceRequest(context.Background(), msg)
+}
+
+// ToReportResourceRequest transforms a Debezium message into a kesselv2.ReportResourceRequest
+func (h *HostTransformer) ToReportResourceRequest(ctx context.Context, msg []byte) (*v1beta2.ReportResourceRequest, error) {
 	var hostMsg types.HostMessage
-	err := json.Unmarshal(msg, &hostMsg)
+	var source interface{}
+	payload, err := Payload(msg)
+	if err == nil {
+		err = json.Unmarshal(payload, &hostMsg.Payload)
+	}
+	if err == nil {
+		err = json.Unmarshal(payload, &source)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshaling Debezium message: %v", err)
 	}
 
-	// Create a simplified structure that matches the expected format
-	// First convert to the intermediate JSON structure
-	intermediatePayload := map[string]interface{}{
-		"type":                 types.HostResourceType,
-		"reporter_type":        types.HostReporterType,
-		"reporter_instance_id": types.HostReporterInstanceID,
-		"representations": map[string]interface{}{
-			"metadata": map[string]interface{}{
-				"local_resource_id": hostMsg.Payload.ID,
-				"api_href":          types.HostAPIHref,
-				"console_href":      types.HostConsoleHref,
-				"reporter_version":  types.HostReporterVersion,
-			},
-			"reporter": map[string]interface{}{
-				"satellite_id":          hostMsg.Payload.SatelliteID,
-				"sub_manager_id":        hostMsg.Payload.SubscriptionManagerID,
-				"insights_inventory_id": hostMsg.Payload.InsightsID,
-				"ansible_host":          hostMsg.Payload.AnsibleHost,
-			},
-			"common": map[string]interface{}{
-				"workspace_id": hostMsg.Payload.Groups[0].ID,
-			},
-		},
+	apiHref, err := executeHref(h.apiHref, hostMsg.Payload)
+	if err != nil {
+		return nil, err
 	}
-
-	// Marshal and unmarshal to convert to the expected type
-	payloadBytes, err := json.Marshal(intermediatePayload)
+	consoleHref, err := executeHref(h.consoleHref, hostMsg.Payload)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling intermediate payload: %v", err)
+		return nil, err
 	}
-
-	var request v1beta2.ReportResourceRequest
-	err = json.Unmarshal(payloadBytes, &request)
+	workspaceID, err := h.workspaceID(ctx, hostMsg.Payload)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling to ReportResourceRequest: %v", err)
+		return nil, err
 	}
 
-	return &request, nil
+	return h.mapping.toReportResourceRequest(map[string]interface{}{
+		"payload": source,
+		"host": map[string]interface{}{
+			"reporter_instance_id": h.reporterInstanceID,
+			"reporter_version":     h.reporterVersion,
+			"api_href":             apiHref,
+			"console_href":         consoleHref,
+			"workspace_id":         workspaceID,
+		},
+	})
 }
 
-// TransformHostToDeleteResourceRequest transforms a tombstone message into a kesselv2.DeleteResourceRequest
-// Extracts the resource ID from the message key since tombstones have empty values
-func TransformHostToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error) {
-	// Extract ID from the key
-	if len(msgKey) == 0 {
-		return nil, fmt.Errorf("tombstone message has no key to extract resource ID")
+// WithMapping returns a copy of the HostTransformer that reports hosts with the given mapping instead of
+// DefaultHostMapping
+func (h *HostTransformer) WithMapping(spec MappingSpec) (Transformer, error) {
+	mapping, err := NewMappingTransformer(spec)
+	if err != nil {
+		return nil, err
 	}
+	transformer := *h
+	transformer.mapping = mapping
+	return &transformer, nil
+}
 
-	var keyPayload struct {
-		Payload struct {
-			ID string `json:"id"`
-		} `json:"payload"`
+// WithWorkspaceResolver returns a copy of the HostTransformer that resolves the default workspace of hosts without a
+// group using the given resolver
+func (h *HostTransformer) WithWorkspaceResolver(resolver WorkspaceResolver) *HostTransformer {
+	transformer := *h
+	transformer.workspaces = resolver
+	return &transformer
+}
+
+// workspaceID returns the workspace a host is reported in
+// Hosts without a group use their org's default workspace when a resolver is configured, then the ungrouped workspace.
+// Hosts in multiple groups use the first group unless multiple groups are rejected
+func (h *HostTransformer) workspaceID(ctx context.Context, host types.HostPayload) (string, error) {
+	switch {
+	case len(host.Groups) == 0:
+		if h.workspaces != nil && host.OrgID != "" {
+			workspaceID, err := h.workspaces.DefaultWorkspace(ctx, host.OrgID)
+			if err == nil {
+				return workspaceID, nil
+			}
+			if !errors.Is(err, ErrWorkspaceNotFound) {
+				return "", fmt.Errorf("failed to resolve default workspace for host %s: %w", host.ID, err)
+			}
+		}
+		if h.ungroupedWorkspaceID == "" {
+			return "", fmt.Errorf("%w: host=%s", ErrHostHasNoGroups, host.ID)
+		}
+		return h.ungroupedWorkspaceID, nil
+	case len(host.Groups) > 1 && h.multipleGroups == MultipleGroupsReject:
+		return "", fmt.Errorf("%w: host=%s groups=%d", ErrHostHasMultipleGroups, host.ID, len(host.Groups))
 	}
+	if host.Groups[0].ID == "" {
+		return "", fmt.Errorf("host %s group has no ID", host.ID)
+	}
+	return host.Groups[0].ID, nil
+}
 
-	err := json.Unmarshal(msgKey, &keyPayload)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling message key for tombstone: %v", err)
+// executeHref renders an href template for a host
+func executeHref(href *template.Template, host types.HostPayload) (string, error) {
+	var rendered strings.Builder
+	if err := href.Execute(&rendered, host); err != nil {
+		return "", fmt.Errorf("error rendering %s for host %s: %v", href.Name(), host.ID, err)
 	}
+	return rendered.String(), nil
+}
 
-	resourceID := keyPayload.Payload.ID
-	if resourceID == "" {
-		return nil, fmt.Errorf("cannot extract resource ID from tombstone message key")
+// TransformHostToDeleteResourceRequest transforms a tombstone message into a kesselv2.DeleteResourceRequest
+// Extracts the resource ID from the message key since tombstones have empty values
+func TransformHostToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error) {
+	resourceID, err := ResourceIDFromKey(msgKey)
+	if err != nil {
+		return nil, err
 	}
 
 	return &v1beta2.DeleteResourceRequest{
@@ -92,6 +229,22 @@ func TransformHostToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1be
 	}, nil
 }
 
+// ResourceIDFromKey extracts the resource ID from a Debezium message key
+func ResourceIDFromKey(msgKey []byte) (string, error) {
+	if len(msgKey) == 0 {
+		return "", fmt.Errorf("tombstone message has no key to extract resource ID")
+	}
+
+	resourceID, err := KeyID(msgKey)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshaling message key for tombstone: %v", err)
+	}
+	if resourceID == "" {
+		return "", fmt.Errorf("cannot extract resource ID from tombstone message key")
+	}
+	return resourceID, nil
+}
+
 // IsHostDeleted checks if a Debezium message is a tombstone event (indicating deletion)
 func IsHostDeleted(msgValue []byte) (bool, error) {
 	// Tombstone events have empty/null values
@@ -1,6 +1,7 @@
 package transforms
 
 import (
+	"context"
 	"testing"
 
 	"github.com/project-kessel/inventory-consumer/consumer/types"
@@ -40,7 +41,7 @@ const (
 	errUnmarshalingKey      = "error unmarshaling message key for tombstone"
 	errNoKeyForTombstone    = "tombstone message has no key to extract resource ID"
 	errNoResourceID         = "cannot extract resource ID from tombstone message key"
-	errIndexOutOfRange      = "runtime error: index out of range"
+	errNoGroups             = "host has no groups"
 
 	// Test messages
 	testHostMessageValid = `{
@@ -143,6 +144,16 @@ func TestTransformHostToReportResourceRequest(t *testing.T) {
 				assert.Equal(t, testWorkspaceID1, commonMap["workspace_id"])
 			},
 		},
+		{
+			name:        "schemaless host message transforms correctly",
+			message:     []byte(`{"id": "` + testHostID1 + `", "ansible_host": "` + testAnsibleHost1 + `", "groups": [{"id": "` + testWorkspaceID1 + `"}]}`),
+			expectError: false,
+			validate: func(t *testing.T, req *v1beta2.ReportResourceRequest) {
+				assert.Equal(t, testHostID1, req.Representations.Metadata.LocalResourceId)
+				assert.Equal(t, testAnsibleHost1, req.Representations.Reporter.AsMap()["ansible_host"])
+				assert.Equal(t, testWorkspaceID1, req.Representations.Common.AsMap()["workspace_id"])
+			},
+		},
 		{
 			name:        "host message with multiple groups uses first group",
 			message:     []byte(testHostMessageMultipleGroups),
@@ -159,16 +170,16 @@ func TestTransformHostToReportResourceRequest(t *testing.T) {
 			errorContains: errUnmarshalingDebezium,
 		},
 		{
-			name:          "empty payload transforms but may have issues",
+			name:          "empty payload returns error",
 			message:       []byte(testHostMessageEmptyPayload),
 			expectError:   true,
-			errorContains: errIndexOutOfRange,
+			errorContains: errNoGroups,
 		},
 		{
-			name:          "no groups causes panic",
+			name:          "no groups returns error",
 			message:       []byte(testHostMessageNoGroups),
 			expectError:   true,
-			errorContains: errIndexOutOfRange,
+			errorContains: errNoGroups,
 		},
 		{
 			name:          "nil message returns error",
@@ -186,21 +197,9 @@ func TestTransformHostToReportResourceRequest(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			// Use defer to catch panics for tests that expect them
-			if test.errorContains == errIndexOutOfRange {
-				defer func() {
-					if r := recover(); r != nil {
-						// Expected panic
-						assert.Contains(t, r.(error).Error(), test.errorContains)
-					} else {
-						t.Errorf("expected panic but none occurred")
-					}
-				}()
-			}
-
 			req, err := TransformHostToReportResourceRequest(test.message)
 
-			if test.expectError && test.errorContains != errIndexOutOfRange {
+			if test.expectError {
 				assert.NotNil(t, err)
 				assert.Contains(t, err.Error(), test.errorContains)
 				assert.Nil(t, req)
@@ -237,6 +236,15 @@ func TestTransformHostToDeleteResourceRequest(t *testing.T) {
 				assert.Equal(t, types.HostReporterType, req.Reference.Reporter.Type)
 			},
 		},
+		{
+			name:        "plain string key transforms correctly",
+			msgValue:    []byte{},
+			msgKey:      []byte(testDeletedHostID),
+			expectError: false,
+			validate: func(t *testing.T, req *v1beta2.DeleteResourceRequest) {
+				assert.Equal(t, testDeletedHostID, req.Reference.ResourceId)
+			},
+		},
 		{
 			name:          "empty key returns error",
 			msgValue:      []byte{},
@@ -396,3 +404,125 @@ func TestIsEmptyJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestHostTransformer_ConfiguredReporterAndHrefs(t *testing.T) {
+	transformer, err := NewHostTransformer(&HostOptions{
+		ReporterInstanceID: "console.redhat.com",
+		ReporterVersion:    "2.0",
+		APIHref:            "https://console.redhat.com/api/inventory/v1/hosts/{{.ID}}",
+		ConsoleHref:        "https://console.redhat.com/insights/inventory/{{.ID}}",
+	})
+	assert.Nil(t, err)
+
+	req, err := transformer.ToReportResourceRequest(context.Background(), []byte(testHostMessageValid))
+	assert.Nil(t, err)
+	assert.Equal(t, "console.redhat.com", req.ReporterInstanceId)
+	assert.Equal(t, "2.0", *req.Representations.Metadata.ReporterVersion)
+	assert.Equal(t, "https://console.redhat.com/api/inventory/v1/hosts/"+testHostID1, req.Representations.Metadata.ApiHref)
+	assert.Equal(t, "https://console.redhat.com/insights/inventory/"+testHostID1, *req.Representations.Metadata.ConsoleHref)
+
+	_, err = NewHostTransformer(&HostOptions{APIHref: "{{.ID"})
+	assert.NotNil(t, err)
+
+	// unknown template fields fail the transform rather than reporting a broken link
+	transformer, err = NewHostTransformer(&HostOptions{ReporterInstanceID: "console.redhat.com", APIHref: "https://console.redhat.com/{{.Unknown}}"})
+	assert.Nil(t, err)
+	_, err = transformer.ToReportResourceRequest(context.Background(), []byte(testHostMessageValid))
+	assert.NotNil(t, err)
+}
+
+func TestHostTransformer_WithMapping(t *testing.T) {
+	spec := DefaultHostMapping()
+	spec.ReporterType = "hbi-reporter"
+	for i, field := range spec.Fields {
+		if field.Target == "reporter_type" {
+			spec.Fields[i].Default = "hbi-reporter"
+		}
+	}
+	spec.Fields = append(spec.Fields, FieldMapping{Target: "representations.reporter.org_id", Source: "payload.org_id", Required: true})
+	transformer, err := DefaultHostTransformer.WithMapping(spec)
+	assert.Nil(t, err)
+
+	req, err := transformer.ToReportResourceRequest(context.Background(), []byte(`{"payload": {"id": "`+testHostID1+`", "org_id": "12345", "groups": [{"id": "`+testWorkspaceID1+`"}]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "hbi-reporter", req.ReporterType)
+	assert.Equal(t, "12345", req.Representations.Reporter.AsMap()["org_id"])
+	// values derived from the host options are still reported
+	assert.Equal(t, types.HostReporterInstanceID, req.ReporterInstanceId)
+	assert.Equal(t, types.HostAPIHref, req.Representations.Metadata.ApiHref)
+	assert.Equal(t, testWorkspaceID1, req.Representations.Common.AsMap()["workspace_id"])
+
+	deleteReq, err := transformer.ToDeleteResourceRequest(nil, []byte(testTombstoneKey))
+	assert.Nil(t, err)
+	assert.Equal(t, "hbi-reporter", deleteReq.Reference.Reporter.Type)
+
+	_, err = DefaultHostTransformer.WithMapping(MappingSpec{ResourceType: types.HostResourceType})
+	assert.NotNil(t, err)
+}
+
+func TestHostTransformer_Groups(t *testing.T) {
+	tests := []struct {
+		name              string
+		ungroupedID       string
+		multipleGroups    string
+		message           string
+		expectedWorkspace string
+		expectedErr       error
+	}{
+		{
+			name:              "single group uses its workspace",
+			multipleGroups:    MultipleGroupsReject,
+			message:           testHostMessageValid,
+			expectedWorkspace: testWorkspaceID1,
+		},
+		{
+			name:        "no groups without an ungrouped workspace is rejected",
+			message:     testHostMessageNoGroups,
+			expectedErr: ErrHostHasNoGroups,
+		},
+		{
+			name:              "no groups falls back to the ungrouped workspace",
+			ungroupedID:       testWorkspaceID3,
+			message:           testHostMessageNoGroups,
+			expectedWorkspace: testWorkspaceID3,
+		},
+		{
+			name:              "missing groups falls back to the ungrouped workspace",
+			ungroupedID:       testWorkspaceID3,
+			message:           `{"payload": {"id": "` + testHostID3 + `"}}`,
+			expectedWorkspace: testWorkspaceID3,
+		},
+		{
+			name:              "multiple groups use the first group by default",
+			multipleGroups:    MultipleGroupsFirst,
+			message:           testHostMessageMultipleGroups,
+			expectedWorkspace: testWorkspaceID2,
+		},
+		{
+			name:           "multiple groups are rejected when configured",
+			multipleGroups: MultipleGroupsReject,
+			message:        testHostMessageMultipleGroups,
+			expectedErr:    ErrHostHasMultipleGroups,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := NewHostOptions()
+			options.UngroupedWorkspaceID = test.ungroupedID
+			if test.multipleGroups != "" {
+				options.MultipleGroups = test.multipleGroups
+			}
+			transformer, err := NewHostTransformer(options)
+			assert.Nil(t, err)
+
+			req, err := transformer.ToReportResourceRequest(context.Background(), []byte(test.message))
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Nil(t, req)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedWorkspace, req.Representations.Common.AsMap()["workspace_id"])
+		})
+	}
+}
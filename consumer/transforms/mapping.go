@@ -0,0 +1,232 @@
+package transforms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+)
+
+// MappingSpec declares how the Debezium change events for a resource type are transformed into Inventory API
+// requests, so new resource types and reporter fields can be added through configuration
+type MappingSpec struct {
+	// ResourceType is the resource-type header value the mapping is registered for, and the type of deleted resources
+	ResourceType string `mapstructure:"resource-type"`
+	// ReporterType is the reporter type of deleted resources
+	ReporterType string `mapstructure:"reporter-type"`
+	// Topic optionally registers the mapping for messages from a topic that do not set a resource-type header
+	Topic string `mapstructure:"topic"`
+	// Fields map values from the message to fields of the ReportResourceRequest
+	Fields []FieldMapping `mapstructure:"fields"`
+}
+
+// FieldMapping maps a value in the message to a field of the ReportResourceRequest
+type FieldMapping struct {
+	// Target is the dot separated path of the request field, e.g. representations.common.workspace_id
+	Target string `mapstructure:"target"`
+	// Source is the dot separated path of the value in the message, e.g. payload.groups[0].id
+	// Paths start at the schema envelope, and schemaless messages are treated as the payload of one
+	// Values that are strings containing JSON are decoded when the path continues into them
+	Source string `mapstructure:"source"`
+	// Default is used when Source is not set or the message has no value at Source
+	Default interface{} `mapstructure:"default"`
+	// Required fails the transform when no value or default is found
+	Required bool `mapstructure:"required"`
+}
+
+// pathSegment is a single key of a path followed by any array indexes, e.g. groups[0]
+type pathSegment struct {
+	key     string
+	indexes []int
+}
+
+var pathSegmentPattern = regexp.MustCompile(`^([^\[\]]+)((?:\[\d+\])*)$`)
+
+// Validate checks that the mapping has a resource type and that every field path can be parsed
+func (s MappingSpec) Validate() error {
+	if s.ResourceType == "" {
+		return fmt.Errorf("mapping resource-type can not be empty")
+	}
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("mapping for resource type %s has no fields", s.ResourceType)
+	}
+	for _, field := range s.Fields {
+		target, err := parsePath(field.Target)
+		if err != nil {
+			return fmt.Errorf("mapping for resource type %s has an invalid target: %w", s.ResourceType, err)
+		}
+		for _, segment := range target {
+			if len(segment.indexes) > 0 {
+				return fmt.Errorf("mapping for resource type %s has an invalid target %s: indexes are not supported", s.ResourceType, field.Target)
+			}
+		}
+		if field.Source == "" && field.Default == nil {
+			return fmt.Errorf("mapping for resource type %s target %s needs a source or default", s.ResourceType, field.Target)
+		}
+		if field.Source != "" {
+			if _, err := parsePath(field.Source); err != nil {
+				return fmt.Errorf("mapping for resource type %s has an invalid source: %w", s.ResourceType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MappingTransformer is a Transformer built from a MappingSpec
+type MappingTransformer struct {
+	spec MappingSpec
+}
+
+// NewMappingTransformer validates the spec and returns a Transformer that applies it
+func NewMappingTransformer(spec MappingSpec) (*MappingTransformer, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &MappingTransformer{spec: spec}, nil
+}
+
+func (m *MappingTransformer) IsDeleted(msgValue []byte) (bool, error) {
+	return len(msgValue) == 0 || isEmptyJSON(msgValue), nil
+}
+
+// ToReportResourceRequest builds the request from the mapped fields of the message
+func (m *MappingTransformer) ToReportResourceRequest(ctx context.Context, msgValue []byte) (*v1beta2.ReportResourceRequest, error) {
+	var msg interface{}
+	if err := json.Unmarshal(msgValue, &msg); err != nil {
+		return nil, fmt.Errorf("error unmarshaling Debezium message: %v", err)
+	}
+	if IsSchemaless(msgValue) {
+		// source paths are relative to the schema envelope, so schemaless messages are mapped as its payload
+		msg = map[string]interface{}{"payload": msg}
+	}
+	return m.toReportResourceRequest(msg)
+}
+
+// toReportResourceRequest builds the request from the mapped fields of a decoded message
+func (m *MappingTransformer) toReportResourceRequest(msg interface{}) (*v1beta2.ReportResourceRequest, error) {
+	intermediatePayload := map[string]interface{}{}
+	for _, field := range m.spec.Fields {
+		var value interface{}
+		if field.Source != "" {
+			source, _ := parsePath(field.Source)
+			value = lookupPath(msg, source)
+		}
+		if value == nil {
+			value = field.Default
+		}
+		if value == nil {
+			if field.Required {
+				return nil, fmt.Errorf("required field %s is missing: no value found at %s", field.Target, field.Source)
+			}
+			continue
+		}
+		target, _ := parsePath(field.Target)
+		setPath(intermediatePayload, target, value)
+	}
+
+	// Marshal and unmarshal to convert to the expected type
+	payloadBytes, err := json.Marshal(intermediatePayload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling intermediate payload: %v", err)
+	}
+
+	var request v1beta2.ReportResourceRequest
+	err = json.Unmarshal(payloadBytes, &request)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling to ReportResourceRequest: %v", err)
+	}
+	return &request, nil
+}
+
+// ToDeleteResourceRequest builds the request from the resource ID in the message key
+func (m *MappingTransformer) ToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error) {
+	resourceID, err := ResourceIDFromKey(msgKey)
+	if err != nil {
+		return nil, err
+	}
+	return &v1beta2.DeleteResourceRequest{
+		Reference: &v1beta2.ResourceReference{
+			ResourceType: m.spec.ResourceType,
+			ResourceId:   resourceID,
+			Reporter: &v1beta2.ReporterReference{
+				Type: m.spec.ReporterType,
+			},
+		},
+	}, nil
+}
+
+// parsePath splits a dot separated path into its keys and array indexes
+func parsePath(path string) ([]pathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path can not be empty")
+	}
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		match := pathSegmentPattern.FindStringSubmatch(part)
+		if match == nil {
+			return nil, fmt.Errorf("invalid path %s", path)
+		}
+		segment := pathSegment{key: match[1]}
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in path %s: %w", path, err)
+			}
+			segment.indexes = append(segment.indexes, i)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// lookupPath returns the value at the path, or nil if any part of the path does not exist
+func lookupPath(value interface{}, path []pathSegment) interface{} {
+	for _, segment := range path {
+		object, ok := decodeString(value).(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[segment.key]
+		for _, index := range segment.indexes {
+			array, ok := decodeString(value).([]interface{})
+			if !ok || index >= len(array) {
+				return nil
+			}
+			value = array[index]
+		}
+	}
+	return value
+}
+
+// decodeString decodes string values containing a JSON object or array, such as groups stored as a JSON string
+func decodeString(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
+
+// setPath sets the value at the path, creating any intermediate objects
+func setPath(object map[string]interface{}, path []pathSegment, value interface{}) {
+	for _, segment := range path[:len(path)-1] {
+		next, ok := object[segment.key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			object[segment.key] = next
+		}
+		object = next
+	}
+	object[path[len(path)-1].key] = value
+}
@@ -0,0 +1,180 @@
+package transforms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/project-kessel/inventory-consumer/consumer/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testHostMappingSpec() MappingSpec {
+	return MappingSpec{
+		ResourceType: types.HostResourceType,
+		ReporterType: types.HostReporterType,
+		Fields: []FieldMapping{
+			{Target: "type", Default: types.HostResourceType},
+			{Target: "reporter_type", Default: types.HostReporterType},
+			{Target: "reporter_instance_id", Default: types.HostReporterInstanceID},
+			{Target: "representations.metadata.local_resource_id", Source: "payload.id", Required: true},
+			{Target: "representations.metadata.api_href", Default: types.HostAPIHref},
+			{Target: "representations.metadata.reporter_version", Source: "payload.reporter_version", Default: types.HostReporterVersion},
+			{Target: "representations.reporter.satellite_id", Source: "payload.satellite_id"},
+			{Target: "representations.reporter.ansible_host", Source: "payload.ansible_host"},
+			{Target: "representations.common.workspace_id", Source: "payload.groups[0].id", Required: true},
+		},
+	}
+}
+
+func TestMappingTransformer_ToReportResourceRequest(t *testing.T) {
+	transformer, err := NewMappingTransformer(testHostMappingSpec())
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name              string
+		message           string
+		expectError       bool
+		expectedWorkspace string
+	}{
+		{
+			name:              "groups array is mapped",
+			message:           testHostMessageValid,
+			expectedWorkspace: testWorkspaceID1,
+		},
+		{
+			name:              "groups stored as a JSON string are decoded",
+			message:           `{"payload": {"id": "` + testHostID1 + `", "groups": "[{\"id\": \"` + testWorkspaceID2 + `\"}]"}}`,
+			expectedWorkspace: testWorkspaceID2,
+		},
+		{
+			name:              "schemaless messages are mapped as the payload",
+			message:           `{"id": "` + testHostID1 + `", "groups": [{"id": "` + testWorkspaceID1 + `"}]}`,
+			expectedWorkspace: testWorkspaceID1,
+		},
+		{
+			name:        "missing required field is an error",
+			message:     testHostMessageNoGroups,
+			expectError: true,
+		},
+		{
+			name:        "invalid JSON is an error",
+			message:     testHostMessageInvalidJSON,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := transformer.ToReportResourceRequest(context.Background(), []byte(test.message))
+			if test.expectError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, types.HostResourceType, req.Type)
+			assert.Equal(t, types.HostReporterType, req.ReporterType)
+			assert.Equal(t, types.HostReporterInstanceID, req.ReporterInstanceId)
+			assert.Equal(t, testHostID1, req.Representations.Metadata.LocalResourceId)
+			assert.Equal(t, types.HostAPIHref, req.Representations.Metadata.ApiHref)
+			assert.Equal(t, types.HostReporterVersion, *req.Representations.Metadata.ReporterVersion)
+			assert.Equal(t, test.expectedWorkspace, req.Representations.Common.AsMap()["workspace_id"])
+		})
+	}
+}
+
+func TestMappingTransformer_ToDeleteResourceRequest(t *testing.T) {
+	transformer, err := NewMappingTransformer(testHostMappingSpec())
+	assert.Nil(t, err)
+
+	req, err := transformer.ToDeleteResourceRequest(nil, []byte(testTombstoneKey))
+	assert.Nil(t, err)
+	assert.Equal(t, types.HostResourceType, req.Reference.ResourceType)
+	assert.Equal(t, testDeletedHostID, req.Reference.ResourceId)
+	assert.Equal(t, types.HostReporterType, req.Reference.Reporter.Type)
+
+	_, err = transformer.ToDeleteResourceRequest(nil, []byte(testTombstoneKeyNoID))
+	assert.NotNil(t, err)
+}
+
+func TestMappingSpec_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      MappingSpec
+		expectErr bool
+	}{
+		{
+			name: "valid spec",
+			spec: testHostMappingSpec(),
+		},
+		{
+			name:      "missing resource type",
+			spec:      MappingSpec{Fields: []FieldMapping{{Target: "type", Default: "host"}}},
+			expectErr: true,
+		},
+		{
+			name:      "no fields",
+			spec:      MappingSpec{ResourceType: "host"},
+			expectErr: true,
+		},
+		{
+			name:      "target with an index",
+			spec:      MappingSpec{ResourceType: "host", Fields: []FieldMapping{{Target: "groups[0]", Source: "payload.id"}}},
+			expectErr: true,
+		},
+		{
+			name:      "invalid source path",
+			spec:      MappingSpec{ResourceType: "host", Fields: []FieldMapping{{Target: "type", Source: "payload..id"}}},
+			expectErr: true,
+		},
+		{
+			name:      "field without a source or default",
+			spec:      MappingSpec{ResourceType: "host", Fields: []FieldMapping{{Target: "type"}}},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.spec.Validate()
+			if test.expectErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestRegistry_WithMappings(t *testing.T) {
+	spec := testHostMappingSpec()
+	spec.ResourceType = "rhel_host"
+	spec.Topic = "outbox.event.rhel.hosts"
+	hostSpec := DefaultHostMapping()
+	hostSpec.Topic = "outbox.event.hbi.hosts"
+	registry, err := DefaultRegistry.WithMappings([]MappingSpec{spec, hostSpec})
+	assert.Nil(t, err)
+
+	transformer, err := registry.Lookup("rhel_host", "")
+	assert.Nil(t, err)
+	assert.IsType(t, &MappingTransformer{}, transformer)
+
+	transformer, err = registry.Lookup("", "outbox.event.rhel.hosts")
+	assert.Nil(t, err)
+	assert.IsType(t, &MappingTransformer{}, transformer)
+
+	// the host transformer keeps deriving its values and uses the spec as its mapping
+	transformer, err = registry.Lookup(types.HostResourceType, "")
+	assert.Nil(t, err)
+	assert.IsType(t, &HostTransformer{}, transformer)
+	assert.NotEqual(t, DefaultHostTransformer, transformer)
+
+	transformer, err = registry.Lookup("", "outbox.event.hbi.hosts")
+	assert.Nil(t, err)
+	assert.IsType(t, &HostTransformer{}, transformer)
+
+	// the original registry is unchanged
+	transformer, err = DefaultRegistry.Lookup(types.HostResourceType, "")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultHostTransformer, transformer)
+
+	_, err = DefaultRegistry.WithMappings([]MappingSpec{{ResourceType: "host"}})
+	assert.NotNil(t, err)
+}
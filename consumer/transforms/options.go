@@ -0,0 +1,103 @@
+package transforms
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/project-kessel/inventory-consumer/consumer/types"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// MultipleGroupsFirst reports hosts in multiple groups using the workspace of the first group
+	MultipleGroupsFirst = "first"
+	// MultipleGroupsReject rejects hosts in multiple groups as unprocessable
+	MultipleGroupsReject = "reject"
+)
+
+// HostOptions sets the reporter identity and links reported for migrated hosts, and how their workspace is chosen
+// APIHref and ConsoleHref are Go templates executed with the host payload, e.g. https://console.example.com/inventory/{{.ID}}
+type HostOptions struct {
+	ReporterInstanceID   string `mapstructure:"reporter-instance-id"`
+	ReporterVersion      string `mapstructure:"reporter-version"`
+	APIHref              string `mapstructure:"api-href"`
+	ConsoleHref          string `mapstructure:"console-href"`
+	UngroupedWorkspaceID string `mapstructure:"ungrouped-workspace-id"`
+	MultipleGroups       string `mapstructure:"multiple-groups"`
+	// DefaultWorkspaceFile and DefaultWorkspaceURL configure how the default workspace of a host's org is resolved
+	// for hosts without a group, before falling back to UngroupedWorkspaceID. Lookups from the URL are cached, with
+	// orgs that have no default workspace cached for DefaultWorkspaceNotFoundCacheSeconds. Lookups are authenticated
+	// with DefaultWorkspaceHeaders and the bearer token in DefaultWorkspaceTokenFile.
+	DefaultWorkspaceFile                 string            `mapstructure:"default-workspace-file"`
+	DefaultWorkspaceURL                  string            `mapstructure:"default-workspace-url"`
+	DefaultWorkspaceCacheSeconds         int               `mapstructure:"default-workspace-cache-seconds"`
+	DefaultWorkspaceNotFoundCacheSeconds int               `mapstructure:"default-workspace-not-found-cache-seconds"`
+	DefaultWorkspaceHeaders              map[string]string `mapstructure:"default-workspace-headers"`
+	DefaultWorkspaceTokenFile            string            `mapstructure:"default-workspace-token-file"`
+}
+
+func NewHostOptions() *HostOptions {
+	return &HostOptions{
+		ReporterInstanceID:                   types.HostReporterInstanceID,
+		ReporterVersion:                      types.HostReporterVersion,
+		APIHref:                              types.HostAPIHref,
+		ConsoleHref:                          types.HostConsoleHref,
+		MultipleGroups:                       MultipleGroupsFirst,
+		DefaultWorkspaceCacheSeconds:         300,
+		DefaultWorkspaceNotFoundCacheSeconds: 30,
+	}
+}
+
+func (o *HostOptions) AddFlags(fs *pflag.FlagSet, prefix string) {
+	if prefix != "" {
+		prefix = prefix + "."
+	}
+	fs.StringVar(&o.ReporterInstanceID, prefix+"reporter-instance-id", o.ReporterInstanceID, "reporter instance ID reported for hosts")
+	fs.StringVar(&o.ReporterVersion, prefix+"reporter-version", o.ReporterVersion, "reporter version reported for hosts")
+	fs.StringVar(&o.APIHref, prefix+"api-href", o.APIHref, "API link reported for each host, templated with the host payload, e.g. https://console.example.com/api/inventory/v1/hosts/{{.ID}}")
+	fs.StringVar(&o.ConsoleHref, prefix+"console-href", o.ConsoleHref, "console link reported for each host, templated with the host payload, e.g. https://console.example.com/insights/inventory/{{.ID}}")
+	fs.StringVar(&o.UngroupedWorkspaceID, prefix+"ungrouped-workspace-id", o.UngroupedWorkspaceID, "workspace reported for hosts without a group; when empty, ungrouped hosts are rejected as unprocessable")
+	fs.StringVar(&o.MultipleGroups, prefix+"multiple-groups", o.MultipleGroups, "how hosts in more than one group are handled: first reports the first group's workspace, reject rejects the host as unprocessable (default: first)")
+	fs.StringVar(&o.DefaultWorkspaceFile, prefix+"default-workspace-file", o.DefaultWorkspaceFile, "YAML or JSON file mapping org IDs to the default workspace used for hosts without a group")
+	fs.StringVar(&o.DefaultWorkspaceURL, prefix+"default-workspace-url", o.DefaultWorkspaceURL, "URL used to look up the default workspace for hosts without a group, templated with the org ID, e.g. http://rbac:8080/api/workspaces/default?org_id={{.OrgID}}")
+	fs.IntVar(&o.DefaultWorkspaceCacheSeconds, prefix+"default-workspace-cache-seconds", o.DefaultWorkspaceCacheSeconds, "how long default workspaces looked up by URL are cached, 0 disables (default: 300)")
+	fs.IntVar(&o.DefaultWorkspaceNotFoundCacheSeconds, prefix+"default-workspace-not-found-cache-seconds", o.DefaultWorkspaceNotFoundCacheSeconds, "how long orgs without a default workspace at the URL are cached, 0 disables (default: 30)")
+	fs.StringToStringVar(&o.DefaultWorkspaceHeaders, prefix+"default-workspace-headers", o.DefaultWorkspaceHeaders, "headers sent with each default workspace lookup, such as the RBAC pre-shared key headers")
+	fs.StringVar(&o.DefaultWorkspaceTokenFile, prefix+"default-workspace-token-file", o.DefaultWorkspaceTokenFile, "file containing a bearer token sent with each default workspace lookup, read for every lookup so rotated tokens are used")
+}
+
+func (o *HostOptions) Validate() []error {
+	var errs []error
+
+	if o.ReporterInstanceID == "" {
+		errs = append(errs, fmt.Errorf("host reporter instance ID can not be empty"))
+	}
+	if o.APIHref == "" {
+		errs = append(errs, fmt.Errorf("host API href can not be empty"))
+	}
+	if o.MultipleGroups != MultipleGroupsFirst && o.MultipleGroups != MultipleGroupsReject {
+		errs = append(errs, fmt.Errorf("host multiple groups must be %s or %s: multiple-groups='%s'", MultipleGroupsFirst, MultipleGroupsReject, o.MultipleGroups))
+	}
+	if o.DefaultWorkspaceFile != "" && o.DefaultWorkspaceURL != "" {
+		errs = append(errs, fmt.Errorf("only one of host default workspace file or URL can be set"))
+	}
+	if (len(o.DefaultWorkspaceHeaders) > 0 || o.DefaultWorkspaceTokenFile != "") && o.DefaultWorkspaceURL == "" {
+		errs = append(errs, fmt.Errorf("host default workspace headers and token file require a default workspace URL"))
+	}
+	if o.DefaultWorkspaceCacheSeconds < 0 {
+		errs = append(errs, fmt.Errorf("host default workspace cache seconds can not be negative"))
+	}
+	if o.DefaultWorkspaceNotFoundCacheSeconds < 0 {
+		errs = append(errs, fmt.Errorf("host default workspace not found cache seconds can not be negative"))
+	}
+	if _, err := template.New("default-workspace-url").Parse(o.DefaultWorkspaceURL); err != nil {
+		errs = append(errs, fmt.Errorf("invalid host default workspace URL template: %w", err))
+	}
+	if _, err := template.New("api-href").Option("missingkey=error").Parse(o.APIHref); err != nil {
+		errs = append(errs, fmt.Errorf("invalid host API href template: %w", err))
+	}
+	if _, err := template.New("console-href").Option("missingkey=error").Parse(o.ConsoleHref); err != nil {
+		errs = append(errs, fmt.Errorf("invalid host console href template: %w", err))
+	}
+	return errs
+}
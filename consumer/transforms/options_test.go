@@ -0,0 +1,101 @@
+package transforms
+
+import (
+	"testing"
+
+	"github.com/project-kessel/inventory-consumer/consumer/types"
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHostOptions(t *testing.T) {
+	expectedOptions := &HostOptions{
+		ReporterInstanceID:                   types.HostReporterInstanceID,
+		ReporterVersion:                      types.HostReporterVersion,
+		APIHref:                              types.HostAPIHref,
+		ConsoleHref:                          types.HostConsoleHref,
+		MultipleGroups:                       MultipleGroupsFirst,
+		DefaultWorkspaceCacheSeconds:         300,
+		DefaultWorkspaceNotFoundCacheSeconds: 30,
+	}
+	assert.Equal(t, expectedOptions, NewHostOptions())
+}
+
+func TestHostOptions_AddFlags(t *testing.T) {
+	options := NewHostOptions()
+	prefix := "consumer.host"
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	options.AddFlags(fs, prefix)
+
+	common.AllOptionsHaveFlags(t, prefix, fs, *options, nil)
+}
+
+func TestHostOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     *HostOptions
+		expectError bool
+	}{
+		{
+			name:        "default options are valid",
+			options:     NewHostOptions(),
+			expectError: false,
+		},
+		{
+			name: "templated hrefs are valid",
+			options: &HostOptions{
+				ReporterInstanceID: "console.redhat.com",
+				APIHref:            "https://console.redhat.com/api/inventory/v1/hosts/{{.ID}}",
+				ConsoleHref:        "https://console.redhat.com/insights/inventory/{{.ID}}",
+				MultipleGroups:     MultipleGroupsReject,
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid href template",
+			options: &HostOptions{
+				ReporterInstanceID: "console.redhat.com",
+				APIHref:            "https://console.redhat.com/api/inventory/v1/hosts/{{.ID",
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid multiple groups handling",
+			options: &HostOptions{
+				ReporterInstanceID: "console.redhat.com",
+				APIHref:            types.HostAPIHref,
+				MultipleGroups:     "last",
+			},
+			expectError: true,
+		},
+		{
+			name: "default workspace file and URL are both set",
+			options: &HostOptions{
+				ReporterInstanceID:   "console.redhat.com",
+				APIHref:              types.HostAPIHref,
+				MultipleGroups:       MultipleGroupsFirst,
+				DefaultWorkspaceFile: "/etc/kic/workspaces.yaml",
+				DefaultWorkspaceURL:  "http://rbac:8080/api/workspaces/default?org_id={{.OrgID}}",
+			},
+			expectError: true,
+		},
+		{
+			name: "empty reporter instance ID",
+			options: &HostOptions{
+				APIHref: types.HostAPIHref,
+			},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.options.Validate()
+			if test.expectError {
+				assert.NotNil(t, errs)
+			} else {
+				assert.Nil(t, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package transforms
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Payload returns the payload of a message serialized by the Kafka Connect JsonConverter
+// Messages in the {"schema":...,"payload":...} envelope written with schemas.enable=true return their payload, and
+// schemaless JSON written with schemas.enable=false is returned as-is
+func Payload(msg []byte) (json.RawMessage, error) {
+	var value json.RawMessage
+	if err := json.Unmarshal(msg, &value); err != nil {
+		return nil, err
+	}
+	if payload, ok := schemaPayload(value); ok {
+		return payload, nil
+	}
+	return value, nil
+}
+
+// IsSchemaless returns true if the message is JSON that is not in the JsonConverter schema envelope
+func IsSchemaless(msg []byte) bool {
+	_, ok := schemaPayload(msg)
+	return !ok && json.Valid(msg)
+}
+
+// schemaPayload returns the payload of an object with schema and payload fields, whatever other fields it has, or of an
+// object whose only field is payload
+func schemaPayload(msg []byte) (json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return nil, false
+	}
+	payload, ok := fields["payload"]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := fields["schema"]; !ok && len(fields) > 1 {
+		return nil, false
+	}
+	return payload, true
+}
+
+// KeyID returns the resource ID in a message key, which can be an object with an id or a string, with or without
+// the schema envelope; keys that are not JSON are plain string keys written by the StringConverter
+func KeyID(msgKey []byte) (string, error) {
+	trimmed := strings.TrimSpace(string(msgKey))
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "\"") {
+		return trimmed, nil
+	}
+
+	payload, err := Payload(msgKey)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	if err := json.Unmarshal(payload, &id); err == nil {
+		return id, nil
+	}
+	var key struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &key); err != nil {
+		return "", fmt.Errorf("key payload is neither an object nor a string: %v", err)
+	}
+	return key.ID, nil
+}
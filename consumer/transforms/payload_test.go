@@ -0,0 +1,121 @@
+package transforms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "schema envelope returns the payload",
+			msg:      `{"schema":{"type":"struct"},"payload":{"id":"1"}}`,
+			expected: `{"id":"1"}`,
+		},
+		{
+			name:     "schema envelope with another field returns the payload",
+			msg:      `{"schema":{"type":"struct"},"payload":{"id":"1"},"headers":{"source":"hbi"}}`,
+			expected: `{"id":"1"}`,
+		},
+		{
+			name:     "payload without a schema returns the payload",
+			msg:      `{"payload":{"id":"1"}}`,
+			expected: `{"id":"1"}`,
+		},
+		{
+			name:     "schemaless object is returned as-is",
+			msg:      `{"id":"1","payload":"not an envelope"}`,
+			expected: `{"id":"1","payload":"not an envelope"}`,
+		},
+		{
+			name:     "schemaless string is returned as-is",
+			msg:      `"1"`,
+			expected: `"1"`,
+		},
+		{
+			name:        "invalid JSON is an error",
+			msg:         `{invalid`,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := Payload([]byte(test.msg))
+			if test.expectError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.JSONEq(t, test.expected, string(payload))
+		})
+	}
+}
+
+func TestIsSchemaless(t *testing.T) {
+	assert.False(t, IsSchemaless([]byte(`{"schema":{"type":"struct"},"payload":{"id":"1"}}`)))
+	assert.False(t, IsSchemaless([]byte(`{"schema":{"type":"struct"},"payload":{"id":"1"},"headers":{"source":"hbi"}}`)))
+	assert.True(t, IsSchemaless([]byte(`{"id":"1","payload":"not an envelope"}`)))
+	assert.False(t, IsSchemaless([]byte(`{invalid`)))
+}
+
+func TestKeyID(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "struct key with schema",
+			key:      `{"schema":{"type":"struct"},"payload":{"id":"` + testHostID1 + `"}}`,
+			expected: testHostID1,
+		},
+		{
+			name:     "struct key with schema and another field",
+			key:      `{"schema":{"type":"struct"},"payload":{"id":"` + testHostID1 + `"},"version":1}`,
+			expected: testHostID1,
+		},
+		{
+			name:     "string key with schema",
+			key:      `{"schema":{"type":"string"},"payload":"` + testHostID1 + `"}`,
+			expected: testHostID1,
+		},
+		{
+			name:     "schemaless struct key",
+			key:      `{"id":"` + testHostID1 + `"}`,
+			expected: testHostID1,
+		},
+		{
+			name:     "schemaless JSON string key",
+			key:      `"` + testHostID1 + `"`,
+			expected: testHostID1,
+		},
+		{
+			name:     "plain string key",
+			key:      testHostID1,
+			expected: testHostID1,
+		},
+		{
+			name:        "invalid JSON key is an error",
+			key:         testTombstoneKeyInvalidJSON,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := KeyID([]byte(test.key))
+			if test.expectError {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, id)
+		})
+	}
+}
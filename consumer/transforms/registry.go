@@ -0,0 +1,151 @@
+package transforms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/project-kessel/inventory-consumer/consumer/types"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+)
+
+// Transformer converts the Debezium change events captured for a single resource type into Inventory API requests
+type Transformer interface {
+	// IsDeleted returns true if the message represents the deletion of the resource
+	IsDeleted(msgValue []byte) (bool, error)
+	// ToReportResourceRequest transforms a created or updated resource into a ReportResourceRequest
+	// Lookups made to build the request, such as of the default workspace, are canceled with ctx
+	ToReportResourceRequest(ctx context.Context, msgValue []byte) (*v1beta2.ReportResourceRequest, error)
+	// ToDeleteResourceRequest transforms a deleted resource into a DeleteResourceRequest
+	ToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error)
+}
+
+// Mappable is implemented by transformers whose mapping can be replaced by a MappingSpec while keeping the values they
+// derive from the message available as sources
+type Mappable interface {
+	WithMapping(spec MappingSpec) (Transformer, error)
+}
+
+// Registry selects the Transformer for a migration message by its resource type, or by its topic when the message
+// does not declare a resource type
+type Registry struct {
+	mu                  sync.RWMutex
+	resourceTypes       map[string]Transformer
+	topics              map[string]Transformer
+	defaultResourceType string
+}
+
+// DefaultRegistry is the registry used by consumers created with consumer.New
+// It is populated with the built-in transformers and can be extended with Register and RegisterTopic
+var DefaultRegistry = NewDefaultRegistry()
+
+// NewRegistry returns an empty Registry that uses the transformer registered for defaultResourceType when a message
+// matches neither a resource type nor a topic
+func NewRegistry(defaultResourceType string) *Registry {
+	return &Registry{
+		resourceTypes:       make(map[string]Transformer),
+		topics:              make(map[string]Transformer),
+		defaultResourceType: defaultResourceType,
+	}
+}
+
+// NewDefaultRegistry returns a Registry with the built-in transformers registered, defaulting to hosts
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry(types.HostResourceType)
+	if err := r.Register(types.HostResourceType, DefaultHostTransformer); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Register adds a Transformer for the given resource type
+func (r *Registry) Register(resourceType string, transformer Transformer) error {
+	return r.register(r.resourceTypes, "resource type", resourceType, transformer)
+}
+
+// RegisterTopic adds a Transformer used for messages from the given topic that do not declare a resource type
+func (r *Registry) RegisterTopic(topic string, transformer Transformer) error {
+	return r.register(r.topics, "topic", topic, transformer)
+}
+
+func (r *Registry) register(transformers map[string]Transformer, kind, name string, transformer Transformer) error {
+	if name == "" {
+		return fmt.Errorf("%s is required to register a transformer", kind)
+	}
+	if transformer == nil {
+		return fmt.Errorf("transformer for %s %s is nil", kind, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := transformers[name]; ok {
+		return fmt.Errorf("transformer already registered for %s %s", kind, name)
+	}
+	transformers[name] = transformer
+	return nil
+}
+
+// Lookup returns the Transformer for a message. A resource type takes precedence over the topic, and messages
+// matching neither use the default resource type. An unregistered resource type is an error.
+func (r *Registry) Lookup(resourceType, topic string) (Transformer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if resourceType != "" {
+		if transformer, ok := r.resourceTypes[resourceType]; ok {
+			return transformer, nil
+		}
+		return nil, fmt.Errorf("no transformer registered for resource type %s", resourceType)
+	}
+	if transformer, ok := r.topics[topic]; ok {
+		return transformer, nil
+	}
+	if transformer, ok := r.resourceTypes[r.defaultResourceType]; ok {
+		return transformer, nil
+	}
+	return nil, fmt.Errorf("no transformer registered for topic %s", topic)
+}
+
+// WithTransformer returns a copy of the registry with the transformer registered for the resource type, replacing any
+// transformer already registered for it
+func (r *Registry) WithTransformer(resourceType string, transformer Transformer) *Registry {
+	replaced := r.clone()
+	replaced.resourceTypes[resourceType] = transformer
+	return replaced
+}
+
+// WithMappings returns a copy of the registry with a MappingTransformer registered for each spec, replacing any
+// transformer already registered for the same resource type or topic. A Mappable transformer registered for the
+// resource type is registered with the spec as its mapping instead.
+func (r *Registry) WithMappings(specs []MappingSpec) (*Registry, error) {
+	mapped := r.clone()
+	for _, spec := range specs {
+		var transformer Transformer
+		var err error
+		if mappable, ok := mapped.resourceTypes[spec.ResourceType].(Mappable); ok {
+			transformer, err = mappable.WithMapping(spec)
+		} else {
+			transformer, err = NewMappingTransformer(spec)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mapped.resourceTypes[spec.ResourceType] = transformer
+		if spec.Topic != "" {
+			mapped.topics[spec.Topic] = transformer
+		}
+	}
+	return mapped, nil
+}
+
+func (r *Registry) clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cloned := NewRegistry(r.defaultResourceType)
+	for resourceType, transformer := range r.resourceTypes {
+		cloned.resourceTypes[resourceType] = transformer
+	}
+	for topic, transformer := range r.topics {
+		cloned.topics[topic] = transformer
+	}
+	return cloned
+}
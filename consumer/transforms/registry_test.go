@@ -0,0 +1,95 @@
+package transforms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/project-kessel/inventory-consumer/consumer/types"
+	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"github.com/stretchr/testify/assert"
+)
+
+// workspaceTransformer is a stand-in for a transformer of another captured table
+type workspaceTransformer struct{}
+
+func (workspaceTransformer) IsDeleted(msgValue []byte) (bool, error) {
+	return IsHostDeleted(msgValue)
+}
+
+func (workspaceTransformer) ToReportResourceRequest(ctx context.Context, msgValue []byte) (*v1beta2.ReportResourceRequest, error) {
+	return &v1beta2.ReportResourceRequest{Type: "workspace"}, nil
+}
+
+func (workspaceTransformer) ToDeleteResourceRequest(msgValue []byte, msgKey []byte) (*v1beta2.DeleteResourceRequest, error) {
+	return &v1beta2.DeleteResourceRequest{}, nil
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	registry := NewDefaultRegistry()
+	assert.Nil(t, registry.Register("workspace", workspaceTransformer{}))
+	assert.Nil(t, registry.RegisterTopic("outbox.event.hbi.groups", workspaceTransformer{}))
+
+	tests := []struct {
+		name         string
+		resourceType string
+		topic        string
+		expected     Transformer
+		expectErr    bool
+	}{
+		{
+			name:         "resource type selects the transformer",
+			resourceType: "workspace",
+			topic:        "outbox.event.hbi.hosts",
+			expected:     workspaceTransformer{},
+		},
+		{
+			name:     "topic selects the transformer when no resource type is set",
+			topic:    "outbox.event.hbi.groups",
+			expected: workspaceTransformer{},
+		},
+		{
+			name:     "unregistered topic without a resource type uses the default",
+			topic:    "outbox.event.hbi.hosts",
+			expected: DefaultHostTransformer,
+		},
+		{
+			name:         "host resource type selects the host transformer",
+			resourceType: types.HostResourceType,
+			topic:        "outbox.event.hbi.groups",
+			expected:     DefaultHostTransformer,
+		},
+		{
+			name:         "unregistered resource type is an error",
+			resourceType: "unknown",
+			topic:        "outbox.event.hbi.hosts",
+			expectErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transformer, err := registry.Lookup(test.resourceType, test.topic)
+			if test.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, transformer)
+		})
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewDefaultRegistry()
+	assert.NotNil(t, registry.Register(types.HostResourceType, workspaceTransformer{}), "duplicate resource type")
+	assert.NotNil(t, registry.Register("", workspaceTransformer{}), "empty resource type")
+	assert.NotNil(t, registry.RegisterTopic("outbox.event.hbi.groups", nil), "nil transformer")
+
+	assert.Nil(t, registry.RegisterTopic("outbox.event.hbi.groups", workspaceTransformer{}))
+	assert.NotNil(t, registry.RegisterTopic("outbox.event.hbi.groups", workspaceTransformer{}), "duplicate topic")
+}
+
+func TestRegistry_LookupWithoutDefault(t *testing.T) {
+	registry := NewRegistry("")
+	_, err := registry.Lookup("", "outbox.event.hbi.hosts")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,222 @@
+package transforms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/project-kessel/inventory-consumer/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultWorkspaceTimeout is the timeout for a single default workspace lookup
+const defaultWorkspaceTimeout = 10 * time.Second
+
+var (
+	// ErrWorkspaceNotFound is returned by a WorkspaceResolver when an org has no default workspace
+	ErrWorkspaceNotFound = errors.New("default workspace not found")
+	// ErrWorkspaceUnavailable is returned by a WorkspaceResolver when the default workspace could not be looked up,
+	// such as when the lookup service is unreachable; unlike other transform errors, these are worth retrying
+	ErrWorkspaceUnavailable = errors.New("default workspace lookup unavailable")
+)
+
+// WorkspaceResolver looks up the default workspace of an org, used for hosts that are not in a group
+type WorkspaceResolver interface {
+	DefaultWorkspace(ctx context.Context, orgID string) (string, error)
+}
+
+// NewWorkspaceResolver returns the WorkspaceResolver configured in the options, or nil if none is configured
+func NewWorkspaceResolver(options *HostOptions) (WorkspaceResolver, error) {
+	switch {
+	case options.DefaultWorkspaceFile != "":
+		return NewStaticWorkspaceResolver(options.DefaultWorkspaceFile)
+	case options.DefaultWorkspaceURL != "":
+		return NewHTTPWorkspaceResolver(options.DefaultWorkspaceURL,
+			time.Duration(options.DefaultWorkspaceCacheSeconds)*time.Second,
+			time.Duration(options.DefaultWorkspaceNotFoundCacheSeconds)*time.Second,
+			WorkspaceAuth{Headers: options.DefaultWorkspaceHeaders, TokenFile: options.DefaultWorkspaceTokenFile},
+			&http.Client{Timeout: defaultWorkspaceTimeout})
+	}
+	return nil, nil
+}
+
+// StaticWorkspaceResolver resolves default workspaces from a YAML or JSON file mapping org IDs to workspace IDs
+type StaticWorkspaceResolver struct {
+	workspaces map[string]string
+}
+
+// NewStaticWorkspaceResolver reads the org ID to workspace ID mappings from a file
+func NewStaticWorkspaceResolver(path string) (*StaticWorkspaceResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read default workspace file: %w", err)
+	}
+	workspaces := make(map[string]string)
+	if err := yaml.Unmarshal(data, &workspaces); err != nil {
+		return nil, fmt.Errorf("failed to parse default workspace file %s: %w", path, err)
+	}
+	return &StaticWorkspaceResolver{workspaces: workspaces}, nil
+}
+
+func (s *StaticWorkspaceResolver) DefaultWorkspace(ctx context.Context, orgID string) (string, error) {
+	workspaceID, ok := s.workspaces[orgID]
+	if !ok || workspaceID == "" {
+		return "", fmt.Errorf("%w: org_id=%s", ErrWorkspaceNotFound, orgID)
+	}
+	return workspaceID, nil
+}
+
+// WorkspaceAuth authenticates the requests of an HTTPWorkspaceResolver
+type WorkspaceAuth struct {
+	// Headers are sent with each request, such as the pre-shared key headers accepted by RBAC
+	Headers map[string]string
+	// TokenFile holds a bearer token sent with each request; it is read for every request so rotated tokens are used
+	TokenFile string
+}
+
+// authorize adds the headers and bearer token to a request
+func (a WorkspaceAuth) authorize(req *http.Request) error {
+	for key, value := range a.Headers {
+		req.Header.Set(key, value)
+	}
+	if a.TokenFile != "" {
+		token, err := secrets.Read(a.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read default workspace token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// HTTPWorkspaceResolver looks up default workspaces from an HTTP service and caches the results
+// Orgs without a default workspace are cached for a shorter time, so hosts of those orgs do not each send a request
+// but a workspace created for the org is soon found
+// The URL is a Go template executed with the escaped org ID, e.g. http://rbac:8080/api/workspaces/default?org_id={{.OrgID}}
+// The response is either a workspace object with an id, or a list of workspaces under data as returned by RBAC
+type HTTPWorkspaceResolver struct {
+	url         *template.Template
+	ttl         time.Duration
+	notFoundTTL time.Duration
+	auth        WorkspaceAuth
+	client      *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedWorkspace
+}
+
+// cachedWorkspace is a cached lookup; an empty id means the org has no default workspace
+type cachedWorkspace struct {
+	id      string
+	expires time.Time
+}
+
+// workspaceResponse matches both a single workspace and a list of workspaces
+type workspaceResponse struct {
+	ID   string `json:"id"`
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// NewHTTPWorkspaceResolver returns an HTTPWorkspaceResolver that caches workspaces for ttl and orgs without a default
+// workspace for notFoundTTL, authenticating its requests with auth; a zero ttl disables caching
+func NewHTTPWorkspaceResolver(url string, ttl, notFoundTTL time.Duration, auth WorkspaceAuth, client *http.Client) (*HTTPWorkspaceResolver, error) {
+	tmpl, err := template.New("default-workspace-url").Parse(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default workspace URL template: %w", err)
+	}
+	return &HTTPWorkspaceResolver{
+		url:         tmpl,
+		ttl:         ttl,
+		notFoundTTL: notFoundTTL,
+		auth:        auth,
+		client:      client,
+		cache:       make(map[string]cachedWorkspace),
+	}, nil
+}
+
+func (h *HTTPWorkspaceResolver) DefaultWorkspace(ctx context.Context, orgID string) (string, error) {
+	now := time.Now()
+	h.mu.Lock()
+	cached, ok := h.cache[orgID]
+	h.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		if cached.id == "" {
+			return "", fmt.Errorf("%w: org_id=%s", ErrWorkspaceNotFound, orgID)
+		}
+		return cached.id, nil
+	}
+
+	workspaceID, err := h.lookup(ctx, orgID)
+	switch {
+	case err == nil:
+		h.store(orgID, workspaceID, now, h.ttl)
+	case errors.Is(err, ErrWorkspaceNotFound):
+		h.store(orgID, "", now, h.notFoundTTL)
+	}
+	return workspaceID, err
+}
+
+// store caches a lookup made at now for ttl; a zero ttl does not cache it
+func (h *HTTPWorkspaceResolver) store(orgID, workspaceID string, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cache[orgID] = cachedWorkspace{id: workspaceID, expires: now.Add(ttl)}
+}
+
+func (h *HTTPWorkspaceResolver) lookup(ctx context.Context, orgID string) (string, error) {
+	// the org ID comes from the message, so it is escaped to keep it from changing the rest of the URL
+	var lookupURL strings.Builder
+	if err := h.url.Execute(&lookupURL, struct{ OrgID string }{OrgID: url.QueryEscape(orgID)}); err != nil {
+		return "", fmt.Errorf("error rendering default workspace URL for org_id=%s: %v", orgID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating default workspace request for org_id=%s: %v", orgID, err)
+	}
+	if err := h.auth.authorize(req); err != nil {
+		return "", fmt.Errorf("%w: org_id=%s: %v", ErrWorkspaceUnavailable, orgID, err)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: org_id=%s: %w", ErrWorkspaceUnavailable, orgID, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: org_id=%s", ErrWorkspaceNotFound, orgID)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%w: org_id=%s: unexpected status %s", ErrWorkspaceUnavailable, orgID, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: org_id=%s: %v", ErrWorkspaceUnavailable, orgID, err)
+	}
+	var workspace workspaceResponse
+	if err := json.Unmarshal(body, &workspace); err != nil {
+		return "", fmt.Errorf("error unmarshaling default workspace response for org_id=%s: %v", orgID, err)
+	}
+	if workspace.ID != "" {
+		return workspace.ID, nil
+	}
+	if len(workspace.Data) > 0 && workspace.Data[0].ID != "" {
+		return workspace.Data[0].ID, nil
+	}
+	return "", fmt.Errorf("%w: org_id=%s", ErrWorkspaceNotFound, orgID)
+}
@@ -0,0 +1,265 @@
+package transforms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testOrgID1 = "12345"
+	testOrgID2 = "67890"
+)
+
+func TestStaticWorkspaceResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspaces.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(`"`+testOrgID1+`": `+testWorkspaceID1+"\n"), 0o600))
+
+	resolver, err := NewStaticWorkspaceResolver(path)
+	assert.Nil(t, err)
+
+	workspaceID, err := resolver.DefaultWorkspace(context.Background(), testOrgID1)
+	assert.Nil(t, err)
+	assert.Equal(t, testWorkspaceID1, workspaceID)
+
+	_, err = resolver.DefaultWorkspace(context.Background(), testOrgID2)
+	assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+
+	_, err = NewStaticWorkspaceResolver(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NotNil(t, err)
+}
+
+func TestHTTPWorkspaceResolver(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Query().Get("org_id") {
+		case testOrgID1:
+			_, _ = w.Write([]byte(`{"data": [{"id": "` + testWorkspaceID1 + `", "type": "default"}]}`))
+		case testOrgID2:
+			_, _ = w.Write([]byte(`{"id": "` + testWorkspaceID2 + `"}`))
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	resolver, err := NewHTTPWorkspaceResolver(server.URL+"/workspaces/default?org_id={{.OrgID}}", time.Minute, time.Minute, WorkspaceAuth{}, server.Client())
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		orgID       string
+		expectedID  string
+		expectedErr error
+	}{
+		{
+			name:       "workspace list response",
+			orgID:      testOrgID1,
+			expectedID: testWorkspaceID1,
+		},
+		{
+			name:       "single workspace response",
+			orgID:      testOrgID2,
+			expectedID: testWorkspaceID2,
+		},
+		{
+			name:        "not found",
+			orgID:       "missing",
+			expectedErr: ErrWorkspaceNotFound,
+		},
+		{
+			name:        "service unavailable",
+			orgID:       "unavailable",
+			expectedErr: ErrWorkspaceUnavailable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			workspaceID, err := resolver.DefaultWorkspace(context.Background(), test.orgID)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedID, workspaceID)
+		})
+	}
+
+	// successful lookups are cached
+	before := requests.Load()
+	workspaceID, err := resolver.DefaultWorkspace(context.Background(), testOrgID1)
+	assert.Nil(t, err)
+	assert.Equal(t, testWorkspaceID1, workspaceID)
+	assert.Equal(t, before, requests.Load())
+
+	// orgs without a default workspace are cached
+	_, err = resolver.DefaultWorkspace(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+	assert.Equal(t, before, requests.Load())
+
+	// failed lookups are not
+	_, _ = resolver.DefaultWorkspace(context.Background(), "unavailable")
+	assert.Equal(t, before+1, requests.Load())
+}
+
+func TestHTTPWorkspaceResolver_NotFoundTTL(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// orgs without a default workspace expire from the cache sooner than workspaces
+	resolver, err := NewHTTPWorkspaceResolver(server.URL+"/workspaces/default?org_id={{.OrgID}}", time.Hour, 20*time.Millisecond, WorkspaceAuth{}, server.Client())
+	assert.Nil(t, err)
+
+	for range 2 {
+		_, err = resolver.DefaultWorkspace(context.Background(), testOrgID1)
+		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+	}
+	assert.Equal(t, int32(1), requests.Load())
+
+	time.Sleep(30 * time.Millisecond)
+	_, err = resolver.DefaultWorkspace(context.Background(), testOrgID1)
+	assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+	assert.Equal(t, int32(2), requests.Load())
+
+	// a zero TTL does not cache them
+	resolver, err = NewHTTPWorkspaceResolver(server.URL+"/workspaces/default?org_id={{.OrgID}}", time.Hour, 0, WorkspaceAuth{}, server.Client())
+	assert.Nil(t, err)
+	_, _ = resolver.DefaultWorkspace(context.Background(), testOrgID1)
+	_, _ = resolver.DefaultWorkspace(context.Background(), testOrgID1)
+	assert.Equal(t, int32(4), requests.Load())
+}
+
+func TestHTTPWorkspaceResolver_Request(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.Nil(t, os.WriteFile(tokenFile, []byte("first\n"), 0o600))
+
+	var query, psk, authorization atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query.Store(r.URL.Query())
+		psk.Store(r.Header.Get("x-rh-rbac-psk"))
+		authorization.Store(r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"id": "` + testWorkspaceID1 + `"}`))
+	}))
+	defer server.Close()
+
+	auth := WorkspaceAuth{Headers: map[string]string{"x-rh-rbac-psk": "secret"}, TokenFile: tokenFile}
+	resolver, err := NewHTTPWorkspaceResolver(server.URL+"/workspaces/default?org_id={{.OrgID}}", 0, 0, auth, server.Client())
+	assert.Nil(t, err)
+
+	// the org ID can not add query parameters to the URL
+	_, err = resolver.DefaultWorkspace(context.Background(), testOrgID1+"&type=root")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{testOrgID1 + "&type=root"}, query.Load().(url.Values)["org_id"])
+	assert.Empty(t, query.Load().(url.Values).Get("type"))
+	assert.Equal(t, "secret", psk.Load())
+	assert.Equal(t, "Bearer first", authorization.Load())
+
+	// rotated tokens are used for the next lookup
+	assert.Nil(t, os.WriteFile(tokenFile, []byte("second\n"), 0o600))
+	_, err = resolver.DefaultWorkspace(context.Background(), testOrgID1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer second", authorization.Load())
+
+	// lookups are canceled with the message
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = resolver.DefaultWorkspace(ctx, testOrgID2)
+	assert.ErrorIs(t, err, ErrWorkspaceUnavailable)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// fakeWorkspaceResolver resolves workspaces from a map, or returns err for every org when set
+type fakeWorkspaceResolver struct {
+	workspaces map[string]string
+	err        error
+}
+
+func (f fakeWorkspaceResolver) DefaultWorkspace(ctx context.Context, orgID string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	if workspaceID, ok := f.workspaces[orgID]; ok {
+		return workspaceID, nil
+	}
+	return "", ErrWorkspaceNotFound
+}
+
+func TestHostTransformer_DefaultWorkspace(t *testing.T) {
+	ungroupedHost := func(orgID string) []byte {
+		return []byte(`{"payload": {"id": "` + testHostID3 + `", "org_id": "` + orgID + `", "groups": []}}`)
+	}
+	tests := []struct {
+		name              string
+		resolver          WorkspaceResolver
+		ungroupedID       string
+		message           []byte
+		expectedWorkspace string
+		expectedErr       error
+	}{
+		{
+			name:              "ungrouped host uses its org's default workspace",
+			resolver:          fakeWorkspaceResolver{workspaces: map[string]string{testOrgID1: testWorkspaceID1}},
+			ungroupedID:       testWorkspaceID3,
+			message:           ungroupedHost(testOrgID1),
+			expectedWorkspace: testWorkspaceID1,
+		},
+		{
+			name:              "org without a default workspace falls back to the ungrouped workspace",
+			resolver:          fakeWorkspaceResolver{workspaces: map[string]string{testOrgID1: testWorkspaceID1}},
+			ungroupedID:       testWorkspaceID3,
+			message:           ungroupedHost(testOrgID2),
+			expectedWorkspace: testWorkspaceID3,
+		},
+		{
+			name:        "org without a default workspace and no ungrouped workspace is rejected",
+			resolver:    fakeWorkspaceResolver{},
+			message:     ungroupedHost(testOrgID2),
+			expectedErr: ErrHostHasNoGroups,
+		},
+		{
+			name:        "unavailable resolver is returned so the message can be retried",
+			resolver:    fakeWorkspaceResolver{err: ErrWorkspaceUnavailable},
+			ungroupedID: testWorkspaceID3,
+			message:     ungroupedHost(testOrgID1),
+			expectedErr: ErrWorkspaceUnavailable,
+		},
+		{
+			name:              "grouped host does not use the resolver",
+			resolver:          fakeWorkspaceResolver{err: ErrWorkspaceUnavailable},
+			message:           []byte(testHostMessageValid),
+			expectedWorkspace: testWorkspaceID1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := NewHostOptions()
+			options.UngroupedWorkspaceID = test.ungroupedID
+			transformer, err := NewHostTransformer(options)
+			assert.Nil(t, err)
+			transformer = transformer.WithWorkspaceResolver(test.resolver)
+
+			req, err := transformer.ToReportResourceRequest(context.Background(), test.message)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectedWorkspace, req.Representations.Common.AsMap()["workspace_id"])
+		})
+	}
+}
@@ -26,6 +26,7 @@ type HostMessage struct {
 
 type HostPayload struct {
 	ID                    string     `json:"id"`
+	OrgID                 string     `json:"org_id"`
 	AnsibleHost           string     `json:"ansible_host"`
 	InsightsID            string     `json:"insights_id"`
 	SubscriptionManagerID string     `json:"subscription_manager_id"`
@@ -3,76 +3,141 @@ package kessel
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/project-kessel/kessel-sdk-go/kessel/errors"
 	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
+	"google.golang.org/grpc"
 )
 
-type ClientProvider interface {
-	CreateOrUpdateResource(request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error)
-	DeleteResource(request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error)
+// Provider is implemented by the client for every Inventory API version
+// Clients for versions other than v1beta2 define their own provider interface using that version's request types
+type Provider interface {
 	IsEnabled() bool
 }
 
+// ClientProvider sends v1beta2 requests to Inventory API
+// Requests are canceled when ctx is canceled, such as when the consumer shuts down or loses the partition
+type ClientProvider interface {
+	CreateOrUpdateResource(ctx context.Context, request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error)
+	DeleteResource(ctx context.Context, request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error)
+	Provider
+}
+
+// RateLimited is implemented by clients that rate limit requests, so the time requests wait for the limiter can be
+// recorded
+type RateLimited interface {
+	SetRateLimitObserver(observe func(operation string, wait time.Duration))
+}
+
+// Connected is implemented by clients that share their connection to Inventory API, so clients for other API
+// versions can send requests on it. The connection uses the client's TLS settings but not the SDK's OAuth2
+// credentials, so clients that need tokens add their own call credentials.
+type Connected interface {
+	Conn() grpc.ClientConnInterface
+}
+
 type KesselClient struct {
 	*v1beta2.InventoryClient
 	Enabled     bool
 	AuthEnabled bool
+	// ReportTimeout and DeleteTimeout are the deadlines for each request, or 0 for no deadline
+	ReportTimeout time.Duration
+	DeleteTimeout time.Duration
+	// Limiter limits the rate of requests, or is nil if requests are not rate limited
+	Limiter         *Limiter
+	rateLimitWaited func(operation string, wait time.Duration)
+	conn            grpc.ClientConnInterface
+	// mu guards InventoryClient, which is replaced when the client secret is rotated
+	mu     sync.RWMutex
+	config CompletedConfig
+	logger *log.Helper
 }
 
+// previousClientCloseDelay is how long requests sent on a replaced SDK client have to finish before it is closed
+const previousClientCloseDelay = time.Minute
+
 func New(c CompletedConfig, logger *log.Helper) (*KesselClient, error) {
 	logger.Info("Setting up Inventory API client")
-	var client *v1beta2.InventoryClient
-	var err error
-
 	if !c.Enabled {
 		logger.Info("ClientProvider enabled: ", c.Enabled)
 		return &KesselClient{Enabled: false}, nil
 	}
 
+	client, err := newInventoryClient(c, c.ClientSecret)
+	if err != nil {
+		return &KesselClient{}, err
+	}
+
+	kesselClient := &KesselClient{
+		InventoryClient: client,
+		Enabled:         c.Enabled,
+		AuthEnabled:     c.EnableOidcAuth,
+		ReportTimeout:   time.Duration(c.ReportTimeoutSeconds) * time.Second,
+		DeleteTimeout:   time.Duration(c.DeleteTimeoutSeconds) * time.Second,
+		config:          c,
+		logger:          logger,
+	}
+	if c.gRPCConn != nil {
+		kesselClient.conn = c.gRPCConn
+	}
+	if c.RequestsPerSecond > 0 {
+		logger.Infof("Limiting Inventory API requests to %v per second", c.RequestsPerSecond)
+		kesselClient.Limiter = NewLimiter(c.RequestsPerSecond, c.RequestBurst, c.AdaptiveRateLimit)
+	}
+	return kesselClient, nil
+}
+
+// newInventoryClient builds the SDK client for the config, requesting access tokens with clientSecret
+func newInventoryClient(c CompletedConfig, clientSecret string) (*v1beta2.InventoryClient, error) {
+	builder := v1beta2.NewInventoryGRPCClientBuilder().
+		WithEndpoint(c.InventoryURL).
+		WithInsecure(c.Insecure).
+		WithMaxReceiveMessageSize(maxReceiveMessageSize).
+		WithMaxSendMessageSize(maxSendMessageSize)
+	if c.tlsConfig != nil {
+		builder = builder.WithTransportSecurity(c.tlsConfig)
+	}
 	if c.EnableOidcAuth {
-		client, err = v1beta2.NewInventoryGRPCClientBuilder().
-			WithEndpoint(c.InventoryURL).
-			WithOAuth2(c.ClientId, c.ClientSecret, c.TokenEndpoint).
-			WithInsecure(c.Insecure).
-			WithMaxReceiveMessageSize(8 * 1024 * 1024).
-			WithMaxSendMessageSize(4 * 1024 * 1024).
-			Build()
-	} else {
-		client, err = v1beta2.NewInventoryGRPCClientBuilder().
-			WithEndpoint(c.InventoryURL).
-			WithInsecure(c.Insecure).
-			WithMaxReceiveMessageSize(8 * 1024 * 1024).
-			WithMaxSendMessageSize(4 * 1024 * 1024).
-			Build()
+		builder = builder.WithOAuth2(c.ClientId, clientSecret, c.TokenEndpoint)
 	}
+	client, err := builder.Build()
 	if err != nil {
 		if errors.IsConnectionError(err) {
-			return &KesselClient{}, fmt.Errorf("failed to establish connection: %w", err)
+			return nil, fmt.Errorf("failed to establish connection: %w", err)
 		} else if errors.IsTokenError(err) {
-			return &KesselClient{}, fmt.Errorf("oauth2 token configuration failed: %w", err)
+			return nil, fmt.Errorf("oauth2 token configuration failed: %w", err)
 		} else {
-			return &KesselClient{}, fmt.Errorf("failed to create Inventory API gRPC client: %w", err)
+			return nil, fmt.Errorf("failed to create Inventory API gRPC client: %w", err)
 		}
 	}
-	return &KesselClient{
-		InventoryClient: client,
-		Enabled:         c.Enabled,
-		AuthEnabled:     c.EnableOidcAuth,
-	}, nil
+	return client, nil
 }
 
-func (k *KesselClient) CreateOrUpdateResource(request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error) {
-	resp, err := k.ReportResource(context.Background(), request)
+func (k *KesselClient) CreateOrUpdateResource(ctx context.Context, request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error) {
+	if err := k.waitForLimiter(ctx, "ReportResource"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, k.ReportTimeout)
+	defer cancel()
+	resp, err := k.inventory().ReportResource(ctx, request)
+	k.observe(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to report resource: %w", err)
 	}
 	return resp, nil
 }
 
-func (k *KesselClient) DeleteResource(request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error) {
-	resp, err := k.KesselInventoryServiceClient.DeleteResource(context.Background(), request)
+func (k *KesselClient) DeleteResource(ctx context.Context, request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error) {
+	if err := k.waitForLimiter(ctx, "DeleteResource"); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, k.DeleteTimeout)
+	defer cancel()
+	resp, err := k.inventory().KesselInventoryServiceClient.DeleteResource(ctx, request)
+	k.observe(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete resource: %w", err)
 	}
@@ -82,3 +147,73 @@ func (k *KesselClient) DeleteResource(request *v1beta2.DeleteResourceRequest) (*
 func (k *KesselClient) IsEnabled() bool {
 	return k.Enabled
 }
+
+// SetClientSecret rebuilds the SDK client with a rotated client secret, so new access tokens are requested with it
+// The previous client is closed once requests already sent on it had time to finish
+func (k *KesselClient) SetClientSecret(secret string) {
+	if !k.Enabled || !k.AuthEnabled {
+		return
+	}
+	client, err := newInventoryClient(k.config, secret)
+	if err != nil {
+		k.logger.Errorf("failed to rebuild Inventory API client with the rotated client secret: %v", err)
+		return
+	}
+
+	k.mu.Lock()
+	previous := k.InventoryClient
+	k.InventoryClient = client
+	k.mu.Unlock()
+	time.AfterFunc(previousClientCloseDelay, func() {
+		_ = previous.Close()
+	})
+}
+
+// inventory returns the current SDK client
+func (k *KesselClient) inventory() *v1beta2.InventoryClient {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.InventoryClient
+}
+
+// SetRateLimitObserver sets a function called with the time each request waited for the rate limiter
+// It must be set before requests are sent
+func (k *KesselClient) SetRateLimitObserver(observe func(operation string, wait time.Duration)) {
+	k.rateLimitWaited = observe
+}
+
+// Conn returns the connection to Inventory API, or nil if the client is disabled. Requests sent on it wait for the
+// client's rate limiter like v1beta2 requests.
+func (k *KesselClient) Conn() grpc.ClientConnInterface {
+	if k.conn == nil {
+		return nil
+	}
+	return &limitedConn{ClientConnInterface: k.conn, client: k}
+}
+
+// waitForLimiter waits until the rate limiter, if any, allows a request for the operation to be sent
+func (k *KesselClient) waitForLimiter(ctx context.Context, operation string) error {
+	if k.Limiter == nil {
+		return nil
+	}
+	wait, err := k.Limiter.Wait(ctx)
+	if k.rateLimitWaited != nil {
+		k.rateLimitWaited(operation, wait)
+	}
+	return err
+}
+
+// observe adjusts the rate limit using the outcome of a request
+func (k *KesselClient) observe(err error) {
+	if k.Limiter != nil {
+		k.Limiter.Observe(err)
+	}
+}
+
+// withTimeout returns a context with the given deadline, or a cancelable copy of ctx if timeout is not positive
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -1,8 +1,10 @@
 package kessel
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/project-kessel/inventory-consumer/internal/common"
@@ -23,11 +25,11 @@ func createTestConfig(enabled bool, enableOidcAuth bool) CompletedConfig {
 		TokenEndpoint:  "http://localhost:8080/token",
 	}
 
-	return CompletedConfig{
-		&completedConfig{
-			Options: options,
-		},
+	config, errs := NewConfig(options).Complete()
+	if errs != nil {
+		panic(errs)
 	}
+	return config
 }
 
 func createTestLogger() *log.Helper {
@@ -93,6 +95,51 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_RequestTimeouts(t *testing.T) {
+	config := createTestConfig(true, false)
+	config.ReportTimeoutSeconds = 5
+	config.DeleteTimeoutSeconds = 3
+
+	client, err := New(config, createTestLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, client.ReportTimeout)
+	assert.Equal(t, 3*time.Second, client.DeleteTimeout)
+}
+
+func TestKesselClient_SetClientSecret(t *testing.T) {
+	client, err := New(createTestConfig(true, true), createTestLogger())
+	assert.NoError(t, err)
+
+	// the SDK client is rebuilt so tokens are requested with the rotated secret
+	previous := client.inventory()
+	client.SetClientSecret("rotated")
+	assert.NotSame(t, previous, client.inventory())
+
+	// clients without OAuth2 have no secret to rotate
+	client, err = New(createTestConfig(true, false), createTestLogger())
+	assert.NoError(t, err)
+	previous = client.inventory()
+	client.SetClientSecret("rotated")
+	assert.Same(t, previous, client.inventory())
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// without a timeout the context can still be canceled by the caller
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel = withTimeout(parent, 0)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+	cancelParent()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
 func TestKesselClient_IsEnabled(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -135,7 +182,7 @@ func TestClientProvider_CreateOrUpdateResource(t *testing.T) {
 		{
 			name: "successful create or update resource",
 			mockSetup: func(m *mocks.MockClient) {
-				m.On("CreateOrUpdateResource", mock.Anything).
+				m.On("CreateOrUpdateResource", mock.Anything, mock.Anything).
 					Return(&v1beta2.ReportResourceResponse{}, nil)
 			},
 			request: &v1beta2.ReportResourceRequest{
@@ -149,7 +196,7 @@ func TestClientProvider_CreateOrUpdateResource(t *testing.T) {
 		{
 			name: "create or update resource fails",
 			mockSetup: func(m *mocks.MockClient) {
-				m.On("CreateOrUpdateResource", mock.Anything).
+				m.On("CreateOrUpdateResource", mock.Anything, mock.Anything).
 					Return(&v1beta2.ReportResourceResponse{}, errors.New("grpc error"))
 			},
 			request: &v1beta2.ReportResourceRequest{
@@ -164,7 +211,7 @@ func TestClientProvider_CreateOrUpdateResource(t *testing.T) {
 			name: "create or update resource with specific request data",
 			mockSetup: func(m *mocks.MockClient) {
 				// Use mock.Anything for simpler matching
-				m.On("CreateOrUpdateResource", mock.Anything).
+				m.On("CreateOrUpdateResource", mock.Anything, mock.Anything).
 					Return(&v1beta2.ReportResourceResponse{}, nil)
 			},
 			request: &v1beta2.ReportResourceRequest{
@@ -187,7 +234,7 @@ func TestClientProvider_CreateOrUpdateResource(t *testing.T) {
 			var client ClientProvider = mockClient
 
 			// Call the method being tested
-			result, err := client.CreateOrUpdateResource(test.request)
+			result, err := client.CreateOrUpdateResource(context.Background(), test.request)
 
 			// Assert expectations
 			if test.expectedError != nil {
@@ -215,7 +262,7 @@ func TestClientProvider_DeleteResource(t *testing.T) {
 		{
 			name: "successful delete resource",
 			mockSetup: func(m *mocks.MockClient) {
-				m.On("DeleteResource", mock.Anything).
+				m.On("DeleteResource", mock.Anything, mock.Anything).
 					Return(&v1beta2.DeleteResourceResponse{}, nil)
 			},
 			request: &v1beta2.DeleteResourceRequest{
@@ -233,7 +280,7 @@ func TestClientProvider_DeleteResource(t *testing.T) {
 		{
 			name: "delete resource fails",
 			mockSetup: func(m *mocks.MockClient) {
-				m.On("DeleteResource", mock.Anything).
+				m.On("DeleteResource", mock.Anything, mock.Anything).
 					Return(&v1beta2.DeleteResourceResponse{}, errors.New("delete failed"))
 			},
 			request: &v1beta2.DeleteResourceRequest{
@@ -260,7 +307,7 @@ func TestClientProvider_DeleteResource(t *testing.T) {
 			var client ClientProvider = mockClient
 
 			// Call the method being tested
-			result, err := client.DeleteResource(test.request)
+			result, err := client.DeleteResource(context.Background(), test.request)
 
 			// Assert expectations
 			if test.expectedError != nil {
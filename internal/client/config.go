@@ -1,11 +1,19 @@
 package kessel
 
 import (
-	"github.com/authzed/grpcutil"
+	"crypto/tls"
+	"fmt"
+
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+const (
+	maxReceiveMessageSize = 8 * 1024 * 1024
+	maxSendMessageSize    = 4 * 1024 * 1024
+)
+
 type Config struct {
 	*Options
 }
@@ -14,18 +22,11 @@ func NewConfig(o *Options) *Config {
 	return &Config{Options: o}
 }
 
-type tokenClientConfig struct {
-	clientId       string
-	clientSecret   string
-	url            string
-	enableOIDCAuth bool
-	insecure       bool
-}
-
 type completedConfig struct {
 	*Options
-	gRPCConn    *grpc.ClientConn
-	tokenConfig *tokenClientConfig
+	gRPCConn *grpc.ClientConn
+	// tlsConfig is passed to the SDK client builder, or is nil if the client is insecure
+	tlsConfig *tls.Config
 }
 
 type CompletedConfig struct {
@@ -33,37 +34,34 @@ type CompletedConfig struct {
 }
 
 func (c *Config) Complete() (CompletedConfig, []error) {
-	var opts []grpc.DialOption
-	opts = append(opts, grpc.EmptyDialOption{})
-
-	if c.Insecure {
-		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	} else {
-		tlsConfig, _ := grpcutil.WithSystemCerts(grpcutil.VerifyCA)
-		opts = append(opts, tlsConfig)
+	var tlsConfig *tls.Config
+	creds := insecure.NewCredentials()
+	if !c.Insecure {
+		var err error
+		tlsConfig, err = c.tlsConfig()
+		if err != nil {
+			return CompletedConfig{}, []error{fmt.Errorf("failed to configure TLS for the Inventory API client: %w", err)}
+		}
+		creds = credentials.NewTLS(tlsConfig)
 	}
 
 	conn, err := grpc.NewClient(
 		c.InventoryURL,
-		opts...,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(maxReceiveMessageSize),
+			grpc.MaxCallSendMsgSize(maxSendMessageSize),
+		),
 	)
 	if err != nil {
 		return CompletedConfig{}, []error{err}
 	}
 
-	tokenReq := &tokenClientConfig{
-		clientId:       c.ClientId,
-		clientSecret:   c.ClientSecret,
-		url:            c.TokenEndpoint,
-		enableOIDCAuth: c.EnableOidcAuth,
-		insecure:       c.Insecure,
-	}
-
 	return CompletedConfig{
 		&completedConfig{
-			Options:     c.Options,
-			gRPCConn:    conn,
-			tokenConfig: tokenReq,
+			Options:   c.Options,
+			gRPCConn:  conn,
+			tlsConfig: tlsConfig,
 		},
 	}, nil
 }
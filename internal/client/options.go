@@ -3,6 +3,7 @@ package kessel
 import (
 	"fmt"
 
+	"github.com/project-kessel/inventory-consumer/internal/secrets"
 	"github.com/spf13/pflag"
 )
 
@@ -13,7 +14,24 @@ type Options struct {
 	EnableOidcAuth bool   `mapstructure:"enable-oidc-auth"`
 	ClientId       string `mapstructure:"client-id"`
 	ClientSecret   string `mapstructure:"client-secret"`
-	TokenEndpoint  string `mapstructure:"sso-token-endpoint"`
+	// ClientSecretFile is a file the client secret is read from instead, and reloaded from when it changes
+	ClientSecretFile string `mapstructure:"client-secret-file"`
+	TokenEndpoint    string `mapstructure:"sso-token-endpoint"`
+	// ReportTimeoutSeconds and DeleteTimeoutSeconds are the deadlines for each request, or 0 for no deadline
+	ReportTimeoutSeconds int `mapstructure:"report-timeout-seconds"`
+	DeleteTimeoutSeconds int `mapstructure:"delete-timeout-seconds"`
+	// RequestsPerSecond limits the rate of requests to Inventory API with bursts of up to RequestBurst requests,
+	// or is 0 for no limit. With AdaptiveRateLimit, the rate is reduced while Inventory API is overloaded.
+	RequestsPerSecond float64 `mapstructure:"requests-per-second"`
+	RequestBurst      int     `mapstructure:"request-burst"`
+	AdaptiveRateLimit bool    `mapstructure:"adaptive-rate-limit"`
+	// CACertFile verifies the Inventory API server certificate instead of the system certificates, and ClientCertFile
+	// and ClientKeyFile are presented to Inventory API for mutual TLS. ServerName overrides the name the server
+	// certificate is verified against. They are only used when the client is not insecure.
+	CACertFile     string `mapstructure:"ca-cert-file"`
+	ClientCertFile string `mapstructure:"client-cert-file"`
+	ClientKeyFile  string `mapstructure:"client-key-file"`
+	ServerName     string `mapstructure:"server-name"`
 }
 
 func NewOptions() *Options {
@@ -21,6 +39,12 @@ func NewOptions() *Options {
 		Enabled:        true,
 		Insecure:       true,
 		EnableOidcAuth: false,
+
+		ReportTimeoutSeconds: 10,
+		DeleteTimeoutSeconds: 10,
+
+		RequestBurst:      10,
+		AdaptiveRateLimit: true,
 	}
 }
 
@@ -32,9 +56,19 @@ func (o *Options) AddFlags(fs *pflag.FlagSet, prefix string) {
 	fs.StringVar(&o.InventoryURL, prefix+"url", o.InventoryURL, "gRPC endpoint of the kessel inventory service.")
 	fs.StringVar(&o.ClientId, prefix+"client-id", o.ClientId, "service account client id")
 	fs.StringVar(&o.ClientSecret, prefix+"client-secret", o.ClientSecret, "service account secret")
+	fs.StringVar(&o.ClientSecretFile, prefix+"client-secret-file", o.ClientSecretFile, "file containing the service account secret, reloaded when it changes; overrides client-secret")
 	fs.StringVar(&o.TokenEndpoint, prefix+"sso-token-endpoint", o.TokenEndpoint, "sso token endpoint for authentication")
 	fs.BoolVar(&o.EnableOidcAuth, prefix+"enable-oidc-auth", o.EnableOidcAuth, "enable oidc token auth to connect with Inventory API service")
 	fs.BoolVar(&o.Insecure, prefix+"insecure-client", o.Insecure, "the http client that connects to kessel should not verify certificates.")
+	fs.IntVar(&o.ReportTimeoutSeconds, prefix+"report-timeout-seconds", o.ReportTimeoutSeconds, "deadline in seconds for each ReportResource request, or 0 for no deadline")
+	fs.IntVar(&o.DeleteTimeoutSeconds, prefix+"delete-timeout-seconds", o.DeleteTimeoutSeconds, "deadline in seconds for each DeleteResource request, or 0 for no deadline")
+	fs.Float64Var(&o.RequestsPerSecond, prefix+"requests-per-second", o.RequestsPerSecond, "maximum requests per second sent to Inventory API, or 0 for no limit")
+	fs.IntVar(&o.RequestBurst, prefix+"request-burst", o.RequestBurst, "maximum requests sent at once when requests are rate limited")
+	fs.BoolVar(&o.AdaptiveRateLimit, prefix+"adaptive-rate-limit", o.AdaptiveRateLimit, "reduce the request rate while Inventory API returns ResourceExhausted or Unavailable errors")
+	fs.StringVar(&o.CACertFile, prefix+"ca-cert-file", o.CACertFile, "PEM file of the CA that signed the Inventory API server certificate; defaults to the system certificates")
+	fs.StringVar(&o.ClientCertFile, prefix+"client-cert-file", o.ClientCertFile, "PEM client certificate presented to Inventory API for mutual TLS")
+	fs.StringVar(&o.ClientKeyFile, prefix+"client-key-file", o.ClientKeyFile, "PEM private key of the client certificate")
+	fs.StringVar(&o.ServerName, prefix+"server-name", o.ServerName, "name used to verify the Inventory API server certificate instead of the url host")
 }
 
 func (o *Options) Validate() []error {
@@ -43,6 +77,18 @@ func (o *Options) Validate() []error {
 	if len(o.InventoryURL) == 0 && o.Enabled {
 		errs = append(errs, fmt.Errorf("kessel url may not be empty"))
 	}
+	if o.ReportTimeoutSeconds < 0 || o.DeleteTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("kessel request timeouts can not be negative"))
+	}
+	if o.RequestsPerSecond < 0 || o.RequestBurst < 0 {
+		errs = append(errs, fmt.Errorf("kessel request rate limit can not be negative"))
+	}
+	if (o.ClientCertFile == "") != (o.ClientKeyFile == "") {
+		errs = append(errs, fmt.Errorf("kessel client-cert-file and client-key-file must be set together"))
+	}
+	if o.Insecure && (o.CACertFile != "" || o.ClientCertFile != "" || o.ServerName != "") {
+		errs = append(errs, fmt.Errorf("kessel TLS options can not be used with insecure-client"))
+	}
 
 	return errs
 }
@@ -50,5 +96,13 @@ func (o *Options) Validate() []error {
 func (o *Options) Complete() []error {
 	var errs []error
 
+	if o.ClientSecretFile != "" {
+		secret, err := secrets.Read(o.ClientSecretFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load kessel client secret: %w", err))
+		}
+		o.ClientSecret = secret
+	}
+
 	return errs
 }
@@ -1,6 +1,8 @@
 package kessel
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/project-kessel/inventory-consumer/internal/common"
@@ -15,9 +17,13 @@ func TestNewOptions(t *testing.T) {
 	}{
 		options: NewOptions(),
 		expectedOptions: &Options{
-			Enabled:        true,
-			Insecure:       true,
-			EnableOidcAuth: false,
+			Enabled:              true,
+			Insecure:             true,
+			EnableOidcAuth:       false,
+			ReportTimeoutSeconds: 10,
+			DeleteTimeoutSeconds: 10,
+			RequestBurst:         10,
+			AdaptiveRateLimit:    true,
 		},
 	}
 	assert.Equal(t, test.expectedOptions, NewOptions())
@@ -68,6 +74,55 @@ func TestOptions_Validate(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "request timeouts are negative",
+			options: &Options{
+				Enabled:              true,
+				InventoryURL:         "inventory-api:9000",
+				ReportTimeoutSeconds: -1,
+			},
+			expectError: true,
+		},
+		{
+			name: "request rate limit is negative",
+			options: &Options{
+				Enabled:           true,
+				InventoryURL:      "inventory-api:9000",
+				RequestsPerSecond: -1,
+			},
+			expectError: true,
+		},
+		{
+			name: "client certificate is set without a key",
+			options: &Options{
+				Enabled:        true,
+				InventoryURL:   "inventory-api:9000",
+				ClientCertFile: "/etc/kessel/tls.crt",
+			},
+			expectError: true,
+		},
+		{
+			name: "tls options are set with an insecure client",
+			options: &Options{
+				Enabled:      true,
+				InventoryURL: "inventory-api:9000",
+				Insecure:     true,
+				CACertFile:   "/etc/kessel/ca.crt",
+			},
+			expectError: true,
+		},
+		{
+			name: "tls options are set with a secure client",
+			options: &Options{
+				Enabled:        true,
+				InventoryURL:   "inventory-api:9000",
+				CACertFile:     "/etc/kessel/ca.crt",
+				ClientCertFile: "/etc/kessel/tls.crt",
+				ClientKeyFile:  "/etc/kessel/tls.key",
+				ServerName:     "inventory-api",
+			},
+			expectError: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -81,3 +136,19 @@ func TestOptions_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestOptions_Complete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client-secret")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	options := NewOptions()
+	options.ClientSecret = "from-config"
+	options.ClientSecretFile = path
+	assert.Nil(t, options.Complete())
+	assert.Equal(t, "s3cret", options.ClientSecret)
+
+	options.ClientSecretFile = filepath.Join(t.TempDir(), "missing")
+	assert.Len(t, options.Complete(), 1)
+}
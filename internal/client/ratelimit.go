@@ -0,0 +1,156 @@
+package kessel
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// minRateFraction is the lowest fraction of the configured rate an adaptive limiter reduces to
+	minRateFraction = 0.1
+	// rateIncreaseFraction of the configured rate is restored after each successful request
+	rateIncreaseFraction = 0.01
+	// rateDecreaseInterval keeps a burst of failed requests from reducing the rate more than once
+	rateDecreaseInterval = time.Second
+)
+
+// Limiter is a token bucket that limits the rate of requests sent to Inventory API. Tokens are added at the current
+// rate up to the burst size, and each request takes one token, waiting for it if the bucket is empty.
+// An adaptive Limiter halves its rate when Inventory API reports it is overloaded or unavailable, and gradually
+// restores it to the configured rate as requests succeed.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     float64
+	rate      float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	adaptive  bool
+	decreased time.Time
+	now       func() time.Time
+}
+
+// NewLimiter returns a Limiter allowing requestsPerSecond requests with bursts of up to burst requests
+func NewLimiter(requestsPerSecond float64, burst int, adaptive bool) *Limiter {
+	l := &Limiter{
+		limit:    requestsPerSecond,
+		rate:     requestsPerSecond,
+		burst:    float64(max(burst, 1)),
+		adaptive: adaptive,
+		now:      time.Now,
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	return l
+}
+
+// Wait blocks until a request may be sent and returns how long it waited. If ctx is canceled first, the token is
+// returned to the bucket and the context error is returned.
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	delay := l.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	start := l.now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		l.release()
+		return l.now().Sub(start), ctx.Err()
+	}
+}
+
+// Observe adjusts the rate of an adaptive Limiter using the outcome of a request
+func (l *Limiter) Observe(err error) {
+	if !l.adaptive {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// tokens earned at the current rate are added before it changes
+	l.refill()
+
+	if isOverloaded(err) {
+		now := l.now()
+		if now.Sub(l.decreased) < rateDecreaseInterval {
+			return
+		}
+		l.decreased = now
+		l.rate = max(l.rate/2, l.limit*minRateFraction)
+		return
+	}
+	if err == nil {
+		l.rate = min(l.rate+l.limit*rateIncreaseFraction, l.limit)
+	}
+}
+
+// Rate returns the current requests per second
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// reserve takes a token and returns how long to wait before it is available
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// release returns a reserved token that was not used
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.tokens+1, l.burst)
+}
+
+// refill adds the tokens earned since the last refill; l.mu must be held
+func (l *Limiter) refill() {
+	now := l.now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+}
+
+// isOverloaded returns true if a request failed because Inventory API is overloaded or unavailable
+func isOverloaded(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && (st.Code() == codes.ResourceExhausted || st.Code() == codes.Unavailable)
+}
+
+// limitedConn sends requests on a connection once the rate limiter of the client allows them
+type limitedConn struct {
+	grpc.ClientConnInterface
+	client *KesselClient
+}
+
+func (c *limitedConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	if err := c.client.waitForLimiter(ctx, path.Base(method)); err != nil {
+		return err
+	}
+	err := c.ClientConnInterface.Invoke(ctx, method, args, reply, opts...)
+	c.client.observe(err)
+	return err
+}
+
+func (c *limitedConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := c.client.waitForLimiter(ctx, path.Base(method)); err != nil {
+		return nil, err
+	}
+	return c.ClientConnInterface.NewStream(ctx, desc, method, opts...)
+}
@@ -0,0 +1,167 @@
+package kessel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestLimiter returns a limiter with a clock that only moves when advanced
+func newTestLimiter(requestsPerSecond float64, burst int, adaptive bool) (*Limiter, *time.Time) {
+	l := NewLimiter(requestsPerSecond, burst, adaptive)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.last = now
+	return l, &now
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	l, now := newTestLimiter(10, 2, false)
+
+	// the burst is sent without waiting
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, time.Duration(0), l.reserve())
+
+	// later requests wait for a token in turn
+	assert.Equal(t, 100*time.Millisecond, l.reserve())
+	assert.Equal(t, 200*time.Millisecond, l.reserve())
+
+	// tokens are added at the rate up to the burst size
+	*now = now.Add(time.Minute)
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, time.Duration(0), l.reserve())
+	assert.Equal(t, 100*time.Millisecond, l.reserve())
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := NewLimiter(20, 1, false)
+
+	wait, err := l.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+
+	wait, err = l.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Greater(t, wait, time.Duration(0))
+}
+
+func TestLimiter_WaitCanceled(t *testing.T) {
+	l := NewLimiter(0.1, 1, false)
+	_, err := l.Wait(context.Background())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = l.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// the canceled request does not keep its token
+	assert.InDelta(t, 0, l.tokens, 0.01)
+}
+
+func TestLimiter_Adaptive(t *testing.T) {
+	overloaded := status.Error(codes.ResourceExhausted, "too many requests")
+	l, now := newTestLimiter(100, 10, true)
+
+	l.Observe(overloaded)
+	assert.Equal(t, 50.0, l.Rate())
+
+	// a burst of failures only reduces the rate once
+	l.Observe(status.Error(codes.Unavailable, "unavailable"))
+	assert.Equal(t, 50.0, l.Rate())
+
+	// other errors do not change the rate
+	*now = now.Add(rateDecreaseInterval)
+	l.Observe(errors.New("invalid resource"))
+	l.Observe(status.Error(codes.InvalidArgument, "invalid resource"))
+	assert.Equal(t, 50.0, l.Rate())
+
+	// the rate does not drop below the minimum
+	for range 10 {
+		*now = now.Add(rateDecreaseInterval)
+		l.Observe(overloaded)
+	}
+	assert.Equal(t, 10.0, l.Rate())
+
+	// successful requests restore the rate up to the configured rate
+	for range 200 {
+		l.Observe(nil)
+	}
+	assert.Equal(t, 100.0, l.Rate())
+}
+
+func TestLimiter_NotAdaptive(t *testing.T) {
+	l, _ := newTestLimiter(100, 10, false)
+	l.Observe(status.Error(codes.ResourceExhausted, "too many requests"))
+	assert.Equal(t, 100.0, l.Rate())
+}
+
+func TestNew_RateLimit(t *testing.T) {
+	config := createTestConfig(true, false)
+	client, err := New(config, createTestLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, client.Limiter)
+
+	config.RequestsPerSecond = 50
+	config.RequestBurst = 5
+	client, err = New(config, createTestLogger())
+	assert.NoError(t, err)
+	assert.NotNil(t, client.Limiter)
+	assert.Equal(t, 50.0, client.Limiter.Rate())
+}
+
+func TestKesselClient_WaitForLimiter(t *testing.T) {
+	client := &KesselClient{Limiter: NewLimiter(0.1, 1, false)}
+	var waited []string
+	client.SetRateLimitObserver(func(operation string, wait time.Duration) {
+		waited = append(waited, operation)
+	})
+
+	assert.NoError(t, client.waitForLimiter(context.Background(), "ReportResource"))
+
+	// requests that are canceled while waiting for the limiter are not sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, client.waitForLimiter(ctx, "DeleteResource"), context.Canceled)
+	assert.Equal(t, []string{"ReportResource", "DeleteResource"}, waited)
+}
+
+// recordingConn records the methods of the requests sent on it
+type recordingConn struct {
+	grpc.ClientConnInterface
+	methods []string
+}
+
+func (r *recordingConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	r.methods = append(r.methods, method)
+	return nil
+}
+
+func TestKesselClient_Conn(t *testing.T) {
+	assert.Nil(t, (&KesselClient{}).Conn())
+
+	inner := &recordingConn{}
+	client := &KesselClient{Limiter: NewLimiter(0.1, 1, false), conn: inner}
+	var waited []string
+	client.SetRateLimitObserver(func(operation string, wait time.Duration) {
+		waited = append(waited, operation)
+	})
+
+	conn := client.Conn()
+	assert.NoError(t, conn.Invoke(context.Background(), "/kessel.inventory.v1beta3.KesselInventoryService/Check", nil, nil))
+
+	// requests that are canceled while waiting for the limiter are not sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, conn.Invoke(ctx, "/kessel.inventory.v1beta3.KesselInventoryService/Check", nil, nil), context.Canceled)
+	assert.Equal(t, []string{"Check", "Check"}, waited)
+	assert.Len(t, inner.methods, 1)
+}
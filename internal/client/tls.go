@@ -0,0 +1,61 @@
+package kessel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TransportCredentials returns the credentials used to connect to Inventory API. Unless the client is insecure,
+// server certificates are verified against the CA in CACertFile, or the system certificates if it is not set, and the
+// certificate in ClientCertFile is presented to the server for mutual TLS.
+func TransportCredentials(o *Options) (credentials.TransportCredentials, error) {
+	if o.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// tlsConfig loads the CA and client certificates of the options
+func (o *Options) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: o.ServerName,
+	}
+
+	if o.CACertFile != "" {
+		pem, err := os.ReadFile(o.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA certificate file %s", o.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	} else {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load system certificates: %w", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if o.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertFile, o.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,148 @@
+package kessel
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// noError stops the test if err is not nil
+func noError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and its key to dir and returns their paths
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	noError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kessel-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	noError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	noError(t, err)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	noError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	noError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile
+}
+
+func TestTransportCredentials(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+	invalidFile := filepath.Join(dir, "invalid.crt")
+	noError(t, os.WriteFile(invalidFile, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name             string
+		options          *Options
+		expectedProtocol string
+		expectError      bool
+	}{
+		{
+			name:             "insecure client",
+			options:          &Options{Insecure: true},
+			expectedProtocol: "insecure",
+		},
+		{
+			name:             "system certificates",
+			options:          &Options{},
+			expectedProtocol: "tls",
+		},
+		{
+			name:             "custom CA and client certificate",
+			options:          &Options{CACertFile: certFile, ClientCertFile: certFile, ClientKeyFile: keyFile, ServerName: "inventory-api"},
+			expectedProtocol: "tls",
+		},
+		{
+			name:        "missing CA file",
+			options:     &Options{CACertFile: filepath.Join(dir, "missing.crt")},
+			expectError: true,
+		},
+		{
+			name:        "CA file without certificates",
+			options:     &Options{CACertFile: invalidFile},
+			expectError: true,
+		},
+		{
+			name:        "invalid client certificate",
+			options:     &Options{ClientCertFile: invalidFile, ClientKeyFile: keyFile},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			creds, err := TransportCredentials(test.options)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedProtocol, creds.Info().SecurityProtocol)
+			if test.expectedProtocol == "tls" {
+				assert.Equal(t, test.options.ServerName, creds.Info().ServerName)
+			}
+		})
+	}
+}
+
+func TestOptions_TLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir())
+	options := &Options{CACertFile: certFile, ClientCertFile: certFile, ClientKeyFile: keyFile, ServerName: "inventory-api"}
+
+	tlsConfig, err := options.tlsConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "inventory-api", tlsConfig.ServerName)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.NotNil(t, tlsConfig.RootCAs)
+}
+
+func TestConfig_CompleteTLSError(t *testing.T) {
+	options := &Options{
+		Enabled:      true,
+		InventoryURL: "localhost:9090",
+		CACertFile:   filepath.Join(t.TempDir(), "missing.crt"),
+	}
+
+	// TLS errors fail startup instead of falling back to an unverified connection
+	_, errs := NewConfig(options).Complete()
+	assert.Len(t, errs, 1)
+}
+
+func TestConfig_CompleteTLS(t *testing.T) {
+	certFile, _ := writeTestCertificate(t, t.TempDir())
+	options := &Options{Enabled: true, InventoryURL: "localhost:9090", CACertFile: certFile, ServerName: "inventory-api"}
+
+	// the TLS config is passed to the SDK client builder
+	config, errs := NewConfig(options).Complete()
+	assert.Nil(t, errs)
+	assert.Equal(t, "inventory-api", config.tlsConfig.ServerName)
+
+	options.Insecure = true
+	config, errs = NewConfig(options).Complete()
+	assert.Nil(t, errs)
+	assert.Nil(t, config.tlsConfig)
+}
@@ -35,18 +35,95 @@ func LogConfigurationInfo(options *OptionsConfig) {
 		options.Consumer.RetryOptions.MaxBackoffSeconds,
 	)
 
-	log.Debugf("Consumer Auth Settings: Enabled: %v, Security Protocol: %s, Mechanism: %s, Username: %s",
+	log.Debugf("Consumer Retry Settings: Retryable Codes: %v, Terminal Codes: %v",
+		options.Consumer.RetryOptions.RetryableCodes,
+		options.Consumer.RetryOptions.TerminalCodes,
+	)
+
+	log.Debugf("Consumer Processing Settings: Workers Per Partition: %d, Dead Letter Topic: %s, Dead Letter Timeout Ms: %d, Message Format: %s, Commit Count: %d, Commit Interval Ms: %d",
+		options.Consumer.WorkersPerPartition,
+		options.Consumer.DeadLetterTopic,
+		options.Consumer.DeadLetterTimeoutMs,
+		options.Consumer.MessageFormat,
+		options.Consumer.CommitCount,
+		options.Consumer.CommitIntervalMs,
+	)
+	if options.Consumer.CircuitBreakerOptions != nil {
+		log.Debugf("Consumer Circuit Breaker Settings: Enabled: %v, Failure Threshold: %d, Open Seconds: %d, Half Open Successes: %d",
+			options.Consumer.CircuitBreakerOptions.Enabled,
+			options.Consumer.CircuitBreakerOptions.FailureThreshold,
+			options.Consumer.CircuitBreakerOptions.OpenSeconds,
+			options.Consumer.CircuitBreakerOptions.HalfOpenSuccesses,
+		)
+	}
+	if options.Consumer.BatchOptions != nil {
+		log.Debugf("Consumer Batch Settings: Enabled: %v, Max Size: %d, Max Wait Ms: %d, Concurrency: %d",
+			options.Consumer.BatchOptions.Enabled,
+			options.Consumer.BatchOptions.MaxSize,
+			options.Consumer.BatchOptions.MaxWaitMs,
+			options.Consumer.BatchOptions.Concurrency,
+		)
+	}
+	if options.Consumer.HostOptions != nil {
+		log.Debugf("Consumer Host Settings: Reporter Instance ID: %s, Reporter Version: %s, API Href: %s, Console Href: %s",
+			options.Consumer.HostOptions.ReporterInstanceID,
+			options.Consumer.HostOptions.ReporterVersion,
+			options.Consumer.HostOptions.APIHref,
+			options.Consumer.HostOptions.ConsoleHref,
+		)
+	}
+	for topic, topicOptions := range options.Consumer.TopicOptions {
+		if topicOptions != nil {
+			log.Debugf("Consumer Topic Settings: Topic: %s, Message Format: %s, Operation: %s, Version: %s, Transformer: %s, Dead Letter Topic: %s",
+				topic,
+				topicOptions.MessageFormat,
+				topicOptions.Operation,
+				topicOptions.Version,
+				topicOptions.Transformer,
+				topicOptions.DeadLetterTopic,
+			)
+		}
+	}
+	if options.Consumer.HeaderOptions != nil {
+		log.Debugf("Consumer Header Settings: Operation: %v, Version: %v, Topic Overrides: %v",
+			options.Consumer.HeaderOptions.Operation,
+			options.Consumer.HeaderOptions.Version,
+			options.Consumer.HeaderOptions.Topics,
+		)
+	}
+	if options.Consumer.SchemaRegistryOptions != nil && options.Consumer.SchemaRegistryOptions.URL != "" {
+		log.Debugf("Consumer Schema Registry Settings: URL: %s, Username: %s, Timeout Seconds: %d",
+			options.Consumer.SchemaRegistryOptions.URL,
+			options.Consumer.SchemaRegistryOptions.Username,
+			options.Consumer.SchemaRegistryOptions.TimeoutSeconds,
+		)
+	}
+	log.Debugf("Consumer Auth Settings: Enabled: %v, Security Protocol: %s, Mechanism: %s, Username: %s, Password File: %s",
 		options.Consumer.AuthOptions.Enabled,
 		options.Consumer.AuthOptions.SecurityProtocol,
 		options.Consumer.AuthOptions.SASLMechanism,
-		options.Consumer.AuthOptions.SASLUsername)
+		options.Consumer.AuthOptions.SASLUsername,
+		options.Consumer.AuthOptions.SASLPasswordFile)
 
 	if options.Client.Enabled {
-		log.Debugf("Client Configuration: URL: %s, Insecure?: %t, Token Endpoint?: %s",
+		log.Debugf("Client Configuration: URL: %s, Insecure?: %t, Token Endpoint?: %s, Report Timeout Seconds: %d, Delete Timeout Seconds: %d",
 			options.Client.InventoryURL,
 			options.Client.Insecure,
 			options.Client.TokenEndpoint,
+			options.Client.ReportTimeoutSeconds,
+			options.Client.DeleteTimeoutSeconds,
+		)
+		log.Debugf("Client Rate Limit Settings: Requests Per Second: %v, Request Burst: %d, Adaptive?: %t",
+			options.Client.RequestsPerSecond,
+			options.Client.RequestBurst,
+			options.Client.AdaptiveRateLimit,
+		)
+		log.Debugf("Client TLS Settings: CA Cert File: %s, Client Cert File: %s, Server Name: %s",
+			options.Client.CACertFile,
+			options.Client.ClientCertFile,
+			options.Client.ServerName,
 		)
+		log.Debugf("Client Secret Settings: Client Secret File: %s", options.Client.ClientSecretFile)
 	}
 }
 
@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"context"
+	"time"
+
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/project-kessel/kessel-sdk-go/kessel/inventory/v1beta2"
 	"github.com/stretchr/testify/mock"
@@ -14,6 +17,14 @@ type MockClient struct {
 	mock.Mock
 }
 
+type MockProducer struct {
+	mock.Mock
+}
+
+type MockReplayReader struct {
+	mock.Mock
+}
+
 func (m *MockConsumer) CommitOffsets(offsets []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
 	args := m.Called(offsets)
 	return args.Get(0).([]kafka.TopicPartition), args.Error(1)
@@ -44,13 +55,82 @@ func (m *MockConsumer) AssignmentLost() bool {
 	return args.Get(0).(bool)
 }
 
-func (m *MockClient) CreateOrUpdateResource(request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error) {
-	args := m.Called(request)
+func (m *MockConsumer) Assignment() ([]kafka.TopicPartition, error) {
+	args := m.Called()
+	return args.Get(0).([]kafka.TopicPartition), args.Error(1)
+}
+
+func (m *MockConsumer) Pause(partitions []kafka.TopicPartition) error {
+	args := m.Called(partitions)
+	return args.Error(0)
+}
+
+func (m *MockConsumer) Resume(partitions []kafka.TopicPartition) error {
+	args := m.Called(partitions)
+	return args.Error(0)
+}
+
+func (m *MockConsumer) SetSaslCredentials(username, password string) error {
+	args := m.Called(username, password)
+	return args.Error(0)
+}
+
+// Produce records the call and, when no error is returned, reports a successful delivery on deliveryChan
+func (m *MockProducer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	args := m.Called(msg, deliveryChan)
+	if args.Error(0) == nil && deliveryChan != nil {
+		deliveryChan <- msg
+	}
+	return args.Error(0)
+}
+
+func (m *MockProducer) Flush(timeoutMs int) int {
+	args := m.Called(timeoutMs)
+	return args.Int(0)
+}
+
+func (m *MockProducer) Close() {
+	m.Called()
+}
+
+func (m *MockProducer) SetSaslCredentials(username, password string) error {
+	args := m.Called(username, password)
+	return args.Error(0)
+}
+
+func (m *MockReplayReader) GetMetadata(topic *string, allTopics bool, timeoutMs int) (*kafka.Metadata, error) {
+	args := m.Called(topic, allTopics, timeoutMs)
+	return args.Get(0).(*kafka.Metadata), args.Error(1)
+}
+
+func (m *MockReplayReader) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	args := m.Called(topic, partition, timeoutMs)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockReplayReader) OffsetsForTimes(times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	args := m.Called(times, timeoutMs)
+	return args.Get(0).([]kafka.TopicPartition), args.Error(1)
+}
+
+func (m *MockReplayReader) Assign(partitions []kafka.TopicPartition) error {
+	args := m.Called(partitions)
+	return args.Error(0)
+}
+
+func (m *MockReplayReader) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	args := m.Called(timeout)
+	msg, _ := args.Get(0).(*kafka.Message)
+	return msg, args.Error(1)
+}
+
+func (m *MockClient) CreateOrUpdateResource(ctx context.Context, request *v1beta2.ReportResourceRequest) (*v1beta2.ReportResourceResponse, error) {
+	args := m.Called(ctx, request)
 	return args.Get(0).(*v1beta2.ReportResourceResponse), args.Error(1)
 }
 
-func (m *MockClient) DeleteResource(request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error) {
-	args := m.Called(request)
+func (m *MockClient) DeleteResource(ctx context.Context, request *v1beta2.DeleteResourceRequest) (*v1beta2.DeleteResourceResponse, error) {
+	args := m.Called(ctx, request)
 	return args.Get(0).(*v1beta2.DeleteResourceResponse), args.Error(1)
 }
 
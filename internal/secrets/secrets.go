@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// DefaultInterval is how often watched secret files are checked for changes
+const DefaultInterval = 30 * time.Second
+
+// Read returns the contents of a secret file without surrounding whitespace, such as a trailing newline
+func Read(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Watcher reloads secret files when they change, such as when a mounted Kubernetes secret is rotated.
+// Files are polled rather than watched for events since mounted secrets are replaced by swapping symlinks.
+type Watcher struct {
+	mu       sync.Mutex
+	files    []*watchedFile
+	interval time.Duration
+	logger   *log.Helper
+}
+
+type watchedFile struct {
+	path     string
+	value    string
+	onChange func(value string)
+}
+
+// NewWatcher returns a Watcher that checks its files every interval
+func NewWatcher(interval time.Duration, logger *log.Helper) *Watcher {
+	return &Watcher{interval: interval, logger: logger}
+}
+
+// Add watches the secret file at path and calls onChange with its new contents whenever they change.
+// Paths that are empty are ignored so optional secret files can be added unconditionally.
+func (w *Watcher) Add(path string, onChange func(value string)) {
+	if path == "" {
+		return
+	}
+	value, err := Read(path)
+	if err != nil {
+		w.logger.Errorf("failed to read secret file %s: %v", path, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files = append(w.files, &watchedFile{path: path, value: value, onChange: onChange})
+}
+
+// Run checks the files for changes until ctx is canceled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check reloads each file and calls its handler if the contents changed. Files that can not be read keep their
+// previous value, since a secret being replaced may briefly be missing.
+func (w *Watcher) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, file := range w.files {
+		value, err := Read(file.path)
+		if err != nil {
+			w.logger.Errorf("failed to reload secret file %s: %v", file.path, err)
+			continue
+		}
+		if value == file.value || value == "" {
+			continue
+		}
+		file.value = value
+		w.logger.Infof("secret file %s changed, reloading", file.path)
+		file.onChange(value)
+	}
+}
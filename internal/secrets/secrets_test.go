@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/project-kessel/inventory-consumer/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestLogger() *log.Helper {
+	_, logger := common.InitLogger("info", common.LoggerOptions{})
+	return log.NewHelper(log.With(logger, "service", "test"))
+}
+
+func writeSecret(t *testing.T, path, value string) {
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	writeSecret(t, path, "s3cret\n")
+
+	value, err := Read(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	_, err = Read(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	writeSecret(t, path, "first")
+
+	var changes []string
+	w := NewWatcher(time.Hour, createTestLogger())
+	w.Add(path, func(value string) { changes = append(changes, value) })
+	// optional secret files that are not configured are ignored
+	w.Add("", func(value string) { t.Fatal("unexpected change") })
+
+	w.check()
+	assert.Empty(t, changes)
+
+	writeSecret(t, path, "second\n")
+	w.check()
+	w.check()
+	assert.Equal(t, []string{"second"}, changes)
+
+	// a secret that is briefly missing or empty while it is replaced keeps its value
+	assert.NoError(t, os.Remove(path))
+	w.check()
+	writeSecret(t, path, "")
+	w.check()
+	writeSecret(t, path, "second")
+	w.check()
+	assert.Equal(t, []string{"second"}, changes)
+}
+
+func TestWatcher_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	writeSecret(t, path, "first")
+
+	changed := make(chan string, 1)
+	w := NewWatcher(5*time.Millisecond, createTestLogger())
+	w.Add(path, func(value string) { changed <- value })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	writeSecret(t, path, "second")
+	select {
+	case value := <-changed:
+		assert.Equal(t, "second", value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the secret to be reloaded")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the watcher to stop")
+	}
+}
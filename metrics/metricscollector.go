@@ -97,6 +97,11 @@ type MetricsCollector struct {
 	MsgProcessFailures metric.Int64Counter
 	ConsumerErrors     metric.Int64Counter
 	KafkaErrorEvents   metric.Int64Counter
+	MsgsDeadLettered   metric.Int64Counter
+	// CircuitBreakerState is 0 while the Inventory API circuit breaker is closed, 1 while half-open and 2 while open
+	CircuitBreakerState metric.Int64Gauge
+	// RateLimiterWait is the time in seconds Inventory API requests waited for the client rate limiter
+	RateLimiterWait metric.Float64Histogram
 }
 
 // New instantiates a new MetricsCollector
@@ -174,6 +179,15 @@ func (m *MetricsCollector) New(topics []string) error {
 	if m.KafkaErrorEvents, err = meter.Int64Counter(prefix + "kafka_error_events"); err != nil {
 		return err
 	}
+	if m.MsgsDeadLettered, err = meter.Int64Counter(prefix + "msgs_dead_lettered"); err != nil {
+		return err
+	}
+	if m.CircuitBreakerState, err = meter.Int64Gauge(prefix + "circuit_breaker_state"); err != nil {
+		return err
+	}
+	if m.RateLimiterWait, err = meter.Float64Histogram(prefix+"rate_limiter_wait_seconds", metric.WithUnit("s")); err != nil {
+		return err
+	}
 
 	return nil
 }